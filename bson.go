@@ -0,0 +1,600 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BSONObjectID is BSON ObjectId. It is written to JSON as hex string.
+type BSONObjectID [12]byte
+
+func (id BSONObjectID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id BSONObjectID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// BSONBinary is BSON binary data with a subtype other than generic 0, which is decoded as []byte.
+type BSONBinary struct {
+	Subtype byte
+	Data    []byte
+}
+
+// BSONDecimal128 is BSON decimal128, IEEE 754-2008 128-bit decimal floating point in binary integer decimal encoding.
+// It is written to JSON as string.
+type BSONDecimal128 struct {
+	High, Low uint64
+}
+
+// String returns the value in decimal notation like "1.234", or in scientific notation like "1E+3" for large exponents.
+func (d BSONDecimal128) String() string {
+	sign := ""
+	if d.High>>63 != 0 {
+		sign = "-"
+	}
+	var exp int
+	coef := new(big.Int)
+	if combination := d.High >> 58 & 0x1f; combination>>3 == 3 {
+		switch combination {
+		case 0x1e:
+			return sign + "Infinity"
+		case 0x1f:
+			return "NaN"
+		}
+		// coefficient doesn't fit into 113 bits, so it's not canonical, and is zero
+		exp = int(d.High>>47&0x3fff) - 6176
+	} else {
+		exp = int(d.High>>49&0x3fff) - 6176
+		coef.SetUint64(d.High & (1<<49 - 1))
+		coef.Lsh(coef, 64).Or(coef, new(big.Int).SetUint64(d.Low))
+		if coef.Cmp(bsonMaxCoefficient) > 0 {
+			coef.SetUint64(0)
+		}
+	}
+
+	digits := coef.String()
+	adjusted := exp + len(digits) - 1
+	switch {
+	case exp > 0 || adjusted < -6:
+		s := digits[:1]
+		if len(digits) > 1 {
+			s += "." + digits[1:]
+		}
+		return fmt.Sprintf("%s%sE%+d", sign, s, adjusted)
+	case exp == 0:
+		return sign + digits
+	}
+	if point := len(digits) + exp; point > 0 {
+		return sign + digits[:point] + "." + digits[point:]
+	} else {
+		return sign + "0." + strings.Repeat("0", -point) + digits
+	}
+}
+
+func (d BSONDecimal128) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+var bsonMaxCoefficient, _ = new(big.Int).SetString(strings.Repeat("9", 34), 10)
+
+// BSONTimestamp is BSON internal timestamp, with seconds T and increment I.
+type BSONTimestamp struct {
+	T, I uint32
+}
+
+// BSONRegex is BSON regular expression.
+type BSONRegex struct {
+	Pattern, Options string
+}
+
+// BSONJavaScript is BSON JavaScript code.
+type BSONJavaScript string
+
+// BSONCodeWithScope is BSON JavaScript code with scope (deprecated).
+type BSONCodeWithScope struct {
+	Code  string
+	Scope *Map
+}
+
+// BSONSymbol is BSON symbol (deprecated).
+type BSONSymbol string
+
+// BSONDBPointer is BSON DBPointer (deprecated).
+type BSONDBPointer struct {
+	Ref string
+	ID  BSONObjectID
+}
+
+// BSONMinKey is BSON min key, which compares lower than all other values.
+type BSONMinKey struct{}
+
+// BSONMaxKey is BSON max key, which compares higher than all other values.
+type BSONMaxKey struct{}
+
+// MarshalBSON returns BSON document of the map, keeping order of elements.
+// It implements Marshaler interface of MongoDB driver's bson package.
+//
+// Nested maps are written as embedded documents, []any as arrays, []byte as generic binary,
+// time.Time as UTC datetime with milliseconds, and BSON* types as their BSON types.
+// Integers are written as int32 when their type fits into it, int as int32 or int64 by its value,
+// and float64 as double, including numbers decoded by UnmarshalJSON.
+// Other types are converted through JSON first.
+//
+//	data, err := m.MarshalBSON()
+func (m *Map) MarshalBSON() ([]byte, error) {
+	var w bsonWriter
+	if err := w.document(m); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+type bsonWriter struct {
+	buf []byte
+}
+
+// document writes elements of the map, or items of the array, with length and terminating zero.
+func (w *bsonWriter) document(v Value) error {
+	start := len(w.buf)
+	w.buf = append(w.buf, 0, 0, 0, 0)
+	switch v := v.(type) {
+	case *Map:
+		for el := v.First(); el != nil; el = el.Next() {
+			if err := w.element(el.key, el.value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if err := w.element(strconv.Itoa(i), item); err != nil {
+				return err
+			}
+		}
+	}
+	w.buf = append(w.buf, 0)
+	binary.LittleEndian.PutUint32(w.buf[start:], uint32(len(w.buf)-start))
+	return nil
+}
+
+func (w *bsonWriter) cstring(s string) error {
+	if strings.IndexByte(s, 0) >= 0 {
+		return fmt.Errorf("jsonmap: bson: %q contains zero byte", s)
+	}
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, 0)
+	return nil
+}
+
+func (w *bsonWriter) string(s string) {
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(s)+1))
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, 0)
+}
+
+func (w *bsonWriter) element(key Key, v Value) error {
+	typ := len(w.buf)
+	w.buf = append(w.buf, 0)
+	if err := w.cstring(key); err != nil {
+		return err
+	}
+	t, err := w.value(v)
+	if err != nil {
+		return err
+	}
+	w.buf[typ] = t
+	return nil
+}
+
+// value writes the value, and returns its BSON type.
+func (w *bsonWriter) value(v Value) (byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0x0a, nil
+	case bool:
+		if v {
+			w.buf = append(w.buf, 1)
+		} else {
+			w.buf = append(w.buf, 0)
+		}
+		return 0x08, nil
+	case *Map:
+		return 0x03, w.document(v)
+	case []any:
+		return 0x04, w.document(v)
+	case string:
+		w.string(v)
+		return 0x02, nil
+	case []byte:
+		return w.binary(0, v), nil
+	case BSONBinary:
+		return w.binary(v.Subtype, v.Data), nil
+	case float64:
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+		return 0x01, nil
+	case float32:
+		return w.value(float64(v))
+	case int8:
+		return w.int32(int32(v)), nil
+	case int16:
+		return w.int32(int32(v)), nil
+	case int32:
+		return w.int32(v), nil
+	case uint8:
+		return w.int32(int32(v)), nil
+	case uint16:
+		return w.int32(int32(v)), nil
+	case int:
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			return w.int32(int32(v)), nil
+		}
+		return w.int64(int64(v)), nil
+	case int64:
+		return w.int64(v), nil
+	case uint32:
+		return w.int64(int64(v)), nil
+	case uint:
+		return w.value(uint64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("jsonmap: bson: integer %d overflows int64", v)
+		}
+		return w.int64(int64(v)), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return w.int32(int32(n)), nil
+			}
+			return w.int64(n), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("jsonmap: bson: invalid number %s", v)
+		}
+		return w.value(f)
+	case time.Time:
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, uint64(v.UnixMilli()))
+		return 0x09, nil
+	case BSONObjectID:
+		w.buf = append(w.buf, v[:]...)
+		return 0x07, nil
+	case BSONDecimal128:
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, v.Low)
+		w.buf = binary.LittleEndian.AppendUint64(w.buf, v.High)
+		return 0x13, nil
+	case BSONTimestamp:
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, v.I)
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, v.T)
+		return 0x11, nil
+	case BSONRegex:
+		if err := w.cstring(v.Pattern); err != nil {
+			return 0, err
+		}
+		return 0x0b, w.cstring(v.Options)
+	case BSONJavaScript:
+		w.string(string(v))
+		return 0x0d, nil
+	case BSONSymbol:
+		w.string(string(v))
+		return 0x0e, nil
+	case BSONCodeWithScope:
+		start := len(w.buf)
+		w.buf = append(w.buf, 0, 0, 0, 0)
+		w.string(v.Code)
+		scope := v.Scope
+		if scope == nil {
+			scope = New()
+		}
+		if err := w.document(scope); err != nil {
+			return 0, err
+		}
+		binary.LittleEndian.PutUint32(w.buf[start:], uint32(len(w.buf)-start))
+		return 0x0f, nil
+	case BSONDBPointer:
+		w.string(v.Ref)
+		w.buf = append(w.buf, v.ID[:]...)
+		return 0x0c, nil
+	case BSONMinKey:
+		return 0xff, nil
+	case BSONMaxKey:
+		return 0x7f, nil
+	}
+	value, err := convertJSON(v)
+	if err != nil {
+		return 0, err
+	}
+	return w.value(value)
+}
+
+func (w *bsonWriter) int32(n int32) byte {
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(n))
+	return 0x10
+}
+
+func (w *bsonWriter) int64(n int64) byte {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, uint64(n))
+	return 0x12
+}
+
+func (w *bsonWriter) binary(subtype byte, data []byte) byte {
+	if subtype == 2 { // old binary has length inside too
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(data)+4))
+		w.buf = append(w.buf, subtype)
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(data)))
+	} else {
+		w.buf = binary.LittleEndian.AppendUint32(w.buf, uint32(len(data)))
+		w.buf = append(w.buf, subtype)
+	}
+	w.buf = append(w.buf, data...)
+	return 0x05
+}
+
+// UnmarshalBSON parses BSON document, and stores it in the map, keeping order of elements.
+// It implements Unmarshaler interface of MongoDB driver's bson package, and works on zero Map allocated by it.
+//
+// Embedded documents are decoded as *Map, arrays as []any, int32 as int32, int64 as int64, double as float64,
+// datetime as time.Time in UTC, generic binary as []byte, undefined as nil, and other types as BSON* types.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := m.UnmarshalBSON(data)
+func (m *Map) UnmarshalBSON(data []byte) error {
+	if m.elements == nil {
+		m.Clear()
+	}
+	r := &bsonReader{data: data}
+	v, err := r.document(false)
+	if err != nil {
+		return err
+	}
+	if r.pos < len(data) {
+		return r.errorf("unexpected data after document")
+	}
+	for el := v.(*Map).First(); el != nil; el = el.Next() {
+		m.Push(el.key, el.value)
+	}
+	return nil
+}
+
+type bsonReader struct {
+	data  []byte
+	pos   int
+	depth int // nesting of documents
+}
+
+func (r *bsonReader) errorf(format string, args ...any) error {
+	return &FormatError{Format: "bson", Offset: int64(r.pos), Msg: fmt.Sprintf(format, args...)}
+}
+
+// next returns next n bytes.
+func (r *bsonReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, r.errorf("unexpected end of input")
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *bsonReader) uint32() (uint32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *bsonReader) uint64() (uint64, error) {
+	b, err := r.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (r *bsonReader) cstring() (string, error) {
+	i := bytes.IndexByte(r.data[r.pos:], 0)
+	if i < 0 {
+		return "", r.errorf("unterminated string")
+	}
+	s := string(r.data[r.pos : r.pos+i])
+	r.pos += i + 1
+	return s, nil
+}
+
+func (r *bsonReader) string() (string, error) {
+	start := r.pos
+	n, err := r.uint32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.next(int(int32(n)))
+	if err != nil {
+		return "", err
+	}
+	if len(b) == 0 || b[len(b)-1] != 0 {
+		r.pos = start
+		return "", r.errorf("invalid string")
+	}
+	return string(b[:len(b)-1]), nil
+}
+
+// document reads embedded document as *Map, or array as []any.
+func (r *bsonReader) document(array bool) (Value, error) {
+	start := r.pos
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	end := start + int(int32(n))
+	if int32(n) < 5 || end > len(r.data) || end < start || r.data[end-1] != 0 {
+		r.pos = start
+		return nil, r.errorf("invalid document length")
+	}
+	if r.depth == maxDepth {
+		return nil, r.errorf("exceeded max depth %d", maxDepth)
+	}
+	r.depth++
+	var m *Map
+	a := make([]any, 0)
+	if !array {
+		m = New()
+	}
+	for r.pos < end-1 {
+		typ := r.data[r.pos]
+		r.pos++
+		key, err := r.cstring()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.value(typ)
+		if err != nil {
+			return nil, err
+		}
+		if array {
+			a = append(a, v)
+		} else {
+			m.Push(key, v)
+		}
+	}
+	if r.pos != end-1 {
+		r.pos = start
+		return nil, r.errorf("invalid document length")
+	}
+	r.pos = end
+	r.depth--
+	if array {
+		return a, nil
+	}
+	return m, nil
+}
+
+func (r *bsonReader) value(typ byte) (Value, error) {
+	start := r.pos
+	switch typ {
+	case 0x01:
+		bits, err := r.uint64()
+		return math.Float64frombits(bits), err
+	case 0x02:
+		return r.string()
+	case 0x03:
+		return r.document(false)
+	case 0x04:
+		return r.document(true)
+	case 0x05:
+		n, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if int32(n) < 0 {
+			r.pos = start
+			return nil, r.errorf("invalid binary length")
+		}
+		b, err := r.next(1 + int(n))
+		if err != nil {
+			return nil, err
+		}
+		subtype, data := b[0], append([]byte{}, b[1:]...)
+		if subtype == 0 {
+			return data, nil
+		}
+		if subtype == 2 {
+			if len(data) < 4 || int(binary.LittleEndian.Uint32(data)) != len(data)-4 {
+				r.pos = start
+				return nil, r.errorf("invalid binary of subtype 2")
+			}
+			data = data[4:]
+		}
+		return BSONBinary{Subtype: subtype, Data: data}, nil
+	case 0x06, 0x0a: // undefined, null
+		return nil, nil
+	case 0x07:
+		b, err := r.next(12)
+		var id BSONObjectID
+		copy(id[:], b)
+		return id, err
+	case 0x08:
+		b, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] > 1 {
+			r.pos = start
+			return nil, r.errorf("invalid boolean %d", b[0])
+		}
+		return b[0] == 1, nil
+	case 0x09:
+		ms, err := r.uint64()
+		return time.UnixMilli(int64(ms)).UTC(), err
+	case 0x0b:
+		pattern, err := r.cstring()
+		if err != nil {
+			return nil, err
+		}
+		options, err := r.cstring()
+		return BSONRegex{Pattern: pattern, Options: options}, err
+	case 0x0c:
+		ref, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.next(12)
+		p := BSONDBPointer{Ref: ref}
+		copy(p.ID[:], b)
+		return p, err
+	case 0x0d:
+		s, err := r.string()
+		return BSONJavaScript(s), err
+	case 0x0e:
+		s, err := r.string()
+		return BSONSymbol(s), err
+	case 0x0f:
+		n, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		code, err := r.string()
+		if err != nil {
+			return nil, err
+		}
+		scope, err := r.document(false)
+		if err != nil {
+			return nil, err
+		}
+		if r.pos-start != int(int32(n)) {
+			r.pos = start
+			return nil, r.errorf("invalid code with scope length")
+		}
+		return BSONCodeWithScope{Code: code, Scope: scope.(*Map)}, nil
+	case 0x10:
+		n, err := r.uint32()
+		return int32(n), err
+	case 0x11:
+		n, err := r.uint64()
+		return BSONTimestamp{T: uint32(n >> 32), I: uint32(n)}, err
+	case 0x12:
+		n, err := r.uint64()
+		return int64(n), err
+	case 0x13:
+		low, err := r.uint64()
+		if err != nil {
+			return nil, err
+		}
+		high, err := r.uint64()
+		return BSONDecimal128{High: high, Low: low}, err
+	case 0xff:
+		return BSONMinKey{}, nil
+	case 0x7f:
+		return BSONMaxKey{}, nil
+	}
+	return nil, r.errorf("unknown element type 0x%02x", typ)
+}
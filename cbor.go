@@ -0,0 +1,650 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// CBORTag is CBOR tagged value, with a tag not decoded by UnmarshalCBOR.
+type CBORTag struct {
+	Number  uint64
+	Content Value
+}
+
+// CBORSimple is CBOR simple value, other than false, true, null and undefined.
+type CBORSimple uint8
+
+// CBOREncoder writes maps as CBOR (RFC 8949). Zero value is usable, and writes maps in order of elements.
+//
+// Numbers use preferred serialization: the shortest form of integers and lengths,
+// and the shortest of half, single and double precision floats, which keeps the value.
+// Float values, which are integers, are written as floats, same as JSON numbers decoded by UnmarshalJSON.
+//
+//	data, err := jsonmap.CBOREncoder{Deterministic: true}.Marshal(m)
+type CBOREncoder struct {
+	// Deterministic sorts map entries by bytes of encoded keys,
+	// as required by core deterministic encoding (RFC 8949, section 4.2.1), used by COSE.
+	Deterministic bool
+
+	// IntegerKeys writes keys, which are decimal integers like "1" or "-3", as CBOR integers, as used by COSE.
+	IntegerKeys bool
+
+	// SelfDescribed adds self-described CBOR tag 55799 before the top-level map.
+	SelfDescribed bool
+}
+
+// MarshalCBOR returns CBOR encoding of the map, keeping order of elements.
+// Shortcut for CBOREncoder{}.Marshal(m).
+//
+// []byte is written as byte string, *big.Int as integer or bignum,
+// time.Time as epoch time (tag 1) when it has whole seconds, or as RFC 3339 string (tag 0) otherwise.
+// Other types are converted through JSON first.
+//
+//	data, err := jsonmap.MarshalCBOR(m)
+func MarshalCBOR(m *Map) ([]byte, error) {
+	return CBOREncoder{}.Marshal(m)
+}
+
+// Marshal returns CBOR encoding of the map.
+//
+//	data, err := e.Marshal(m)
+func (e CBOREncoder) Marshal(m *Map) ([]byte, error) {
+	w := &cborWriter{CBOREncoder: e}
+	if e.SelfDescribed {
+		w.head(6, 55799)
+	}
+	if err := w.value(m); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+type cborWriter struct {
+	CBOREncoder
+	buf []byte
+}
+
+// head writes major type with argument in the shortest form.
+func (w *cborWriter) head(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		w.buf = append(w.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, major|25)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+	case n <= math.MaxUint32:
+		w.buf = append(w.buf, major|26)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+	default:
+		w.buf = append(w.buf, major|27)
+		w.buf = binary.BigEndian.AppendUint64(w.buf, n)
+	}
+}
+
+func (w *cborWriter) int(n int64) {
+	if n < 0 {
+		w.head(1, uint64(-1-n))
+	} else {
+		w.head(0, uint64(n))
+	}
+}
+
+func (w *cborWriter) key(k Key) {
+	if w.IntegerKeys {
+		if n, err := strconv.ParseInt(k, 10, 64); err == nil && strconv.FormatInt(n, 10) == k {
+			w.int(n)
+			return
+		}
+	}
+	w.head(3, uint64(len(k)))
+	w.buf = append(w.buf, k...)
+}
+
+func (w *cborWriter) value(v Value) error {
+	switch v := v.(type) {
+	case nil:
+		w.buf = append(w.buf, 0xf6)
+	case bool:
+		if v {
+			w.buf = append(w.buf, 0xf5)
+		} else {
+			w.buf = append(w.buf, 0xf4)
+		}
+	case *Map:
+		return w.mapping(v)
+	case []any:
+		w.head(4, uint64(len(v)))
+		for _, item := range v {
+			if err := w.value(item); err != nil {
+				return err
+			}
+		}
+	case string:
+		w.head(3, uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case []byte:
+		w.head(2, uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	case float64:
+		w.float(v)
+	case float32:
+		w.float(float64(v))
+	case int:
+		w.int(int64(v))
+	case int8:
+		w.int(int64(v))
+	case int16:
+		w.int(int64(v))
+	case int32:
+		w.int(int64(v))
+	case int64:
+		w.int(v)
+	case uint:
+		w.head(0, uint64(v))
+	case uint8:
+		w.head(0, uint64(v))
+	case uint16:
+		w.head(0, uint64(v))
+	case uint32:
+		w.head(0, uint64(v))
+	case uint64:
+		w.head(0, v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			w.int(n)
+		} else if n, ok := new(big.Int).SetString(string(v), 10); ok {
+			w.bigInt(n)
+		} else if f, err := v.Float64(); err == nil {
+			w.float(f)
+		} else {
+			return fmt.Errorf("jsonmap: cbor: invalid number %s", v)
+		}
+	case *big.Int:
+		w.bigInt(v)
+	case time.Time:
+		if v.Nanosecond() == 0 {
+			w.head(6, 1)
+			w.int(v.Unix())
+		} else {
+			w.head(6, 0)
+			s := v.Format(time.RFC3339Nano)
+			w.head(3, uint64(len(s)))
+			w.buf = append(w.buf, s...)
+		}
+	case CBORTag:
+		w.head(6, v.Number)
+		return w.value(v.Content)
+	case CBORSimple:
+		if v >= 20 && v < 32 {
+			return fmt.Errorf("jsonmap: cbor: invalid simple value %d", v)
+		}
+		if v < 24 {
+			w.buf = append(w.buf, 0xe0|byte(v))
+		} else {
+			w.buf = append(w.buf, 0xf8, byte(v))
+		}
+	default:
+		value, err := convertJSON(v)
+		if err != nil {
+			return err
+		}
+		return w.value(value)
+	}
+	return nil
+}
+
+func (w *cborWriter) mapping(m *Map) error {
+	w.head(5, uint64(m.Len()))
+	if !w.Deterministic {
+		for el := m.First(); el != nil; el = el.Next() {
+			w.key(el.key)
+			if err := w.value(el.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// encode entries separately, and sort them by encoded keys
+	type entry struct {
+		data []byte
+		key  int // length of encoded key
+	}
+	entries := make([]entry, 0, m.Len())
+	buf := w.buf
+	for el := m.First(); el != nil; el = el.Next() {
+		w.buf = nil
+		w.key(el.key)
+		key := len(w.buf)
+		if err := w.value(el.value); err != nil {
+			return err
+		}
+		entries = append(entries, entry{w.buf, key})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].data[:entries[i].key], entries[j].data[:entries[j].key]) < 0
+	})
+	w.buf = buf
+	for _, e := range entries {
+		w.buf = append(w.buf, e.data...)
+	}
+	return nil
+}
+
+// bigInt writes integer, or bignum if it doesn't fit into 64 bits.
+func (w *cborWriter) bigInt(n *big.Int) {
+	major := byte(0)
+	if n.Sign() < 0 {
+		major = 1
+		n = new(big.Int).Not(n) // -1-n
+	}
+	if n.IsUint64() {
+		w.head(major, n.Uint64())
+		return
+	}
+	b := n.Bytes()
+	w.head(6, uint64(2+major))
+	w.head(2, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// float writes the float in the shortest form, which keeps the value.
+func (w *cborWriter) float(f float64) {
+	if math.IsNaN(f) {
+		w.buf = append(w.buf, 0xf9, 0x7e, 0x00)
+		return
+	}
+	f32 := float32(f)
+	if float64(f32) != f {
+		w.buf = append(w.buf, 0xfb)
+		w.buf = binary.BigEndian.AppendUint64(w.buf, math.Float64bits(f))
+		return
+	}
+	if h, ok := float16Bits(f32); ok {
+		w.buf = append(w.buf, 0xf9)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, h)
+		return
+	}
+	w.buf = append(w.buf, 0xfa)
+	w.buf = binary.BigEndian.AppendUint32(w.buf, math.Float32bits(f32))
+}
+
+// float16Bits returns half precision bits of the float, if it can be represented exactly.
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+	e := exp - 127
+	switch {
+	case exp == 0xff: // infinity, NaN is handled by caller
+		return sign | 0x7c00, mant == 0
+	case exp == 0 && mant == 0:
+		return sign, true
+	case e >= -14 && e <= 15: // normal
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), mant&0x1fff == 0
+	case e >= -24 && e < -14: // subnormal
+		full := 0x800000 | mant
+		shift := uint(-e - 1)
+		return sign | uint16(full>>shift), full&(1<<shift-1) == 0
+	}
+	return 0, false
+}
+
+// float16 returns value of half precision float.
+func float16(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		f = math.Inf(1)
+		if mant != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// UnmarshalCBOR parses CBOR map, and stores it in m, keeping order of keys.
+// Nested maps, including ones inside arrays and tags, are decoded as *Map, and arrays as []any.
+//
+// Integers are decoded as int64, or uint64 if they don't fit, and floats as float64.
+// Byte strings are decoded as []byte, undefined as nil, and indefinite-length items are joined.
+// Integer and byte string map keys are converted to strings.
+//
+// Tags decoded: date/time string (0) and epoch time (1) as time.Time in UTC, bignums (2, 3) as *big.Int,
+// self-described CBOR (55799) as its content. Other tags are decoded as CBORTag.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := jsonmap.UnmarshalCBOR(data, m)
+func UnmarshalCBOR(data []byte, m *Map) error {
+	r := &cborReader{data: data}
+	v, err := r.value()
+	if err != nil {
+		return err
+	}
+	top, ok := v.(*Map)
+	if !ok {
+		r.pos = 0
+		return r.errorf("expected map")
+	}
+	if r.pos < len(data) {
+		return r.errorf("unexpected data after top-level value")
+	}
+	for el := top.First(); el != nil; el = el.Next() {
+		m.Push(el.key, el.value)
+	}
+	return nil
+}
+
+// cborBreak is the stop code of indefinite-length items.
+type cborBreak struct{}
+
+type cborReader struct {
+	data  []byte
+	pos   int
+	depth int // nesting of arrays, maps and tags
+}
+
+// enter checks and increases nesting depth, before reading contents of array, map or tag.
+func (r *cborReader) enter() error {
+	if r.depth == maxDepth {
+		return r.errorf("exceeded max depth %d", maxDepth)
+	}
+	r.depth++
+	return nil
+}
+
+func (r *cborReader) errorf(format string, args ...any) error {
+	return &FormatError{Format: "cbor", Offset: int64(r.pos), Msg: fmt.Sprintf(format, args...)}
+}
+
+// next returns next n bytes.
+func (r *cborReader) next(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, r.errorf("unexpected end of input")
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// head reads major type and argument. Indefinite length is reported with ok false.
+func (r *cborReader) head() (major byte, n uint64, ok bool, err error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), true, nil
+	case info <= 27:
+		if b, err = r.next(1 << (info - 24)); err != nil {
+			return 0, 0, false, err
+		}
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return major, n, true, nil
+	case info == 31 && (major >= 2 && major <= 5 || major == 7):
+		return major, 0, false, nil
+	}
+	r.pos--
+	return 0, 0, false, r.errorf("invalid additional information %d", info)
+}
+
+// count checks that input has at least n*min more bytes, for n items.
+func (r *cborReader) count(n uint64, min uint64) (int, error) {
+	if n > uint64(len(r.data)-r.pos)/min {
+		return 0, r.errorf("unexpected end of input")
+	}
+	return int(n), nil
+}
+
+func (r *cborReader) value() (Value, error) {
+	start := r.pos
+	major, n, ok, err := r.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 1:
+		if n > math.MaxInt64 {
+			return new(big.Int).Not(new(big.Int).SetUint64(n)), nil
+		}
+		return -1 - int64(n), nil
+	case 2, 3:
+		b, err := r.str(major, n, ok)
+		if err != nil {
+			return nil, err
+		}
+		if major == 2 {
+			return b, nil
+		}
+		if !utf8.Valid(b) {
+			r.pos = start
+			return nil, r.errorf("invalid UTF-8 in text string")
+		}
+		return string(b), nil
+	case 4:
+		return r.array(n, ok)
+	case 5:
+		return r.mapping(n, ok)
+	case 6:
+		return r.tag(n, start)
+	}
+
+	// major 7
+	switch info := r.data[start] & 0x1f; {
+	case !ok:
+		return cborBreak{}, nil
+	case info == 25:
+		return float16(uint16(n)), nil
+	case info == 26:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case info == 27:
+		return math.Float64frombits(n), nil
+	case info == 24 && n < 32:
+		r.pos = start
+		return nil, r.errorf("invalid simple value %d", n)
+	case n == 20:
+		return false, nil
+	case n == 21:
+		return true, nil
+	case n == 22, n == 23: // null, undefined
+		return nil, nil
+	case n < 20 || n >= 32:
+		return CBORSimple(n), nil
+	}
+	r.pos = start
+	return nil, r.errorf("invalid simple value %d", n)
+}
+
+// str reads byte or text string, joining chunks of indefinite-length string.
+func (r *cborReader) str(major byte, n uint64, ok bool) ([]byte, error) {
+	if ok {
+		b, err := r.next(n)
+		return append([]byte{}, b...), err
+	}
+	b := []byte{}
+	for {
+		start := r.pos
+		m, n, ok, err := r.head()
+		if err != nil {
+			return nil, err
+		}
+		if m == 7 && !ok {
+			return b, nil
+		}
+		if m != major || !ok {
+			r.pos = start
+			return nil, r.errorf("invalid chunk of indefinite-length string")
+		}
+		chunk, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, chunk...)
+	}
+}
+
+// item reads array element or map key or value. Break is allowed in indefinite-length items only.
+func (r *cborReader) item(indefinite bool) (Value, bool, error) {
+	start := r.pos
+	v, err := r.value()
+	if err != nil {
+		return nil, false, err
+	}
+	if _, ok := v.(cborBreak); ok {
+		if !indefinite {
+			r.pos = start
+			return nil, false, r.errorf("unexpected break")
+		}
+		return nil, true, nil
+	}
+	return v, false, nil
+}
+
+func (r *cborReader) array(n uint64, ok bool) ([]any, error) {
+	size, err := r.count(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	a := make([]any, 0, size)
+	for i := 0; !ok || i < size; i++ {
+		v, done, err := r.item(!ok)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+		a = append(a, v)
+	}
+	r.depth--
+	return a, nil
+}
+
+func (r *cborReader) mapping(n uint64, ok bool) (*Map, error) {
+	size, err := r.count(n, 2)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	m := New()
+	for i := 0; !ok || i < size; i++ {
+		start := r.pos
+		k, done, err := r.item(!ok)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+		var key Key
+		switch k := k.(type) {
+		case string:
+			key = k
+		case []byte:
+			key = string(k)
+		case int64:
+			key = strconv.FormatInt(k, 10)
+		case uint64:
+			key = strconv.FormatUint(k, 10)
+		default:
+			r.pos = start
+			return nil, r.errorf("unsupported map key of type %T", k)
+		}
+		v, _, err := r.item(false)
+		if err != nil {
+			return nil, err
+		}
+		m.Push(key, v)
+	}
+	r.depth--
+	return m, nil
+}
+
+// tag reads content of the tag, and decodes known tags.
+func (r *cborReader) tag(number uint64, start int) (Value, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	content, _, err := r.item(false)
+	if err != nil {
+		return nil, err
+	}
+	r.depth--
+	fail := func() (Value, error) {
+		r.pos = start
+		return nil, r.errorf("invalid content of tag %d", number)
+	}
+	switch number {
+	case 0:
+		s, ok := content.(string)
+		if !ok {
+			return fail()
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return fail()
+		}
+		return t.UTC(), nil
+	case 1:
+		switch c := content.(type) {
+		case int64:
+			return time.Unix(c, 0).UTC(), nil
+		case float64:
+			if math.IsNaN(c) || math.IsInf(c, 0) {
+				return fail()
+			}
+			sec := math.Floor(c)
+			return time.Unix(int64(sec), int64(math.Round((c-sec)*1e9))).UTC(), nil
+		}
+		return fail()
+	case 2, 3:
+		b, ok := content.([]byte)
+		if !ok {
+			return fail()
+		}
+		n := new(big.Int).SetBytes(b)
+		if number == 3 {
+			n.Not(n)
+		}
+		return n, nil
+	case 55799:
+		return content, nil
+	}
+	return CBORTag{Number: number, Content: content}, nil
+}
@@ -0,0 +1,307 @@
+// jsonmap-merge is a git merge driver for JSON files, which merges objects key by key,
+// keeping order of keys. It writes conflict markers only around values changed by both sides.
+// Files that are not JSON objects are merged by "git merge-file" as text.
+//
+// Setup in .git/config (or ~/.gitconfig):
+//
+//	[merge "jsonmap"]
+//		name = ordered JSON merge
+//		driver = jsonmap-merge %O %A %B
+//
+// and in .gitattributes:
+//
+//	*.json merge=jsonmap
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/metalim/jsonmap"
+)
+
+func main() {
+	if len(os.Args) != 4 {
+		fmt.Fprintln(os.Stderr, "usage: jsonmap-merge <base> <ours> <theirs>")
+		os.Exit(2)
+	}
+	os.Exit(run(os.Args[1], os.Args[2], os.Args[3]))
+}
+
+// run merges files and writes result into ours, as git expects.
+// Returns 0 for clean merge, 1 for conflicts and 2 for errors.
+func run(baseFile, oursFile, theirsFile string) int {
+	var maps [3]*jsonmap.Map
+	var oursData []byte
+	for i, name := range []string{baseFile, oursFile, theirsFile} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if i == 1 {
+			oursData = data
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			maps[i] = jsonmap.New() // added or deleted file
+			continue
+		}
+		if maps[i], err = decode(data); err != nil {
+			return mergeText(baseFile, oursFile, theirsFile)
+		}
+	}
+
+	merged, conflicts := jsonmap.Merge3(maps[0], maps[1], maps[2])
+	w := writer{
+		indent:    detectIndent(oursData),
+		conflicts: conflicts,
+		byPath:    make(map[string]jsonmap.Conflict, len(conflicts)),
+	}
+	for _, c := range conflicts {
+		w.byPath[c.Path.String()] = c
+	}
+	w.writeMap(nil, merged, "", true)
+	w.buf.WriteByte('\n')
+
+	if err := os.WriteFile(oursFile, w.buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(conflicts) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// decode parses JSON object, keeping numbers as json.Number, so they are written back as is.
+func decode(data []byte) (*jsonmap.Map, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, errors.New("not a JSON object")
+	}
+	m := jsonmap.New()
+	if err := decodeMap(d, m); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON object")
+	}
+	return m, nil
+}
+
+func decodeMap(d *json.Decoder, m *jsonmap.Map) error {
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		v, err := decodeValue(d)
+		if err != nil {
+			return err
+		}
+		m.Push(tok.(string), v)
+	}
+	_, err := d.Token() // '}'
+	return err
+}
+
+func decodeValue(d *json.Decoder) (any, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := jsonmap.New()
+		return m, decodeMap(d, m)
+	case json.Delim('['):
+		a := make([]any, 0)
+		for d.More() {
+			v, err := decodeValue(d)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err := d.Token() // ']'
+		return a, err
+	}
+	return tok, nil
+}
+
+// mergeText falls back to line-based merge with conflict markers.
+func mergeText(baseFile, oursFile, theirsFile string) int {
+	cmd := exec.Command("git", "merge-file", "-L", "ours", "-L", "base", "-L", "theirs", oursFile, baseFile, theirsFile)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// detectIndent returns indentation of the first indented line, or two spaces.
+func detectIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && len(trimmed) < len(line) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+type writer struct {
+	buf       bytes.Buffer
+	indent    string
+	conflicts []jsonmap.Conflict
+	byPath    map[string]jsonmap.Conflict
+}
+
+// entry is a key of the merged map, or a conflict.
+type entry struct {
+	path     jsonmap.Path
+	key      string
+	value    any
+	conflict *jsonmap.Conflict
+}
+
+// has reports whether the entry is kept when conflicts are resolved to ours or theirs.
+func (e entry) has(ours bool) bool {
+	if e.conflict == nil {
+		return true
+	}
+	if ours {
+		return e.conflict.HasOurs
+	}
+	return e.conflict.HasTheirs
+}
+
+func (w *writer) writeMap(path jsonmap.Path, m *jsonmap.Map, prefix string, allowConflicts bool) {
+	var entries []entry
+	for el := m.First(); el != nil; el = el.Next() {
+		p := path.Append(el.Key())
+		if c, ok := w.byPath[p.String()]; ok && allowConflicts {
+			entries = append(entries, entry{conflict: &c})
+			continue
+		}
+		entries = append(entries, entry{path: p, key: el.Key(), value: el.Value()})
+	}
+	// keys deleted by ours, but changed by theirs, are not in the merged map
+	if allowConflicts {
+		for i, c := range w.conflicts {
+			if !c.HasOurs && len(c.Path) == len(path)+1 && c.Path[:len(path)].String() == path.String() {
+				entries = append(entries, entry{conflict: &w.conflicts[i]})
+			}
+		}
+	}
+	if len(entries) == 0 {
+		w.buf.WriteString("{}")
+		return
+	}
+
+	// Entries up to the last one present on both sides end with comma.
+	// Entries after it, which exist only on one side, start with comma instead,
+	// so either side of the conflicts gives valid JSON.
+	last := -1
+	for i, e := range entries {
+		if e.has(true) && e.has(false) {
+			last = i
+		}
+	}
+	w.buf.WriteString("{\n")
+	inner := prefix + w.indent
+	for i, e := range entries {
+		if e.conflict == nil {
+			w.writeEntry(e.path, e.key, e.value, inner, false, i < last, allowConflicts)
+			continue
+		}
+		var lead [2]bool // ours, theirs
+		if i > last {
+			for j := 0; j < i; j++ {
+				lead[0] = lead[0] || entries[j].has(true)
+				lead[1] = lead[1] || entries[j].has(false)
+			}
+		}
+		w.writeConflict(*e.conflict, inner, lead, i < last)
+	}
+	w.buf.WriteString(prefix)
+	w.buf.WriteByte('}')
+}
+
+func (w *writer) writeConflict(c jsonmap.Conflict, prefix string, lead [2]bool, comma bool) {
+	key := c.Path[len(c.Path)-1].(string)
+	w.buf.WriteString("<<<<<<< ours\n")
+	if c.HasOurs {
+		w.writeEntry(c.Path, key, c.Ours, prefix, lead[0], comma, false)
+	}
+	w.buf.WriteString("=======\n")
+	if c.HasTheirs {
+		w.writeEntry(c.Path, key, c.Theirs, prefix, lead[1], comma, false)
+	}
+	w.buf.WriteString(">>>>>>> theirs\n")
+}
+
+// writeEntry writes "key": value line, with comma before the key if lead is set, or after the value if comma is set.
+func (w *writer) writeEntry(path jsonmap.Path, key string, value any, prefix string, lead, comma, allowConflicts bool) {
+	w.buf.WriteString(prefix)
+	if lead {
+		w.buf.WriteString(", ")
+	}
+	w.writeScalar(key)
+	w.buf.WriteString(": ")
+	w.writeValue(path, value, prefix, allowConflicts)
+	if comma {
+		w.buf.WriteByte(',')
+	}
+	w.buf.WriteByte('\n')
+}
+
+func (w *writer) writeValue(path jsonmap.Path, value any, prefix string, allowConflicts bool) {
+	switch v := value.(type) {
+	case *jsonmap.Map:
+		w.writeMap(path, v, prefix, allowConflicts)
+	case []any:
+		if len(v) == 0 {
+			w.buf.WriteString("[]")
+			return
+		}
+		w.buf.WriteString("[\n")
+		inner := prefix + w.indent
+		for i, item := range v {
+			w.buf.WriteString(inner)
+			w.writeValue(path.Append(i), item, inner, allowConflicts)
+			if i < len(v)-1 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteByte('\n')
+		}
+		w.buf.WriteString(prefix)
+		w.buf.WriteByte(']')
+	default:
+		w.writeScalar(v)
+	}
+}
+
+func (w *writer) writeScalar(v any) {
+	enc := json.NewEncoder(&w.buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		panic(err) // values come from decoded JSON
+	}
+	w.buf.Truncate(w.buf.Len() - 1) // newline added by Encode
+}
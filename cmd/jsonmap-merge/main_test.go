@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zeebo/assert"
+)
+
+// merge runs the driver on temporary files, and returns exit code and merged text.
+func merge(t *testing.T, base, ours, theirs string) (int, string) {
+	t.Helper()
+	dir := t.TempDir()
+	var names []string
+	for i, data := range []string{base, ours, theirs} {
+		name := filepath.Join(dir, []string{"base", "ours", "theirs"}[i])
+		assert.NoError(t, os.WriteFile(name, []byte(data), 0o644))
+		names = append(names, name)
+	}
+	code := run(names[0], names[1], names[2])
+	out, err := os.ReadFile(names[1])
+	assert.NoError(t, err)
+	return code, string(out)
+}
+
+// resolve picks one side of every conflict.
+func resolve(text string, ours bool) string {
+	var b strings.Builder
+	keep := true
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch line {
+		case "<<<<<<< ours\n":
+			keep = ours
+		case "=======\n":
+			keep = !ours
+		case ">>>>>>> theirs\n":
+			keep = true
+		default:
+			if keep {
+				b.WriteString(line)
+			}
+		}
+	}
+	return b.String()
+}
+
+func TestRunKeepsNumbers(t *testing.T) {
+	code, out := merge(t,
+		`{"id": 12345678901234567890, "price": 1.10, "a": 1}`,
+		`{"id": 12345678901234567890, "price": 1.10, "a": 2}`,
+		`{"id": 12345678901234567890, "price": 1.10, "a": 1, "b": 1e2}`)
+	assert.Equal(t, code, 0)
+	assert.Equal(t, out, "{\n  \"id\": 12345678901234567890,\n  \"price\": 1.10,\n  \"a\": 2,\n  \"b\": 1e2\n}\n")
+}
+
+func TestRunConflicts(t *testing.T) {
+	code, out := merge(t,
+		"{\n\t\"a\": 1,\n\t\"b\": 1,\n\t\"c\": 1\n}\n",
+		"{\n\t\"a\": 2,\n\t\"b\": 2\n}\n",
+		"{\n\t\"a\": 3,\n\t\"c\": 3\n}\n")
+	assert.Equal(t, code, 1)
+	assert.Equal(t, out, "{\n"+
+		"<<<<<<< ours\n\t\"a\": 2\n=======\n\t\"a\": 3\n>>>>>>> theirs\n"+
+		"<<<<<<< ours\n\t, \"b\": 2\n=======\n>>>>>>> theirs\n"+
+		"<<<<<<< ours\n=======\n\t, \"c\": 3\n>>>>>>> theirs\n"+
+		"}\n")
+
+	for _, ours := range []bool{true, false} {
+		var v any
+		assert.NoError(t, json.Unmarshal([]byte(resolve(out, ours)), &v))
+	}
+}
+
+func TestRunConflictsValid(t *testing.T) {
+	// every side of conflicts gives valid JSON, wherever deleted keys are
+	for _, c := range [][3]string{
+		{`{"a":1,"b":1}`, `{"a":2}`, `{"a":1,"b":2}`},
+		{`{"a":1,"b":1}`, `{"a":1,"b":2}`, `{"a":1}`},
+		{`{"a":1,"b":1}`, `{"b":2}`, `{"a":2,"b":1}`},
+		{`{"a":1,"b":1,"c":1}`, `{"b":2,"c":1}`, `{"a":2,"c":1}`},
+		{`{"a":1}`, `{}`, `{"a":2}`},
+		{`{"x":{"a":1,"b":1}}`, `{"x":{"a":1}}`, `{"x":{"a":1,"b":2},"y":1}`},
+	} {
+		code, out := merge(t, c[0], c[1], c[2])
+		assert.Equal(t, code, 1)
+		for _, ours := range []bool{true, false} {
+			var v any
+			assert.NoError(t, json.Unmarshal([]byte(resolve(out, ours)), &v))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package jsonmap
+
+import "encoding/json"
+
+// normalizeValue converts values of types other than JSON types and Go numbers through JSON.
+func normalizeValue(v Value) (Value, error) {
+	switch v.(type) {
+	case nil, string, bool, json.Number, *Map, []any, float64, float32,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v, nil
+	}
+	return convertJSON(v)
+}
+
+// convertJSON converts value through JSON, with objects decoded as *Map.
+// Used by encoders for types they don't write directly, like structs.
+func convertJSON(v Value) (Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalValue(data)
+}
+
+// formatText formats value as text: strings as is, nil as empty string, and other values in JSON format.
+func formatText(v Value) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		err = json.Unmarshal(data, &s)
+		return s, err
+	}
+	return string(data), nil
+}
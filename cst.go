@@ -0,0 +1,434 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// UnmarshalPreserve is same as UnmarshalJSONC, but remembers formatting of the document in the map and its elements:
+// whitespace, comments, trailing commas, and raw text of keys, strings and numbers.
+// MarshalPreserve writes the document back byte-for-byte, except for the parts changed through the Map API.
+//
+// Useful for tools, which edit human-maintained files, like package manifests or settings.
+//
+//	m := jsonmap.New()
+//	err := jsonmap.UnmarshalPreserve(data, m)
+//	m.Set("version", "1.2.4")
+//	m.InsertAfter("license", "MIT", m.GetElement("version"))
+//	data, err = jsonmap.MarshalPreserve(m)
+func UnmarshalPreserve(data []byte, m *Map) error {
+	data = append([]byte(nil), data...) // raw parts are kept, and data can be reused by caller
+	p := &cstParser{}
+	p.s = scanner{buf: data, dialect: dialectJSONC, fn: func(e *Event) error {
+		p.scalar = e.Value
+		return nil
+	}}
+	lead := p.space()
+	if p.s.peek() != '{' {
+		return p.s.errorf("expected '{'")
+	}
+	if err := p.object(m); err != nil {
+		return err
+	}
+	tail := p.space()
+	if err := p.s.checkEnd(); err != nil {
+		return err
+	}
+	m.cst.lead = lead
+	m.cst.tail = tail
+	m.cst.unit = p.unit
+	return nil
+}
+
+// MarshalPreserve encodes the map as JSON, keeping formatting remembered by UnmarshalPreserve.
+//
+// Untouched parts are written as they were. New elements get indentation and separators of their neighbours,
+// and a comma is added or removed, when an element becomes or stops being the last one.
+// Comments before an element and on its line are removed together with it.
+// Changed arrays are written again, keeping formatting of objects inside them.
+// Maps, which were not parsed with UnmarshalPreserve, are indented like their parent.
+//
+//	data, err := jsonmap.MarshalPreserve(m)
+func MarshalPreserve(m *Map) ([]byte, error) {
+	w := &cstWriter{unit: "  "}
+	if m.cst != nil {
+		if m.cst.unit != "" {
+			w.unit = m.cst.unit
+		}
+		w.buf = append(w.buf, m.cst.lead...)
+	}
+	if err := w.object(m, "", true); err != nil {
+		return nil, err
+	}
+	if m.cst != nil {
+		w.buf = append(w.buf, m.cst.tail...)
+	}
+	return w.buf, nil
+}
+
+// mapTrivia is formatting of a parsed object.
+type mapTrivia struct {
+	lead, tail    []byte // around the top-level object
+	unit          string // indentation unit, for the top-level object only
+	open          []byte // after '{' to the end of its line
+	end           []byte // before '}', after the line of the last element
+	empty         bool   // object was parsed empty, end has its content
+	trailingComma bool
+	multiline     bool
+	indent        []byte // indentation of elements
+	colon         []byte // separator between keys and values
+	space         []byte // separator between elements of single-line object
+}
+
+// elemTrivia is formatting of a parsed object member.
+type elemTrivia struct {
+	key     Key    // parsed key, to detect renames
+	rawKey  []byte // quoted key
+	before  []byte // whitespace and comments before the key
+	colon   []byte // from the end of key to the start of value
+	raw     []byte // raw value, nil for objects
+	orig    Value  // value decoded from raw
+	after   []byte // between value and comma
+	comment []byte // after comma to the end of line
+}
+
+type cstParser struct {
+	s      scanner
+	scalar Value // last scalar reported by scanner
+	unit   string
+}
+
+// space skips whitespace and comments, and returns them.
+func (p *cstParser) space() []byte {
+	start := p.s.pos
+	p.s.skipSpace()
+	return p.s.buf[start:p.s.pos:p.s.pos]
+}
+
+// enter checks and increases nesting depth, at the opening bracket of object or array.
+func (p *cstParser) enter() error {
+	if p.s.depth == maxDepth {
+		return p.s.errorf("exceeded max depth %d", maxDepth)
+	}
+	p.s.depth++
+	return nil
+}
+
+// object parses an object at current position into m.
+func (p *cstParser) object(m *Map) error {
+	s := &p.s
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer func() { s.depth-- }()
+	s.pos++ // '{'
+	t := &mapTrivia{}
+	m.cst = t
+	ws := p.space()
+	if s.peek() == '}' {
+		s.pos++
+		t.empty = true
+		t.end = ws
+		return nil
+	}
+	var before []byte
+	t.open, before = splitLine(ws)
+	var elems []*elemTrivia
+	for {
+		start := s.pos
+		key, err := s.key()
+		if err != nil {
+			return err
+		}
+		e := &elemTrivia{key: string(key), rawKey: s.buf[start:s.pos:s.pos], before: before}
+		start = s.pos
+		p.space()
+		if s.peek() != ':' {
+			return s.errorf("expected ':' after object key")
+		}
+		s.pos++
+		p.space()
+		e.colon = s.buf[start:s.pos:s.pos]
+		value, raw, err := p.value()
+		if err != nil {
+			return err
+		}
+		if raw != nil {
+			e.raw = raw
+			e.orig = cloneValue(value)
+		}
+		m.Push(e.key, value)
+		m.last.cst = e
+		elems = append(elems, e)
+
+		ws := p.space()
+		switch s.peek() {
+		case ',':
+			s.pos++
+			e.after = ws
+			ws = p.space()
+			if s.peek() != '}' {
+				e.comment, before = splitLine(ws)
+				continue
+			}
+			t.trailingComma = true
+		case '}':
+		default:
+			return s.errorf("expected ',' or '}' after object value")
+		}
+		s.pos++
+		e.comment, t.end = splitLine(ws)
+		p.layout(t, elems)
+		return nil
+	}
+}
+
+// value parses a value at current position. Returns raw text of arrays and scalars.
+func (p *cstParser) value() (v Value, raw []byte, err error) {
+	s := &p.s
+	start := s.pos
+	switch s.peek() {
+	case '{':
+		m := New()
+		return m, nil, p.object(m)
+	case '[':
+		if err := p.enter(); err != nil {
+			return nil, nil, err
+		}
+		defer func() { s.depth-- }()
+		s.pos++
+		a := make([]any, 0)
+		p.space()
+		if s.peek() == ']' {
+			s.pos++
+			return a, s.buf[start:s.pos:s.pos], nil
+		}
+		for {
+			item, _, err := p.value()
+			if err != nil {
+				return nil, nil, err
+			}
+			a = append(a, item)
+			p.space()
+			switch s.peek() {
+			case ',':
+				s.pos++
+				if !s.trailingComma(']') {
+					p.space()
+					continue
+				}
+			case ']':
+				s.pos++
+			default:
+				return nil, nil, s.errorf("expected ',' or ']' after array element")
+			}
+			return a, s.buf[start:s.pos:s.pos], nil
+		}
+	}
+	if err := s.value(); err != nil {
+		return nil, nil, err
+	}
+	return p.scalar, s.buf[start:s.pos:s.pos], nil
+}
+
+// layout detects formatting for new elements from parsed ones.
+func (p *cstParser) layout(t *mapTrivia, elems []*elemTrivia) {
+	t.multiline = bytes.IndexByte(t.open, '\n') >= 0 || bytes.IndexByte(t.end, '\n') >= 0
+	for _, e := range elems {
+		if bytes.IndexByte(e.before, '\n') >= 0 || bytes.IndexByte(e.comment, '\n') >= 0 {
+			t.multiline = true
+		}
+	}
+	t.indent = lineIndent(elems[0].before)
+	t.colon = []byte(": ")
+	if isBlank(bytes.Replace(elems[0].colon, []byte(":"), nil, 1)) {
+		t.colon = elems[0].colon
+	}
+	t.space = []byte(" ")
+	if len(elems) > 1 && isBlank(elems[1].before) {
+		t.space = elems[1].before
+	}
+	if t.multiline && p.unit == "" {
+		closing := lineIndent(t.end)
+		if len(t.indent) > len(closing) && bytes.HasPrefix(t.indent, closing) {
+			p.unit = string(t.indent[len(closing):])
+		}
+	}
+}
+
+// splitLine splits whitespace and comments after the end of the first line.
+func splitLine(b []byte) (line, rest []byte) {
+	for i := 0; i < len(b); i++ {
+		if b[i] == '\n' {
+			return b[:i+1], b[i+1:]
+		}
+		if b[i] != '/' || i+1 == len(b) {
+			continue
+		}
+		switch b[i+1] {
+		case '/':
+			if j := bytes.IndexByte(b[i:], '\n'); j >= 0 {
+				return b[:i+j+1], b[i+j+1:]
+			}
+			return nil, b
+		case '*':
+			j := bytes.Index(b[i+2:], []byte("*/"))
+			if j < 0 {
+				return nil, b
+			}
+			i += j + 3
+		}
+	}
+	return nil, b
+}
+
+// lineIndent returns leading whitespace of the last line.
+func lineIndent(b []byte) []byte {
+	if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+		b = b[i+1:]
+	}
+	n := 0
+	for n < len(b) && (b[n] == ' ' || b[n] == '\t') {
+		n++
+	}
+	return b[:n]
+}
+
+func isBlank(b []byte) bool {
+	return len(lineIndent(b)) == len(b) && bytes.IndexByte(b, '\n') < 0
+}
+
+type cstWriter struct {
+	buf  []byte
+	unit string
+}
+
+// newline starts a new line, if output is not at the start of a line already.
+func (w *cstWriter) newline() {
+	if len(w.buf) > 0 && w.buf[len(w.buf)-1] != '\n' {
+		w.buf = append(w.buf, '\n')
+	}
+}
+
+// object writes the map. Indent is indentation of the line where the object starts.
+// Maps without formatting are indented as children of their parent, or written in a single line.
+func (w *cstWriter) object(m *Map, indent string, multiline bool) error {
+	t := m.cst
+	if t != nil && t.empty && m.Len() == 0 {
+		w.buf = append(w.buf, '{')
+		w.buf = append(w.buf, t.end...)
+		w.buf = append(w.buf, '}')
+		return nil
+	}
+	if m.Len() == 0 {
+		w.buf = append(w.buf, '{', '}')
+		return nil
+	}
+	generated := t == nil || t.empty
+	if generated {
+		t = &mapTrivia{multiline: multiline, indent: []byte(indent + w.unit), colon: []byte(": "), space: []byte(" ")}
+	}
+
+	w.buf = append(w.buf, '{')
+	w.buf = append(w.buf, t.open...)
+	for el := m.first; el != nil; el = el.next {
+		e := el.cst
+		if e == nil {
+			e = &elemTrivia{colon: t.colon}
+			switch {
+			case t.multiline:
+				w.newline()
+				e.before = t.indent
+				e.comment = []byte{'\n'}
+			case el != m.first:
+				e.before = t.space
+			}
+		} else if !t.multiline && el != m.first && len(e.before) == 0 {
+			e.before = t.space // moved from the first position
+		}
+
+		w.buf = append(w.buf, e.before...)
+		if el.cst != nil && e.key == el.key {
+			w.buf = append(w.buf, e.rawKey...)
+		} else {
+			key, err := json.Marshal(el.key)
+			if err != nil {
+				return err
+			}
+			w.buf = append(w.buf, key...)
+		}
+		w.buf = append(w.buf, e.colon...)
+		if err := w.value(el.value, e.raw, e.orig, string(t.indent), t.multiline); err != nil {
+			return err
+		}
+		w.buf = append(w.buf, e.after...)
+		if el.next != nil || t.trailingComma {
+			w.buf = append(w.buf, ',')
+		}
+		w.buf = append(w.buf, e.comment...)
+	}
+	if generated && t.multiline {
+		w.newline()
+		w.buf = append(w.buf, indent...)
+	} else {
+		w.buf = append(w.buf, t.end...)
+	}
+	w.buf = append(w.buf, '}')
+	return nil
+}
+
+// value writes the value, or its raw text if it was not changed.
+func (w *cstWriter) value(v Value, raw []byte, orig Value, indent string, multiline bool) error {
+	switch v := v.(type) {
+	case *Map:
+		return w.object(v, indent, multiline)
+	case []any:
+		if raw != nil && equal(v, orig) {
+			w.buf = append(w.buf, raw...)
+			return nil
+		}
+		if raw != nil {
+			multiline = bytes.IndexByte(raw, '\n') >= 0
+		}
+		return w.array(v, indent, multiline)
+	}
+	if raw != nil && equal(v, orig) {
+		w.buf = append(w.buf, raw...)
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.buf = append(w.buf, data...)
+	return nil
+}
+
+func (w *cstWriter) array(a []any, indent string, multiline bool) error {
+	if len(a) == 0 {
+		w.buf = append(w.buf, '[', ']')
+		return nil
+	}
+	w.buf = append(w.buf, '[')
+	for i, item := range a {
+		if i > 0 {
+			w.buf = append(w.buf, ',')
+			if !multiline {
+				w.buf = append(w.buf, ' ')
+			}
+		}
+		if multiline {
+			w.buf = append(w.buf, '\n')
+			w.buf = append(w.buf, indent+w.unit...)
+		}
+		if err := w.value(item, nil, nil, indent+w.unit, multiline); err != nil {
+			return err
+		}
+	}
+	if multiline {
+		w.buf = append(w.buf, '\n')
+		w.buf = append(w.buf, indent...)
+	}
+	w.buf = append(w.buf, ']')
+	return nil
+}
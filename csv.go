@@ -0,0 +1,166 @@
+package jsonmap
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CSVOptions configures WriteCSV and ReadCSV. Zero value is usable, and writes and reads comma-separated values as is.
+//
+//	opts := jsonmap.CSVOptions{Comma: '\t', Flatten: true, Infer: true}
+type CSVOptions struct {
+	// Comma is field delimiter, like '\t' for TSV. Default is ','.
+	Comma rune
+
+	// Flatten makes WriteCSV write nested maps and arrays as separate columns with joined keys, like "a.b.0",
+	// and ReadCSV rebuild them back. Otherwise nested values are written as JSON.
+	Flatten bool
+
+	// Separator of flattened keys. Default is ".".
+	Separator string
+
+	// Infer makes ReadCSV read "true" and "false" as booleans, numbers in JSON format as float64,
+	// and empty cells as nil. Otherwise all cells are read as strings.
+	// Numbers with leading zeros, like "01234", stay strings.
+	Infer bool
+}
+
+func (o CSVOptions) flattener() Flattener {
+	sep := o.Separator
+	if sep == "" {
+		sep = "."
+	}
+	return Flattener{Separator: sep, Arrays: true}
+}
+
+// WriteCSV writes rows as CSV with header. Columns are union of keys of all rows in order of first appearance.
+// Missing keys and nil values are written as empty cells, strings as is, and other values in JSON format.
+//
+//	err := jsonmap.WriteCSV(w, rows, jsonmap.CSVOptions{Flatten: true})
+func WriteCSV(w io.Writer, rows []*Map, opts CSVOptions) error {
+	if opts.Flatten {
+		f := opts.flattener()
+		flat := make([]*Map, len(rows))
+		for i, row := range rows {
+			flat[i] = f.Flatten(row)
+		}
+		rows = flat
+	}
+
+	var columns []Key
+	index := map[Key]int{}
+	for _, row := range rows {
+		for el := row.First(); el != nil; el = el.Next() {
+			if _, ok := index[el.key]; !ok {
+				index[el.key] = len(columns)
+				columns = append(columns, el.key)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i := range record {
+			record[i] = ""
+		}
+		for el := row.First(); el != nil; el = el.Next() {
+			text, err := formatText(el.value)
+			if err != nil {
+				return fmt.Errorf("jsonmap: csv: column %q: %w", el.key, err)
+			}
+			record[index[el.key]] = text
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadCSV reads CSV with header, and returns a map per row, with keys in order of columns.
+// All cells are read as strings. Shortcut for CSVOptions{}.ReadCSV(r).
+//
+//	rows, err := jsonmap.ReadCSV(r)
+func ReadCSV(r io.Reader) ([]*Map, error) {
+	return CSVOptions{}.ReadCSV(r)
+}
+
+var csvNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// ReadCSV reads CSV with header, and returns a map per row, with keys in order of columns.
+// Byte order mark before header is skipped.
+//
+//	rows, err := opts.ReadCSV(r)
+func (o CSVOptions) ReadCSV(r io.Reader) ([]*Map, error) {
+	cr := csv.NewReader(r)
+	if o.Comma != 0 {
+		cr.Comma = o.Comma
+	}
+	columns, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns[0] = strings.TrimPrefix(columns[0], "\uFEFF")
+	seen := map[string]bool{}
+	for _, column := range columns {
+		if seen[column] {
+			return nil, fmt.Errorf("jsonmap: csv: duplicate column %q", column)
+		}
+		seen[column] = true
+	}
+
+	var rows []*Map
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := New()
+		for i, cell := range record {
+			row.Set(columns[i], o.cellValue(cell))
+		}
+		if o.Flatten {
+			if row, err = o.flattener().Unflatten(row); err != nil {
+				return nil, err
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func (o CSVOptions) cellValue(cell string) Value {
+	if !o.Infer {
+		return cell
+	}
+	switch {
+	case cell == "":
+		return nil
+	case cell == "true":
+		return true
+	case cell == "false":
+		return false
+	case csvNumber.MatchString(cell):
+		if f, err := strconv.ParseFloat(cell, 64); err == nil {
+			return f
+		}
+	}
+	return cell
+}
@@ -0,0 +1,136 @@
+package jsonmap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decoder reads a stream of JSON objects into maps, one at a time, keeping order of keys.
+// Supports newline-delimited JSON (NDJSON), JSON text sequences (RFC 7464, each record prefixed with RS),
+// and JSON objects simply concatenated or separated by whitespace.
+//
+//	d := jsonmap.NewDecoder(r)
+//	for d.More() {
+//	    m := jsonmap.New()
+//	    if err := d.Decode(m); err != nil {
+//	        return err
+//	    }
+//	    fmt.Println(m)
+//	}
+type Decoder struct {
+	dec    *json.Decoder
+	ctx    context.Context
+	record int
+}
+
+// NewDecoder returns a new decoder that reads from r.
+// The decoder buffers input, and may read data from r beyond the requested objects.
+//
+//	d := jsonmap.NewDecoder(os.Stdin)
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderContext(context.Background(), r)
+}
+
+// NewDecoderContext is same as NewDecoder, but stops reading when ctx is canceled.
+// Context is checked before each read from r, so a read blocked in r is not interrupted:
+// close r to unblock it, as http.Client does with response body on cancellation.
+//
+//	d := jsonmap.NewDecoderContext(ctx, resp.Body)
+func NewDecoderContext(ctx context.Context, r io.Reader) *Decoder {
+	return &Decoder{
+		dec: json.NewDecoder(&seqReader{ctx: ctx, r: r}),
+		ctx: ctx,
+	}
+}
+
+// More reports whether there is another object in the input stream.
+// Returns false at the end of input, or if the context was canceled.
+func (d *Decoder) More() bool {
+	return d.ctx.Err() == nil && d.dec.More()
+}
+
+// Record returns number of records read so far, including the failed one.
+func (d *Decoder) Record() int {
+	return d.record
+}
+
+// Decode reads the next JSON object from input into m.
+// Returns io.EOF at the end of input. Other errors are wrapped in *DecodeError with the record number.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before decoding.
+//
+//	err := d.Decode(m)
+func (d *Decoder) Decode(m *Map) error {
+	if err := d.ctx.Err(); err != nil {
+		return &DecodeError{Record: d.record + 1, Offset: d.dec.InputOffset(), Err: err}
+	}
+	tok, err := d.dec.Token()
+	if err == io.EOF {
+		return err
+	}
+	d.record++
+	if err == nil && tok != json.Delim('{') {
+		err = errors.New("expected '{'")
+	}
+	if err == nil {
+		err = decodeMap(d.dec, m)
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if ctxErr := d.ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return &DecodeError{Record: d.record, Offset: d.dec.InputOffset(), Err: err}
+	}
+	return nil
+}
+
+// DecodeError is returned by Decoder, to report the record with error.
+type DecodeError struct {
+	Record int   // 1-based number of the record
+	Offset int64 // input offset after the error
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("record %d (offset %d): %v", e.Record, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// seqReader replaces record separators of JSON text sequences (RFC 7464) with spaces,
+// and checks for context cancellation before each read.
+// RS inside strings is kept, to be rejected as invalid control character.
+type seqReader struct {
+	ctx      context.Context
+	r        io.Reader
+	inString bool
+	escaped  bool // previous byte in string was backslash
+}
+
+func (s *seqReader) Read(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := s.r.Read(p)
+	for i, c := range p[:n] {
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case s.inString && c == '\\':
+			s.escaped = true
+		case c == '"':
+			s.inString = !s.inString
+		case c == 0x1E && !s.inString:
+			p[i] = ' '
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,247 @@
+package jsonmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind is the kind of change reported by Diff.
+type ChangeKind int
+
+const (
+	Added   ChangeKind = iota // value exists only in the new map
+	Removed                   // value exists only in the old map
+	Changed                   // value differs between maps
+	Moved                     // key exists in both maps, but at a different position
+)
+
+var changeKindNames = [...]string{
+	Added:   "added",
+	Removed: "removed",
+	Changed: "changed",
+	Moved:   "moved",
+}
+
+// String returns the name of the change kind.
+func (k ChangeKind) String() string {
+	if int(k) < len(changeKindNames) {
+		return changeKindNames[k]
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a single difference between two maps, returned by Diff.
+//
+// Old is set for Removed and Changed, New is set for Added and Changed.
+// From and To are positions of the key in the old and new map, set for Moved only.
+type Change struct {
+	Kind     ChangeKind
+	Path     Path
+	Old, New Value
+	From, To int
+}
+
+// Diff returns the differences between maps a and b, walking nested maps and arrays.
+// Changes are reported in document order of b, with removed keys reported before the rest of their map.
+//
+// Besides added, removed and changed values, Diff reports reordering of keys:
+// the minimal set of keys to move is computed via the longest common subsequence of keys.
+// Arrays are compared element by element.
+// O(n*m) time for each pair of nested maps with n and m keys.
+//
+//	for _, c := range jsonmap.Diff(a, b) {
+//		fmt.Println(c.Kind, c.Path)
+//	}
+func Diff(a, b *Map) []Change {
+	var changes []Change
+	return diffMap(changes, nil, a, b)
+}
+
+func diffMap(changes []Change, path Path, a, b *Map) []Change {
+	for el := a.First(); el != nil; el = el.Next() {
+		if _, ok := b.elements[el.key]; !ok {
+			changes = append(changes, Change{Kind: Removed, Path: path.Append(el.key), Old: el.value})
+		}
+	}
+
+	// keys present in both maps, in order of each map
+	var commonA, commonB []Key
+	for el := a.First(); el != nil; el = el.Next() {
+		if _, ok := b.elements[el.key]; ok {
+			commonA = append(commonA, el.key)
+		}
+	}
+	for el := b.First(); el != nil; el = el.Next() {
+		if _, ok := a.elements[el.key]; ok {
+			commonB = append(commonB, el.key)
+		}
+	}
+	stay := lcs(commonA, commonB)
+
+	for el := b.First(); el != nil; el = el.Next() {
+		p := path.Append(el.key)
+		old, ok := a.elements[el.key]
+		if !ok {
+			changes = append(changes, Change{Kind: Added, Path: p, New: el.value})
+			continue
+		}
+		if !stay[el.key] {
+			changes = append(changes, Change{Kind: Moved, Path: p, From: a.KeyIndex(el.key), To: b.KeyIndex(el.key)})
+		}
+		changes = diffValue(changes, p, old.value, el.value)
+	}
+	return changes
+}
+
+func diffArray(changes []Change, path Path, a, b []any) []Change {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		changes = diffValue(changes, path.Append(i), a[i], b[i])
+	}
+	for i := len(b); i < len(a); i++ {
+		changes = append(changes, Change{Kind: Removed, Path: path.Append(i), Old: a[i]})
+	}
+	for i := len(a); i < len(b); i++ {
+		changes = append(changes, Change{Kind: Added, Path: path.Append(i), New: b[i]})
+	}
+	return changes
+}
+
+func diffValue(changes []Change, path Path, a, b Value) []Change {
+	switch a := a.(type) {
+	case *Map:
+		if b, ok := b.(*Map); ok {
+			return diffMap(changes, path, a, b)
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			return diffArray(changes, path, a, b)
+		}
+	}
+	if !equal(a, b) {
+		changes = append(changes, Change{Kind: Changed, Path: path, Old: a, New: b})
+	}
+	return changes
+}
+
+// lcs returns the set of keys in the longest common subsequence of a and b.
+// O(n*m) time and space.
+func lcs(a, b []Key) map[Key]bool {
+	n, m := len(a), len(b)
+	table := make([][]int, n+1)
+	for i := range table {
+		table[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				table[i][j] = table[i+1][j+1] + 1
+			case table[i+1][j] >= table[i][j+1]:
+				table[i][j] = table[i+1][j]
+			default:
+				table[i][j] = table[i][j+1]
+			}
+		}
+	}
+	keys := make(map[Key]bool, table[0][0])
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case a[i] == b[j]:
+			keys[a[i]] = true
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return keys
+}
+
+// equal reports whether two values are deeply equal, including order of keys in nested maps.
+func equal(a, b Value) bool {
+	switch a := a.(type) {
+	case *Map:
+		b, ok := b.(*Map)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for ea, eb := a.First(), b.First(); ea != nil; ea, eb = ea.Next(), eb.Next() {
+			if ea.key != eb.key || !equal(ea.value, eb.value) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// FormatDiff renders changes in a human-readable unified-style format.
+// Each line holds a marker, the JSON Pointer path and the value:
+// "-" for removed and old values, "+" for added and new values,
+// and "~" for moved keys with their old and new positions.
+//
+//	fmt.Print(jsonmap.FormatDiff(changes))
+func FormatDiff(changes []Change) string {
+	var b strings.Builder
+	for _, c := range changes {
+		switch c.Kind {
+		case Added:
+			fmt.Fprintf(&b, "+ %s: %s\n", c.Path, formatValue(c.New))
+		case Removed:
+			fmt.Fprintf(&b, "- %s: %s\n", c.Path, formatValue(c.Old))
+		case Changed:
+			fmt.Fprintf(&b, "- %s: %s\n", c.Path, formatValue(c.Old))
+			fmt.Fprintf(&b, "+ %s: %s\n", c.Path, formatValue(c.New))
+		case Moved:
+			fmt.Fprintf(&b, "~ %s: %d -> %d\n", c.Path, c.From, c.To)
+		}
+	}
+	return b.String()
+}
+
+func formatValue(v Value) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// MarshalJSON implements json.Marshaler interface.
+// The change is written as object with "kind" and "path" (JSON Pointer),
+// and "old", "new", or "from" and "to", depending on the kind.
+//
+//	data, err := json.Marshal(jsonmap.Diff(a, b))
+func (c Change) MarshalJSON() ([]byte, error) {
+	m := New()
+	m.Set("kind", c.Kind.String())
+	m.Set("path", c.Path.String())
+	switch c.Kind {
+	case Added:
+		m.Set("new", c.New)
+	case Removed:
+		m.Set("old", c.Old)
+	case Changed:
+		m.Set("old", c.Old)
+		m.Set("new", c.New)
+	case Moved:
+		m.Set("from", c.From)
+		m.Set("to", c.To)
+	}
+	return json.Marshal(m)
+}
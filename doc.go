@@ -76,29 +76,186 @@
 //	data, err := json.Marshal(myStruct)
 //	err = json.Unmarshal(data, &myStruct)
 //
+// Compare two maps, including order of keys:
+//
+//	changes := jsonmap.Diff(a, b)
+//	fmt.Print(jsonmap.FormatDiff(changes))
+//
+// Three-way merge, with conflicts returned as structured values:
+//
+//	merged, conflicts := jsonmap.Merge3(base, ours, theirs)
+//
+// Select values with JSONPath (RFC 9535), in document order:
+//
+//	nodes, err := jsonmap.Query(m, `$.store.book[?@.price < 10].title`)
+//	for _, node := range nodes {
+//	    fmt.Println(node.Path.Normalized(), node.Value)
+//	}
+//
+// Walk nested maps and arrays, editing them in place:
+//
+//	jsonmap.Walk(m, func(path jsonmap.Path, key jsonmap.Key, value jsonmap.Value) jsonmap.Action {
+//	    if value == nil {
+//	        return jsonmap.Delete
+//	    }
+//	    return jsonmap.Continue
+//	})
+//
+// Flatten nested maps to dotted keys, and back:
+//
+//	flat := jsonmap.Flatten(m, ".") // {"a":{"b":[1,2]}} -> {"a.b.0":1,"a.b.1":2}
+//	m, err := jsonmap.Unflatten(flat, ".")
+//
+// Read a stream of objects (NDJSON, RFC 7464 JSON text sequences, or concatenated JSON):
+//
+//	d := jsonmap.NewDecoder(r)
+//	for d.More() {
+//	    m := jsonmap.New()
+//	    err := d.Decode(m)
+//	}
+//
+// Stream elements of a huge array, keeping the rest of the document:
+//
+//	s := jsonmap.NewArrayStream(r, "/items")
+//	for s.Next() {
+//	    fmt.Println(s.Value())
+//	}
+//	err := s.Err()
+//	rest := s.Map()
+//
+// Write huge JSON token by token:
+//
+//	e := jsonmap.NewEncoder(w)
+//	e.BeginArray()
+//	for _, m := range maps {
+//	    e.WriteMap(m)
+//	}
+//	err := e.EndArray()
+//
+// Scan JSON events without building maps, skipping values you don't need:
+//
+//	err := jsonmap.Scan(data, func(e *jsonmap.Event) error {
+//	    if e.Kind == jsonmap.EventKey && e.Key != "id" {
+//	        return jsonmap.SkipValue
+//	    }
+//	    return nil
+//	})
+//
+// Decode only selected paths, skipping the rest:
+//
+//	err := jsonmap.UnmarshalSelect(data, m, "/id", "/items/*/name")
+//
+// Defer decoding of values, passing untouched ones through byte-for-byte:
+//
+//	r := jsonmap.NewRawMap()
+//	err := json.Unmarshal(data, r)
+//	r.Set("seen", true)
+//	out, err := json.Marshal(r)
+//
+// Parse JSON arriving in chunks, looking at the partial result on the way:
+//
+//	p := jsonmap.NewParser()
+//	err := p.Feed(chunk)
+//	m := p.Snapshot() // incomplete values are jsonmap.Partial
+//	err = p.Close()
+//
+// Parse malformed JSON, like trailing commas, single quotes or truncated input:
+//
+//	fixes, err := jsonmap.UnmarshalLenient(data, m)
+//	for _, fix := range fixes {
+//	    log.Println(fix) // offset 12: removed trailing comma
+//	}
+//
+// Read config files with comments (JSONC) or in JSON5, and write JSON5:
+//
+//	err := jsonmap.UnmarshalJSONC(data, m)
+//	err = jsonmap.UnmarshalJSON5(data, m)
+//	data, err = jsonmap.MarshalJSON5(m)
+//
+// Edit config files, keeping their comments and formatting:
+//
+//	err := jsonmap.UnmarshalPreserve(data, m)
+//	m.InsertAfter("license", "MIT", m.GetElement("version"))
+//	data, err = jsonmap.MarshalPreserve(m)
+//
+// Convert YAML, keeping order of keys:
+//
+//	err := jsonmap.UnmarshalYAML(data, m)
+//	data, err = jsonmap.MarshalYAML(m)
+//
+// Convert TOML, keeping order of keys:
+//
+//	err := jsonmap.UnmarshalTOML(data, m)
+//	data, err = jsonmap.MarshalTOML(m)
+//
+// Encode and decode MessagePack, keeping order of keys and integer types:
+//
+//	data, err := jsonmap.MarshalMsgpack(m)
+//	err = jsonmap.UnmarshalMsgpack(data, m)
+//
+// Encode CBOR in order of keys, or in deterministic order for signing, and decode it:
+//
+//	data, err := jsonmap.MarshalCBOR(m)
+//	data, err = jsonmap.CBOREncoder{Deterministic: true}.Marshal(m)
+//	err = jsonmap.UnmarshalCBOR(data, m)
+//
+// Encode BSON document for MongoDB commands, where order of keys matters, and decode it:
+//
+//	data, err := m.MarshalBSON()
+//	err = m.UnmarshalBSON(data)
+//
+// Convert XML to map and back, keeping order of elements, in "@attr"/"#text", BadgerFish or Parker convention:
+//
+//	m, err := jsonmap.FromXML(r)
+//	data, err := jsonmap.XMLConverter{Convention: jsonmap.XMLBadgerFish, Indent: "  "}.ToXML(m)
+//
+// Export rows as CSV or TSV with columns in order of first appearance of keys, and read them back:
+//
+//	err := jsonmap.WriteCSV(w, rows, jsonmap.CSVOptions{Flatten: true})
+//	rows, err := jsonmap.CSVOptions{Flatten: true, Infer: true}.ReadCSV(r)
+//
+// Encode URL query with parameters in order of elements, and nested keys like "a[b][0]=x", and parse it back:
+//
+//	query := jsonmap.EncodeQuery(m)
+//	m, err := jsonmap.ParseQuery(query)
+//
+// Read and write INI, Java .properties and dotenv files in order of keys, optionally unflattening dotted keys:
+//
+//	err := jsonmap.UnmarshalINI(data, m)
+//	err = jsonmap.KeyValueOptions{Unflatten: true}.UnmarshalProperties(data, m)
+//	data, err = jsonmap.MarshalDotenv(m)
+//
+// Edit XML or binary property lists, keeping order of dictionary keys:
+//
+//	err := jsonmap.UnmarshalPlist(data, m)
+//	data, err = jsonmap.MarshalPlist(m)
+//	data, err = jsonmap.MarshalBinaryPlist(m)
+//
 // Time complexity of operations:
 //
-//	| Operation | Time        |
-//	|-----------|-------------|
-//	| Clear     | O(1)        |
-//	| Get       | O(1)        |
-//	| Set       | O(1)        |
-//	| Delete    | O(1)        |
-//	| Push      | O(1)        |
-//	| Pop       | O(1)        |
-//	|           |             |
-//	| First     | O(1)        |
-//	| Last      | O(1)        |
-//	| GetElement| O(1)        |
-//	| el.Next   | O(1)        |
-//	| el.Prev   | O(1)        |
-//	|           |             |
-//	| SetFront  | O(1)        |
-//	| PushFront | O(1)        |
-//	| PopFront  | O(1)        |
-//	|           |             |
-//	| KeyIndex  | O(N)        |
-//	| Keys      | O(N)        |
-//	| Values    | O(N)        |
-//	| SortKeys  | O(N*log(N)) |
+//	| Operation    | Time        |
+//	|--------------|-------------|
+//	| Clear        | O(1)        |
+//	| Get          | O(1)        |
+//	| Set          | O(1)        |
+//	| Delete       | O(1)        |
+//	| Push         | O(1)        |
+//	| Pop          | O(1)        |
+//	|              |             |
+//	| First        | O(1)        |
+//	| Last         | O(1)        |
+//	| GetElement   | O(1)        |
+//	| el.Next      | O(1)        |
+//	| el.Prev      | O(1)        |
+//	|              |             |
+//	| SetFront     | O(1)        |
+//	| PushFront    | O(1)        |
+//	| PopFront     | O(1)        |
+//	| InsertBefore | O(1)        |
+//	| InsertAfter  | O(1)        |
+//	|              |             |
+//	| KeyIndex     | O(N)        |
+//	| Keys         | O(N)        |
+//	| Values       | O(N)        |
+//	| SortKeys     | O(N*log(N)) |
 package jsonmap
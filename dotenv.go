@@ -0,0 +1,165 @@
+package jsonmap
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// UnmarshalDotenv parses dotenv file, and stores it in m, keeping order of keys.
+// Shortcut for KeyValueOptions{}.UnmarshalDotenv(data, m).
+//
+//	err := jsonmap.UnmarshalDotenv(data, m)
+func UnmarshalDotenv(data []byte, m *Map) error {
+	return KeyValueOptions{}.UnmarshalDotenv(data, m)
+}
+
+// MarshalDotenv returns dotenv encoding of the map, with nested keys joined by ".".
+// Shortcut for KeyValueOptions{}.MarshalDotenv(m).
+//
+//	data, err := jsonmap.MarshalDotenv(m)
+func MarshalDotenv(m *Map) ([]byte, error) {
+	return KeyValueOptions{}.MarshalDotenv(m)
+}
+
+var (
+	dotenvKey   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*`)
+	dotenvPlain = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+)
+
+// UnmarshalDotenv parses dotenv file, and stores it in m, keeping order of keys.
+// Lines are "KEY=value", with optional "export " prefix, and lines starting with "#" are comments.
+// Unquoted values are trimmed, and " #" starts a comment in them. Values in single quotes are taken as is,
+// and values in double quotes may contain escapes \n, \r, \t, \" and \\. Quoted values may span lines.
+// Values are strings, and variables like ${VAR} are not expanded.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := opts.UnmarshalDotenv(data, m)
+func (o KeyValueOptions) UnmarshalDotenv(data []byte, m *Map) error {
+	text := strings.Join(textLines(data), "\n")
+	flat := New()
+	pos := 0
+	errorf := func(format string, args ...any) error {
+		line := strings.Count(text[:pos], "\n") + 1
+		return &LineError{Format: "dotenv", Line: line, Msg: fmt.Sprintf(format, args...)}
+	}
+	for pos < len(text) {
+		line := text[pos:]
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || trimmed[0] == '#' {
+			pos += len(line) + 1
+			continue
+		}
+		pos += len(line) - len(trimmed)
+		if rest := strings.TrimPrefix(trimmed, "export"); rest != trimmed && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			pos += len(trimmed) - len(strings.TrimLeft(rest, " \t"))
+		}
+
+		key := dotenvKey.FindString(text[pos:])
+		if key == "" {
+			return errorf("invalid key")
+		}
+		pos += len(key)
+		pos += len(text[pos:]) - len(strings.TrimLeft(text[pos:], " \t"))
+		if pos == len(text) || text[pos] != '=' {
+			return errorf("expected = after key %q", key)
+		}
+		pos++
+		pos += len(text[pos:]) - len(strings.TrimLeft(text[pos:], " \t"))
+
+		var value string
+		if pos < len(text) && (text[pos] == '"' || text[pos] == '\'') {
+			quote := text[pos]
+			var b strings.Builder
+			end := pos + 1
+			for ; end < len(text) && text[end] != quote; end++ {
+				if quote == '"' && text[end] == '\\' && end+1 < len(text) {
+					end++
+					switch text[end] {
+					case 'n':
+						b.WriteByte('\n')
+					case 'r':
+						b.WriteByte('\r')
+					case 't':
+						b.WriteByte('\t')
+					case '"', '\\':
+						b.WriteByte(text[end])
+					default:
+						b.WriteByte('\\')
+						b.WriteByte(text[end])
+					}
+					continue
+				}
+				b.WriteByte(text[end])
+			}
+			if end == len(text) {
+				return errorf("unterminated quoted value of key %q", key)
+			}
+			value = b.String()
+			pos = end + 1
+			rest := text[pos:]
+			if i := strings.IndexByte(rest, '\n'); i >= 0 {
+				rest = rest[:i]
+			}
+			if tail := strings.TrimLeft(rest, " \t"); tail != "" && tail[0] != '#' {
+				return errorf("unexpected text after quoted value of key %q", key)
+			}
+			pos += len(rest) + 1
+		} else {
+			value = text[pos:]
+			if i := strings.IndexByte(value, '\n'); i >= 0 {
+				value = value[:i]
+			}
+			pos += len(value) + 1
+			for i := 1; i < len(value); i++ {
+				if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+					value = value[:i]
+					break
+				}
+			}
+			value = strings.TrimSpace(value)
+		}
+		flat.Set(key, value)
+	}
+	return o.store(flat, m)
+}
+
+var dotenvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// MarshalDotenv returns dotenv encoding of the map, with "KEY=value" lines.
+// Nested maps and arrays are written with keys joined by Separator. nil is written as empty value,
+// strings as is, and other values in JSON format.
+// Values with special characters are written in single quotes, or in double quotes with escapes,
+// if they contain single quotes or line breaks.
+//
+//	data, err := opts.MarshalDotenv(m)
+func (o KeyValueOptions) MarshalDotenv(m *Map) ([]byte, error) {
+	var b bytes.Buffer
+	for el := o.flattener().Flatten(m).First(); el != nil; el = el.Next() {
+		if dotenvKey.FindString(el.key) != el.key {
+			return nil, fmt.Errorf("jsonmap: dotenv: invalid key %q", el.key)
+		}
+		v, err := normalizeValue(el.value)
+		if err != nil {
+			return nil, err
+		}
+		text, err := formatText(v)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case dotenvPlain.MatchString(text):
+		case !strings.ContainsAny(text, "'\r\n"):
+			text = "'" + text + "'"
+		default:
+			text = `"` + dotenvEscaper.Replace(text) + `"`
+		}
+		fmt.Fprintf(&b, "%s=%s\n", el.key, text)
+	}
+	return b.Bytes(), nil
+}
@@ -13,6 +13,8 @@ type Element struct {
 	value Value
 
 	next, prev *Element
+
+	cst *elemTrivia // formatting of parsed element, see UnmarshalPreserve
 }
 
 // Key returns the key of the element.
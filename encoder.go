@@ -0,0 +1,362 @@
+package jsonmap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+)
+
+// Encoder writes JSON token by token, for output too large to build as *Map first.
+// It validates nesting and places commas, colons and indentation.
+// Each complete top-level value is followed by a newline, same as json.Encoder.
+//
+// Defaults match MarshalJSON: compact output, with HTML characters escaped.
+// Errors are sticky: after the first error, all methods return it.
+//
+//	e := jsonmap.NewEncoder(w)
+//	e.BeginObject()
+//	e.Key("meta")
+//	e.WriteMap(meta)
+//	e.Key("items")
+//	e.BeginArray()
+//	for _, item := range items {
+//	    e.Value(item)
+//	}
+//	e.EndArray()
+//	err := e.EndObject()
+type Encoder struct {
+	w          io.Writer
+	buf        *bufio.Writer
+	prefix     string
+	indent     string
+	escapeHTML bool
+	json5      bool
+	flushEvery int
+	count      int // elements written since last flush
+	stack      []encFrame
+	err        error
+	scratch    bytes.Buffer  // output of enc
+	enc        *json.Encoder // for scalars, created on first use
+}
+
+type encFrame struct {
+	object bool
+	n      int  // elements written
+	hasKey bool // object key written, value expected
+}
+
+// NewEncoder returns a new encoder that writes to w.
+//
+//	e := jsonmap.NewEncoder(os.Stdout)
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:          w,
+		buf:        bufio.NewWriter(w),
+		escapeHTML: true,
+	}
+}
+
+// SetIndent makes encoder indent output, same as json.Encoder.SetIndent.
+// Each element begins on a new line starting with prefix followed by copies of indent according to nesting.
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.prefix = prefix
+	e.indent = indent
+}
+
+// SetEscapeHTML specifies whether characters <, > and & should be escaped in strings, as json.Encoder does by default.
+func (e *Encoder) SetEscapeHTML(on bool) {
+	e.escapeHTML = on
+}
+
+// SetJSON5 makes encoder write JSON5: keys, which are valid identifiers, are not quoted,
+// and NaN and infinite numbers are written as NaN and Infinity, instead of failing.
+func (e *Encoder) SetJSON5(on bool) {
+	e.json5 = on
+}
+
+// SetFlushEvery makes encoder flush output after every n elements, at any depth.
+// If the writer has Flush() method, like http.ResponseWriter, or Flush() error method, it is flushed too.
+// Zero (default) flushes only after complete top-level values and on Flush.
+func (e *Encoder) SetFlushEvery(n int) {
+	e.flushEvery = n
+}
+
+// Flush writes buffered output to the writer, and flushes the writer if possible.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.count = 0
+	if e.err = e.buf.Flush(); e.err != nil {
+		return e.err
+	}
+	switch f := e.w.(type) {
+	case interface{ Flush() }:
+		f.Flush()
+	case interface{ Flush() error }:
+		e.err = f.Flush()
+	}
+	return e.err
+}
+
+// BeginObject starts a JSON object, as a value.
+func (e *Encoder) BeginObject() error {
+	return e.begin(true)
+}
+
+// EndObject ends the current JSON object.
+func (e *Encoder) EndObject() error {
+	return e.end(true)
+}
+
+// BeginArray starts a JSON array, as a value.
+func (e *Encoder) BeginArray() error {
+	return e.begin(false)
+}
+
+// EndArray ends the current JSON array.
+func (e *Encoder) EndArray() error {
+	return e.end(false)
+}
+
+// Key writes a key of the current object. It must be followed by a value.
+func (e *Encoder) Key(key Key) error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 || !e.top().object || e.top().hasKey {
+		return e.fail("unexpected key")
+	}
+	f := e.top()
+	if f.n > 0 {
+		e.buf.WriteByte(',')
+	}
+	e.newline(len(e.stack))
+	if e.json5 && isIdentifier(key) {
+		e.buf.WriteString(key)
+	} else {
+		e.writeScalar(key)
+	}
+	e.buf.WriteByte(':')
+	if e.indent != "" || e.prefix != "" {
+		e.buf.WriteByte(' ')
+	}
+	f.hasKey = true
+	return e.err
+}
+
+// Value writes a complete value. Nested *Map and []any values are written with current indentation.
+func (e *Encoder) Value(value Value) error {
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	e.writeValue(value)
+	return e.afterValue()
+}
+
+// WriteMap writes the map as a value. Same as Value(m).
+func (e *Encoder) WriteMap(m *Map) error {
+	return e.Value(m)
+}
+
+func (e *Encoder) begin(object bool) error {
+	if err := e.beforeValue(); err != nil {
+		return err
+	}
+	if object {
+		e.buf.WriteByte('{')
+	} else {
+		e.buf.WriteByte('[')
+	}
+	e.stack = append(e.stack, encFrame{object: object})
+	return nil
+}
+
+func (e *Encoder) end(object bool) error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 || e.top().object != object || e.top().hasKey {
+		if object {
+			return e.fail("unexpected end of object")
+		}
+		return e.fail("unexpected end of array")
+	}
+	n := e.top().n
+	e.stack = e.stack[:len(e.stack)-1]
+	if n > 0 {
+		e.newline(len(e.stack))
+	}
+	if object {
+		e.buf.WriteByte('}')
+	} else {
+		e.buf.WriteByte(']')
+	}
+	return e.afterValue()
+}
+
+func (e *Encoder) top() *encFrame {
+	return &e.stack[len(e.stack)-1]
+}
+
+func (e *Encoder) fail(msg string) error {
+	e.err = errors.New("jsonmap encoder: " + msg)
+	return e.err
+}
+
+// beforeValue checks that a value is expected, and writes separators.
+func (e *Encoder) beforeValue() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 {
+		return nil
+	}
+	f := e.top()
+	if f.object {
+		if !f.hasKey {
+			return e.fail("expected key")
+		}
+		return nil
+	}
+	if f.n > 0 {
+		e.buf.WriteByte(',')
+	}
+	e.newline(len(e.stack))
+	return nil
+}
+
+// afterValue finishes the value, and flushes output if needed.
+func (e *Encoder) afterValue() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) == 0 {
+		e.buf.WriteByte('\n')
+		return e.Flush()
+	}
+	f := e.top()
+	f.n++
+	f.hasKey = false
+	return e.counted()
+}
+
+// counted counts a written element, and flushes output after every flushEvery elements.
+func (e *Encoder) counted() error {
+	e.count++
+	if e.flushEvery > 0 && e.count >= e.flushEvery {
+		return e.Flush()
+	}
+	return nil
+}
+
+func (e *Encoder) newline(depth int) {
+	if e.indent == "" && e.prefix == "" {
+		return
+	}
+	e.buf.WriteByte('\n')
+	e.buf.WriteString(e.prefix)
+	for i := 0; i < depth; i++ {
+		e.buf.WriteString(e.indent)
+	}
+}
+
+// writeValue writes nested maps and arrays with encoder indentation, and other values via encoding/json.
+// Nil maps and arrays are written as null, same as MarshalJSON does.
+func (e *Encoder) writeValue(value Value) {
+	switch v := value.(type) {
+	case *Map:
+		if v == nil {
+			e.buf.WriteString("null")
+			return
+		}
+		e.stack = append(e.stack, encFrame{object: true})
+		e.buf.WriteByte('{')
+		for el := v.First(); el != nil && e.err == nil; el = el.Next() {
+			e.Key(el.key)
+			e.beforeValue()
+			e.writeValue(el.value)
+			e.top().n++
+			e.top().hasKey = false
+			e.counted()
+		}
+		e.closeNested('}')
+	case []any:
+		if v == nil {
+			e.buf.WriteString("null")
+			return
+		}
+		e.stack = append(e.stack, encFrame{})
+		e.buf.WriteByte('[')
+		for _, item := range v {
+			if e.err != nil {
+				break
+			}
+			e.beforeValue()
+			e.writeValue(item)
+			e.top().n++
+			e.counted()
+		}
+		e.closeNested(']')
+	default:
+		e.writeScalar(value)
+	}
+}
+
+func (e *Encoder) closeNested(c byte) {
+	n := e.top().n
+	e.stack = e.stack[:len(e.stack)-1]
+	if n > 0 {
+		e.newline(len(e.stack))
+	}
+	e.buf.WriteByte(c)
+}
+
+func (e *Encoder) writeScalar(value Value) {
+	if e.err != nil {
+		return
+	}
+	if e.json5 {
+		var f float64
+		switch v := value.(type) {
+		case float64:
+			f = v
+		case float32:
+			f = float64(v)
+		}
+		switch {
+		case math.IsNaN(f):
+			e.buf.WriteString("NaN")
+			return
+		case math.IsInf(f, 1):
+			e.buf.WriteString("Infinity")
+			return
+		case math.IsInf(f, -1):
+			e.buf.WriteString("-Infinity")
+			return
+		}
+	}
+	if e.enc == nil {
+		e.enc = json.NewEncoder(&e.scratch)
+	}
+	e.scratch.Reset()
+	e.enc.SetEscapeHTML(e.escapeHTML)
+	if err := e.enc.Encode(value); err != nil {
+		e.err = err
+		return
+	}
+	data := bytes.TrimSuffix(e.scratch.Bytes(), []byte{'\n'})
+	if (e.indent != "" || e.prefix != "") && len(data) > 0 && (data[0] == '{' || data[0] == '[') {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, data, e.prefix+strings.Repeat(e.indent, len(e.stack)), e.indent); err != nil {
+			e.err = err
+			return
+		}
+		data = indented.Bytes()
+	}
+	e.buf.Write(data)
+}
@@ -48,3 +48,62 @@ func (m *Map) PopFront() (key Key, value Value, ok bool) {
 	m.Delete(key)
 	return
 }
+
+// InsertBefore sets the value for the key, and places the element before mark.
+// If key is already in the map, the element is moved, keeping its formatting, see UnmarshalPreserve.
+// If mark is not an element of the map, or is the element for the key, only the value is set.
+// O(1) time.
+//
+//	m.InsertBefore(key, value, m.GetElement(other))
+func (m *Map) InsertBefore(key Key, value Value, mark *Element) {
+	if elem := m.insertable(key, value, mark); elem != nil {
+		m.link(elem, mark.prev, mark)
+	}
+}
+
+// InsertAfter sets the value for the key, and places the element after mark.
+// If key is already in the map, the element is moved, keeping its formatting, see UnmarshalPreserve.
+// If mark is not an element of the map, or is the element for the key, only the value is set.
+// O(1) time.
+//
+//	m.InsertAfter(key, value, m.GetElement(other))
+func (m *Map) InsertAfter(key Key, value Value, mark *Element) {
+	if elem := m.insertable(key, value, mark); elem != nil {
+		m.link(elem, mark, mark.next)
+	}
+}
+
+// insertable sets the value, and returns the element unlinked from the list, ready to be placed next to mark.
+// Returns nil if it should stay in place.
+func (m *Map) insertable(key Key, value Value, mark *Element) *Element {
+	if mark == nil || m.elements[mark.key] != mark || mark.key == key {
+		m.Set(key, value)
+		return nil
+	}
+	elem, ok := m.elements[key]
+	if ok {
+		m.Delete(key)
+		elem.prev, elem.next = nil, nil
+		elem.value = value
+	} else {
+		elem = &Element{key: key, value: value}
+	}
+	m.elements[key] = elem
+	return elem
+}
+
+// link places the element between prev and next, which are adjacent or nil.
+func (m *Map) link(elem, prev, next *Element) {
+	elem.prev = prev
+	elem.next = next
+	if prev == nil {
+		m.first = elem
+	} else {
+		prev.next = elem
+	}
+	if next == nil {
+		m.last = elem
+	} else {
+		next.prev = elem
+	}
+}
@@ -0,0 +1,216 @@
+package jsonmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Flattener converts between nested maps and single-level maps with joined keys, like "a.b.0.c".
+// Empty Separator means ".", same as in CSVOptions.
+//
+//	f := jsonmap.Flattener{Separator: "__", Escape: `\`, Arrays: true}
+//	flat := f.Flatten(m)
+//	m2, err := f.Unflatten(flat)
+type Flattener struct {
+	// Separator of key parts, like "." (default) or "_".
+	Separator string
+
+	// Escape is prepended to separators and escapes inside keys, if set.
+	// Without it, keys containing the separator can't be unflattened back as they were.
+	Escape string
+
+	// FormatIndex formats array index as key part. Default is decimal number.
+	FormatIndex func(i int) string
+
+	// ParseIndex parses key part as array index, used by Unflatten if Arrays is set.
+	// Default accepts decimal numbers without leading zeros.
+	ParseIndex func(s string) (int, bool)
+
+	// Arrays makes Unflatten rebuild arrays from maps with keys 0..n-1.
+	// Otherwise arrays are rebuilt as maps with index keys.
+	Arrays bool
+}
+
+// Flatten returns a single-level map, with nested keys joined by sep, in depth-first document order.
+// Array indices become key parts. Empty nested maps and arrays are kept as values.
+// Shortcut for Flattener{Separator: sep}.Flatten(m).
+//
+//	flat := jsonmap.Flatten(m, ".") // {"a":{"b":[1,2]}} -> {"a.b.0":1,"a.b.1":2}
+func Flatten(m *Map, sep string) *Map {
+	return Flattener{Separator: sep}.Flatten(m)
+}
+
+// Unflatten reverses Flatten, rebuilding nested maps and arrays in order of keys.
+// Shortcut for Flattener{Separator: sep, Arrays: true}.Unflatten(m).
+//
+//	m, err := jsonmap.Unflatten(flat, ".")
+func Unflatten(m *Map, sep string) (*Map, error) {
+	return Flattener{Separator: sep, Arrays: true}.Unflatten(m)
+}
+
+// Flatten returns a single-level map, with nested keys joined by separator, in depth-first document order.
+//
+//	flat := f.Flatten(m)
+func (f Flattener) Flatten(m *Map) *Map {
+	if f.Separator == "" {
+		f.Separator = "."
+	}
+	out := New()
+	for el := m.First(); el != nil; el = el.Next() {
+		f.flattenValue(out, f.escape(el.key), el.value)
+	}
+	return out
+}
+
+// flattenMap flattens nested map, prefix is its joined key, which can be empty.
+func (f Flattener) flattenMap(out *Map, prefix string, m *Map) {
+	for el := m.First(); el != nil; el = el.Next() {
+		f.flattenValue(out, prefix+f.Separator+f.escape(el.key), el.value)
+	}
+}
+
+func (f Flattener) flattenValue(out *Map, key string, v Value) {
+	switch v := v.(type) {
+	case *Map:
+		if v.Len() > 0 {
+			f.flattenMap(out, key, v)
+			return
+		}
+	case []any:
+		if len(v) > 0 {
+			for i, item := range v {
+				f.flattenValue(out, key+f.Separator+f.escape(f.formatIndex(i)), item)
+			}
+			return
+		}
+	}
+	out.Set(key, v)
+}
+
+func (f Flattener) escape(s string) string {
+	if f.Escape == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, f.Escape, f.Escape+f.Escape)
+	return strings.ReplaceAll(s, f.Separator, f.Escape+f.Separator)
+}
+
+func (f Flattener) formatIndex(i int) string {
+	if f.FormatIndex != nil {
+		return f.FormatIndex(i)
+	}
+	return strconv.Itoa(i)
+}
+
+func (f Flattener) parseIndex(s string) (int, bool) {
+	if f.ParseIndex != nil {
+		return f.ParseIndex(s)
+	}
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// split splits key by separator, honoring and removing escapes.
+func (f Flattener) split(key string) []string {
+	if f.Escape == "" {
+		return strings.Split(key, f.Separator)
+	}
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(key); {
+		switch {
+		case strings.HasPrefix(key[i:], f.Escape) && i+len(f.Escape) < len(key):
+			i += len(f.Escape)
+			if strings.HasPrefix(key[i:], f.Separator) {
+				b.WriteString(f.Separator)
+				i += len(f.Separator)
+			} else if strings.HasPrefix(key[i:], f.Escape) {
+				b.WriteString(f.Escape)
+				i += len(f.Escape)
+			} else {
+				b.WriteString(f.Escape)
+			}
+		case strings.HasPrefix(key[i:], f.Separator):
+			parts = append(parts, b.String())
+			b.Reset()
+			i += len(f.Separator)
+		default:
+			b.WriteByte(key[i])
+			i++
+		}
+	}
+	return append(parts, b.String())
+}
+
+// Unflatten rebuilds nested maps from keys joined by separator, in order of keys.
+// Returns error if a key is both a value and a parent of other keys, like "a" and "a.b".
+//
+//	m, err := f.Unflatten(flat)
+func (f Flattener) Unflatten(m *Map) (*Map, error) {
+	if f.Separator == "" {
+		f.Separator = "."
+	}
+	out := New()
+	for el := m.First(); el != nil; el = el.Next() {
+		parts := f.split(el.key)
+		parent := out
+		for i, part := range parts[:len(parts)-1] {
+			v, ok := parent.Get(part)
+			if !ok {
+				child := New()
+				parent.Set(part, child)
+				parent = child
+				continue
+			}
+			child, ok := v.(*Map)
+			if !ok {
+				return nil, fmt.Errorf("unflatten %q: %q is not a map", el.key, strings.Join(parts[:i+1], f.Separator))
+			}
+			parent = child
+		}
+		last := parts[len(parts)-1]
+		if _, ok := parent.Get(last); ok {
+			return nil, fmt.Errorf("unflatten %q: duplicate key", el.key)
+		}
+		parent.Set(last, cloneValue(el.value))
+	}
+	if f.Arrays {
+		f.rebuildArrays(out)
+	}
+	return out, nil
+}
+
+// rebuildArrays replaces nested maps with keys 0..n-1 by arrays, depth-first.
+// Returns the array, if m itself can be converted.
+func (f Flattener) rebuildArrays(m *Map) ([]any, bool) {
+	for el := m.First(); el != nil; el = el.Next() {
+		if child, ok := el.value.(*Map); ok {
+			if a, ok := f.rebuildArrays(child); ok {
+				el.value = a
+			}
+		}
+	}
+	if m.Len() == 0 {
+		return nil, false
+	}
+	a := make([]any, m.Len())
+	seen := make([]bool, m.Len())
+	for el := m.First(); el != nil; el = el.Next() {
+		i, ok := f.parseIndex(el.key)
+		if !ok || i < 0 || i >= len(a) || seen[i] {
+			return nil, false
+		}
+		seen[i] = true
+		a[i] = el.value
+	}
+	return a, true
+}
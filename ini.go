@@ -0,0 +1,203 @@
+package jsonmap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// LineError is malformed input of a line-based format, like INI.
+type LineError struct {
+	Format string // name of the format, like "ini"
+	Line   int    // 1-based line number of the error
+	Msg    string
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("jsonmap: %s: %s at line %d", e.Format, e.Msg, e.Line)
+}
+
+// textLines splits text into lines, skipping byte order mark, and accepting "\n", "\r\n" and "\r" line endings.
+func textLines(data []byte) []string {
+	text := strings.TrimPrefix(string(data), "\uFEFF")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Split(text, "\n")
+}
+
+// UnmarshalINI parses INI file, and stores it in m, keeping order of keys.
+// Keys before the first section are stored in m, and sections become nested maps. Repeated sections are merged.
+//
+// Keys are separated from values by "=" or ":", and keys without separator get nil value.
+// Lines starting with ";" or "#" are comments. Indented lines continue the value of the previous key.
+// Values are strings, trimmed of spaces. Values in double quotes may contain escapes \\, \", \n, \r and \t,
+// and values in single quotes are taken as is.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := jsonmap.UnmarshalINI(data, m)
+func UnmarshalINI(data []byte, m *Map) error {
+	root := New()
+	section := root
+	lastKey := "" // key of the unquoted value, which can be continued
+	for i, line := range textLines(data) {
+		errorf := func(format string, args ...any) error {
+			return &LineError{Format: "ini", Line: i + 1, Msg: fmt.Sprintf(format, args...)}
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			lastKey = ""
+		case trimmed[0] == ';' || trimmed[0] == '#':
+		case lastKey != "" && (line[0] == ' ' || line[0] == '\t'):
+			v, _ := section.Get(lastKey)
+			section.Set(lastKey, v.(string)+"\n"+trimmed)
+		case trimmed[0] == '[':
+			if !strings.HasSuffix(trimmed, "]") {
+				return errorf("unterminated section header")
+			}
+			name := strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			if name == "" {
+				return errorf("empty section name")
+			}
+			v, ok := root.Get(name)
+			if !ok {
+				v = New()
+				root.Set(name, v)
+			}
+			if section, ok = v.(*Map); !ok {
+				return errorf("section %q conflicts with key", name)
+			}
+			lastKey = ""
+		default:
+			end := strings.IndexAny(trimmed, "=:")
+			if end < 0 {
+				section.Set(trimmed, nil)
+				lastKey = ""
+				break
+			}
+			key := strings.TrimSpace(trimmed[:end])
+			if key == "" {
+				return errorf("empty key")
+			}
+			value, quoted, err := iniValue(strings.TrimSpace(trimmed[end+1:]))
+			if err != nil {
+				return errorf("%s", err)
+			}
+			section.Set(key, value)
+			lastKey = key
+			if quoted {
+				lastKey = ""
+			}
+		}
+	}
+	for el := root.First(); el != nil; el = el.Next() {
+		m.Push(el.key, el.value)
+	}
+	return nil
+}
+
+// iniValue unquotes the value, if quoted.
+func iniValue(s string) (value string, quoted bool, err error) {
+	if s == "" || s[0] != '"' && s[0] != '\'' {
+		return s, false, nil
+	}
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return "", true, fmt.Errorf("unterminated quoted value")
+	}
+	if s[0] == '\'' {
+		return s[1 : len(s)-1], true, nil
+	}
+	var b strings.Builder
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '"' {
+			return "", true, fmt.Errorf("unescaped quote in value")
+		}
+		if c != '\\' || i+1 == len(s) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '\\', '"':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), true, nil
+}
+
+var iniEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// MarshalINI returns INI encoding of the map, with keys as "key = value" lines.
+// Nested maps are written as sections after other keys, as INI can't have keys after sections.
+// Deeper nesting and arrays are not supported.
+//
+// nil is written as a key without value, strings as is, and other values in JSON format.
+// Strings with surrounding spaces, line breaks or leading quotes are written in double quotes with escapes.
+//
+//	data, err := jsonmap.MarshalINI(m)
+func MarshalINI(m *Map) ([]byte, error) {
+	var b bytes.Buffer
+	var sections []*Element
+	for el := m.First(); el != nil; el = el.Next() {
+		if _, ok := el.value.(*Map); ok {
+			sections = append(sections, el)
+			continue
+		}
+		if err := iniEntry(&b, el.key, el.value); err != nil {
+			return nil, err
+		}
+	}
+	for _, el := range sections {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		if el.key == "" || el.key != strings.TrimSpace(el.key) || strings.ContainsAny(el.key, "]\r\n") {
+			return nil, fmt.Errorf("jsonmap: ini: invalid section name %q", el.key)
+		}
+		fmt.Fprintf(&b, "[%s]\n", el.key)
+		for sel := el.value.(*Map).First(); sel != nil; sel = sel.Next() {
+			if err := iniEntry(&b, sel.key, sel.value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b.Bytes(), nil
+}
+
+func iniEntry(b *bytes.Buffer, key Key, v Value) error {
+	if key == "" || key != strings.TrimSpace(key) || strings.ContainsAny(key, "=:\r\n") || strings.ContainsAny(key[:1], "[;#") {
+		return fmt.Errorf("jsonmap: ini: invalid key %q", key)
+	}
+	v, err := normalizeValue(v)
+	if err != nil {
+		return err
+	}
+	switch v.(type) {
+	case nil:
+		b.WriteString(key + "\n")
+		return nil
+	case *Map, []any:
+		return fmt.Errorf("jsonmap: ini: key %q: %T can't be written in INI", key, v)
+	}
+	text, err := formatText(v)
+	if err != nil {
+		return err
+	}
+	if text != strings.TrimSpace(text) || strings.ContainsAny(text, "\r\n") || text != "" && (text[0] == '"' || text[0] == '\'') {
+		text = `"` + iniEscaper.Replace(text) + `"`
+	}
+	fmt.Fprintf(b, "%s = %s\n", key, text)
+	return nil
+}
@@ -0,0 +1,267 @@
+package jsonmap
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// UnmarshalJSONC is same as UnmarshalJSON, but accepts JSON with comments (JSONC), as used by VS Code settings:
+// line comments //, block comments /* */, and trailing commas in objects and arrays.
+//
+//	err := jsonmap.UnmarshalJSONC(data, m)
+func UnmarshalJSONC(data []byte, m *Map) error {
+	return unmarshalDialect(data, m, dialectJSONC)
+}
+
+// UnmarshalJSON5 is same as UnmarshalJSON, but accepts JSON5 (https://spec.json5.org):
+// comments, trailing commas, unquoted keys, single-quoted and multi-line strings,
+// hexadecimal numbers, Infinity, NaN, leading plus sign, and leading or trailing decimal point.
+//
+//	err := jsonmap.UnmarshalJSON5(data, m)
+func UnmarshalJSON5(data []byte, m *Map) error {
+	return unmarshalDialect(data, m, dialectJSON5)
+}
+
+func unmarshalDialect(data []byte, m *Map, d dialect) error {
+	b := builder{root: m}
+	s := &scanner{buf: data, fn: b.event, dialect: d}
+	return s.run()
+}
+
+// MarshalJSON5 returns compact JSON5 encoding of the map:
+// keys, which are valid identifiers, are not quoted, and NaN and infinite numbers are written as NaN and Infinity.
+// HTML characters are not escaped. For indented output use Encoder with SetJSON5.
+//
+//	data, err := jsonmap.MarshalJSON5(m)
+func MarshalJSON5(m *Map) ([]byte, error) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetJSON5(true)
+	e.SetEscapeHTML(false)
+	if err := e.WriteMap(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// isIdentifier reports whether key is ECMAScript 5.1 IdentifierName, which can be written in JSON5 without quotes.
+func isIdentifier(key Key) bool {
+	for i, r := range key {
+		if !isIdentRune(r, i == 0) {
+			return false
+		}
+	}
+	return key != ""
+}
+
+func isIdentRune(r rune, first bool) bool {
+	switch {
+	case r == '$' || r == '_' || unicode.In(r, unicode.Lu, unicode.Ll, unicode.Lt, unicode.Lm, unicode.Lo, unicode.Nl):
+		return true
+	case first:
+		return false
+	}
+	return r == '\u200C' || r == '\u200D' || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc)
+}
+
+// skipComment skips a comment, or JSON5 whitespace, at current position. Reports whether anything was skipped.
+// Unterminated block comment is reported as read error.
+func (s *scanner) skipComment() bool {
+	c := s.buf[s.pos]
+	if c == '/' {
+		if !s.fill(2) || len(s.buf)-s.pos < 2 {
+			return false
+		}
+		switch s.buf[s.pos+1] {
+		case '/':
+			for s.more() && s.buf[s.pos] != '\n' {
+				s.pos++
+			}
+			return true
+		case '*':
+			offset := s.offset()
+			s.pos += 2
+			for {
+				if !s.fill(2) {
+					s.rerr = &ScanError{Offset: offset, Msg: "unterminated comment"}
+					return false
+				}
+				if s.buf[s.pos] == '*' && len(s.buf)-s.pos >= 2 && s.buf[s.pos+1] == '/' {
+					s.pos += 2
+					return true
+				}
+				s.pos++
+			}
+		}
+		return false
+	}
+	if s.dialect != dialectJSON5 {
+		return false
+	}
+	if c == '\v' || c == '\f' {
+		s.pos++
+		return true
+	}
+	if c >= utf8.RuneSelf {
+		s.fill(utf8.UTFMax)
+		r, size := utf8.DecodeRune(s.buf[s.pos:])
+		if r == '\uFEFF' || r == '\u2028' || r == '\u2029' || unicode.Is(unicode.Zs, r) {
+			s.pos += size
+			return true
+		}
+	}
+	return false
+}
+
+// identifier reads JSON5 unquoted key, and returns it in scratch buffer.
+func (s *scanner) identifier() ([]byte, error) {
+	s.str = s.str[:0]
+	for {
+		s.fill(utf8.UTFMax)
+		r, size := utf8.DecodeRune(s.buf[s.pos:])
+		if r == '\\' {
+			s.pos++
+			if s.peek() != 'u' {
+				return nil, s.errorf("invalid escape in key")
+			}
+			s.pos++
+			var err error
+			if r, err = s.hex4(); err != nil {
+				return nil, err
+			}
+			if !isIdentRune(r, len(s.str) == 0) {
+				return nil, s.errorf("invalid character %q in key", r)
+			}
+			size = 0
+		} else if size == 0 || !isIdentRune(r, len(s.str) == 0) {
+			break
+		}
+		s.pos += size
+		s.str = utf8.AppendRune(s.str, r)
+	}
+	if len(s.str) == 0 {
+		return nil, s.errorf("expected key")
+	}
+	return s.str, nil
+}
+
+// number5 reads JSON5 number.
+func (s *scanner) number5() (float64, error) {
+	sign := 1.0
+	switch s.peek() {
+	case '-':
+		sign = -1
+		s.pos++
+	case '+':
+		s.pos++
+	}
+	switch c := s.peek(); {
+	case c == 'I':
+		if err := s.literal("Infinity"); err != nil {
+			return 0, err
+		}
+		return math.Inf(int(sign)), nil
+	case c == 'N':
+		if err := s.literal("NaN"); err != nil {
+			return 0, err
+		}
+		return math.NaN(), nil
+	case c == '0' && s.fill(2) && len(s.buf)-s.pos >= 2 && (s.buf[s.pos+1] == 'x' || s.buf[s.pos+1] == 'X'):
+		s.pos += 2
+		s.str = s.str[:0]
+		for isHex(s.peek()) {
+			s.str = append(s.str, s.buf[s.pos])
+			s.pos++
+		}
+		n, err := strconv.ParseUint(string(s.str), 16, 64)
+		if err != nil {
+			return 0, s.errorf("invalid number 0x%s", s.str)
+		}
+		return sign * float64(n), nil
+	}
+
+	s.str = s.str[:0]
+	digits := func() int {
+		n := 0
+		for c := s.peek(); c >= '0' && c <= '9'; c = s.peek() {
+			s.str = append(s.str, c)
+			s.pos++
+			n++
+		}
+		return n
+	}
+	n := digits()
+	if n > 1 && s.str[0] == '0' {
+		return 0, s.errorf("invalid number with leading zero")
+	}
+	if s.peek() == '.' {
+		s.str = append(s.str, '.')
+		s.pos++
+		n += digits()
+	}
+	if n == 0 {
+		return 0, s.errorf("invalid number")
+	}
+	if c := s.peek(); c == 'e' || c == 'E' {
+		s.str = append(s.str, c)
+		s.pos++
+		if c := s.peek(); c == '+' || c == '-' {
+			s.str = append(s.str, c)
+			s.pos++
+		}
+		if digits() == 0 {
+			return 0, s.errorf("invalid number")
+		}
+	}
+	f, err := strconv.ParseFloat(string(s.str), 64)
+	if err != nil {
+		return 0, s.errorf("invalid number %s", s.str)
+	}
+	return sign * f, nil
+}
+
+// escape5 returns JSON5 escaped character c, which is not valid in JSON.
+// Returns -1 for line continuation.
+func (s *scanner) escape5(c byte) (rune, error) {
+	switch {
+	case s.pos > len(s.buf): // peeked past the end
+		s.pos--
+		return 0, s.errorf("unexpected end of input in string")
+	case c == 'v':
+		return '\v', nil
+	case c == '0':
+		if d := s.peek(); d >= '0' && d <= '9' {
+			return 0, s.errorf("invalid escape of digits in string")
+		}
+		return 0, nil
+	case c == 'x':
+		s.fill(2)
+		if len(s.buf)-s.pos < 2 || !isHex(s.buf[s.pos]) || !isHex(s.buf[s.pos+1]) {
+			return 0, s.errorf("invalid \\x escape in string")
+		}
+		n, _ := strconv.ParseUint(string(s.buf[s.pos:s.pos+2]), 16, 8)
+		s.pos += 2
+		return rune(n), nil
+	case c == '\n':
+		return -1, nil
+	case c == '\r':
+		if s.peek() == '\n' {
+			s.pos++
+		}
+		return -1, nil
+	case c >= '1' && c <= '9':
+		s.pos--
+		return 0, s.errorf("invalid escape character %q in string", c)
+	case c >= utf8.RuneSelf:
+		s.pos--
+		r, err := s.rune()
+		if r == '\u2028' || r == '\u2029' {
+			return -1, err
+		}
+		return r, err
+	}
+	return rune(c), nil
+}
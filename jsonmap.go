@@ -9,6 +9,8 @@ package jsonmap
 type Map struct {
 	elements    map[Key]*Element
 	first, last *Element
+
+	cst *mapTrivia // formatting of parsed document, see UnmarshalPreserve
 }
 
 // New returns a new map. O(1) time.
@@ -20,13 +22,14 @@ func New() *Map {
 	}
 }
 
-// Clear removes all elements from the map. O(1) time.
+// Clear removes all elements from the map, and formatting remembered by UnmarshalPreserve. O(1) time.
 //
 //	m.Clear()
 func (m *Map) Clear() {
 	m.elements = make(map[Key]*Element)
 	m.first = nil
 	m.last = nil
+	m.cst = nil
 }
 
 // Len returns the number of elements in the map, similar to len(m) for native map. O(1) time.
@@ -0,0 +1,1173 @@
+package jsonmap
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// JSONPath is a compiled JSONPath query (RFC 9535), to select values from nested maps and arrays.
+// Safe for concurrent use.
+//
+//	p, err := jsonmap.ParseJSONPath(`$.store.book[?@.price < 10].title`)
+//	for _, node := range p.Query(m) {
+//		fmt.Println(node.Path.Normalized(), node.Value)
+//	}
+type JSONPath struct {
+	expr     string
+	segments []jpSegment
+}
+
+// Node is a value selected by JSONPath query, with its location.
+// Use Path.Set to replace the selected value.
+type Node struct {
+	Path  Path
+	Value Value
+}
+
+// ParseJSONPath compiles JSONPath expression (RFC 9535).
+// Supports child and descendant segments, name, wildcard, index, slice and filter selectors,
+// and standard functions length(), count(), match(), search() and value().
+//
+//	p, err := jsonmap.ParseJSONPath(`$..author`)
+func ParseJSONPath(expr string) (*JSONPath, error) {
+	p := &jpParser{src: expr}
+	segments, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return &JSONPath{expr: expr, segments: segments}, nil
+}
+
+// MustParseJSONPath is same as ParseJSONPath, but panics on error.
+//
+//	var titles = jsonmap.MustParseJSONPath(`$.store.book[*].title`)
+func MustParseJSONPath(expr string) *JSONPath {
+	p, err := ParseJSONPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source expression of the query.
+func (p *JSONPath) String() string {
+	return p.expr
+}
+
+// Query returns values selected from root, in document order.
+// Root is usually *Map, but can be any value, including []any.
+//
+//	nodes := p.Query(m)
+func (p *JSONPath) Query(root Value) []Node {
+	return jpApply(root, p.segments, []Node{{Path: Path{}, Value: root}})
+}
+
+// Query is a shortcut for ParseJSONPath followed by JSONPath.Query.
+//
+//	nodes, err := jsonmap.Query(m, `$.store.book[?@.price < 10].title`)
+func Query(root Value, expr string) ([]Node, error) {
+	p, err := ParseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(root), nil
+}
+
+// JSONPathError is a syntax error in JSONPath expression.
+type JSONPathError struct {
+	Expr   string
+	Offset int
+	Msg    string
+}
+
+func (e *JSONPathError) Error() string {
+	return fmt.Sprintf("jsonpath: %s at offset %d in %q", e.Msg, e.Offset, e.Expr)
+}
+
+// evaluation
+
+type jpSegment struct {
+	descendant bool
+	selectors  []jpSelector
+}
+
+type jpSelector interface {
+	apply(root Value, n Node, out []Node) []Node
+}
+
+func jpApply(root Value, segments []jpSegment, nodes []Node) []Node {
+	for _, seg := range segments {
+		var out []Node
+		for _, n := range nodes {
+			if seg.descendant {
+				out = jpDescend(root, seg.selectors, n, out)
+				continue
+			}
+			for _, sel := range seg.selectors {
+				out = sel.apply(root, n, out)
+			}
+		}
+		nodes = out
+	}
+	return nodes
+}
+
+// jpDescend applies selectors to the node and all its descendants, in document order.
+func jpDescend(root Value, selectors []jpSelector, n Node, out []Node) []Node {
+	for _, sel := range selectors {
+		out = sel.apply(root, n, out)
+	}
+	switch v := n.Value.(type) {
+	case *Map:
+		for el := v.First(); el != nil; el = el.Next() {
+			out = jpDescend(root, selectors, Node{n.Path.Append(el.key), el.value}, out)
+		}
+	case []any:
+		for i, item := range v {
+			out = jpDescend(root, selectors, Node{n.Path.Append(i), item}, out)
+		}
+	}
+	return out
+}
+
+type jpName string
+
+func (s jpName) apply(_ Value, n Node, out []Node) []Node {
+	if m, ok := n.Value.(*Map); ok {
+		if v, ok := m.Get(string(s)); ok {
+			out = append(out, Node{n.Path.Append(string(s)), v})
+		}
+	}
+	return out
+}
+
+type jpWildcard struct{}
+
+func (jpWildcard) apply(_ Value, n Node, out []Node) []Node {
+	switch v := n.Value.(type) {
+	case *Map:
+		for el := v.First(); el != nil; el = el.Next() {
+			out = append(out, Node{n.Path.Append(el.key), el.value})
+		}
+	case []any:
+		for i, item := range v {
+			out = append(out, Node{n.Path.Append(i), item})
+		}
+	}
+	return out
+}
+
+type jpIndex int
+
+func (s jpIndex) apply(_ Value, n Node, out []Node) []Node {
+	if a, ok := n.Value.([]any); ok {
+		i := int(s)
+		if i < 0 {
+			i += len(a)
+		}
+		if i >= 0 && i < len(a) {
+			out = append(out, Node{n.Path.Append(i), a[i]})
+		}
+	}
+	return out
+}
+
+type jpSlice struct {
+	start, end *int
+	step       int
+}
+
+func (s jpSlice) apply(_ Value, n Node, out []Node) []Node {
+	a, ok := n.Value.([]any)
+	if !ok || s.step == 0 {
+		return out
+	}
+	l := len(a)
+	normalize := func(i int) int {
+		if i < 0 {
+			return l + i
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int) int {
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+	if s.step > 0 {
+		start, end := 0, l
+		if s.start != nil {
+			start = clamp(normalize(*s.start), 0, l)
+		}
+		if s.end != nil {
+			end = clamp(normalize(*s.end), 0, l)
+		}
+		for i := start; i < end; i += s.step {
+			out = append(out, Node{n.Path.Append(i), a[i]})
+		}
+		return out
+	}
+	start, end := l-1, -1
+	if s.start != nil {
+		start = clamp(normalize(*s.start), -1, l-1)
+	}
+	if s.end != nil {
+		end = clamp(normalize(*s.end), -1, l-1)
+	}
+	for i := start; i > end; i += s.step {
+		out = append(out, Node{n.Path.Append(i), a[i]})
+	}
+	return out
+}
+
+type jpFilter struct {
+	expr jpExpr
+}
+
+func (s jpFilter) apply(root Value, n Node, out []Node) []Node {
+	switch v := n.Value.(type) {
+	case *Map:
+		for el := v.First(); el != nil; el = el.Next() {
+			if s.expr.eval(root, el.value).logical {
+				out = append(out, Node{n.Path.Append(el.key), el.value})
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if s.expr.eval(root, item).logical {
+				out = append(out, Node{n.Path.Append(i), item})
+			}
+		}
+	}
+	return out
+}
+
+// filter expressions
+
+// jpType is a type of filter expression, see RFC 9535 section 2.4.1.
+type jpType int
+
+const (
+	jpValueType jpType = iota
+	jpLogicalType
+	jpNodesType
+)
+
+// jpResult holds result of filter expression, depending on its type.
+type jpResult struct {
+	value   Value // ValueType
+	nothing bool  // ValueType: no value
+	logical bool  // LogicalType
+	nodes   []Node
+}
+
+type jpExpr interface {
+	typ() jpType
+	eval(root, current Value) jpResult
+}
+
+type jpLiteral struct {
+	value Value
+}
+
+func (e jpLiteral) typ() jpType              { return jpValueType }
+func (e jpLiteral) eval(_, _ Value) jpResult { return jpResult{value: e.value} }
+
+type jpQuery struct {
+	relative bool // @ or $
+	singular bool
+	segments []jpSegment
+}
+
+func (e *jpQuery) typ() jpType { return jpNodesType }
+
+func (e *jpQuery) eval(root, current Value) jpResult {
+	start := root
+	if e.relative {
+		start = current
+	}
+	return jpResult{nodes: jpApply(root, e.segments, []Node{{Path: Path{}, Value: start}})}
+}
+
+// jpConvert converts expression result to the type required by context.
+type jpConvert struct {
+	expr jpExpr
+	to   jpType
+}
+
+func (e jpConvert) typ() jpType { return e.to }
+
+func (e jpConvert) eval(root, current Value) jpResult {
+	r := e.expr.eval(root, current)
+	switch {
+	case e.to == jpLogicalType && e.expr.typ() == jpNodesType:
+		return jpResult{logical: len(r.nodes) > 0}
+	case e.to == jpValueType && e.expr.typ() == jpNodesType:
+		if len(r.nodes) == 1 {
+			return jpResult{value: r.nodes[0].Value}
+		}
+		return jpResult{nothing: true}
+	}
+	return r
+}
+
+type jpOr []jpExpr
+
+func (e jpOr) typ() jpType { return jpLogicalType }
+
+func (e jpOr) eval(root, current Value) jpResult {
+	for _, x := range e {
+		if x.eval(root, current).logical {
+			return jpResult{logical: true}
+		}
+	}
+	return jpResult{}
+}
+
+type jpAnd []jpExpr
+
+func (e jpAnd) typ() jpType { return jpLogicalType }
+
+func (e jpAnd) eval(root, current Value) jpResult {
+	for _, x := range e {
+		if !x.eval(root, current).logical {
+			return jpResult{}
+		}
+	}
+	return jpResult{logical: true}
+}
+
+type jpNot struct {
+	expr jpExpr
+}
+
+func (e jpNot) typ() jpType { return jpLogicalType }
+
+func (e jpNot) eval(root, current Value) jpResult {
+	return jpResult{logical: !e.expr.eval(root, current).logical}
+}
+
+type jpCompare struct {
+	op          string
+	left, right jpExpr
+}
+
+func (e jpCompare) typ() jpType { return jpLogicalType }
+
+func (e jpCompare) eval(root, current Value) jpResult {
+	l := e.left.eval(root, current)
+	r := e.right.eval(root, current)
+	var res bool
+	switch e.op {
+	case "==":
+		res = jpEqual(l, r)
+	case "!=":
+		res = !jpEqual(l, r)
+	case "<":
+		res = jpLess(l, r)
+	case "<=":
+		res = jpLess(l, r) || jpEqual(l, r)
+	case ">":
+		res = jpLess(r, l)
+	case ">=":
+		res = jpLess(r, l) || jpEqual(l, r)
+	}
+	return jpResult{logical: res}
+}
+
+func jpEqual(a, b jpResult) bool {
+	if a.nothing || b.nothing {
+		return a.nothing && b.nothing
+	}
+	return jpEqualValues(a.value, b.value)
+}
+
+// jpEqualValues compares values as JSON: numbers by value and objects regardless of order of keys.
+func jpEqualValues(a, b Value) bool {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		return ok && x == y
+	}
+	switch a := a.(type) {
+	case *Map:
+		b, ok := b.(*Map)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for el := a.First(); el != nil; el = el.Next() {
+			v, ok := b.Get(el.key)
+			if !ok || !jpEqualValues(el.value, v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jpEqualValues(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case nil:
+		return b == nil
+	}
+	return equal(a, b)
+}
+
+func jpLess(a, b jpResult) bool {
+	if a.nothing || b.nothing {
+		return false
+	}
+	if x, ok := toNumber(a.value); ok {
+		y, ok := toNumber(b.value)
+		return ok && x < y
+	}
+	if x, ok := a.value.(string); ok {
+		y, ok := b.value.(string)
+		return ok && x < y
+	}
+	return false
+}
+
+// toNumber converts any Go number to float64.
+func toNumber(v Value) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case interface{ Float64() (float64, error) }: // json.Number
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// functions
+
+type jpFunction struct {
+	params []jpType
+	result jpType
+	call   func(args []jpResult) jpResult
+}
+
+var jpFunctions = map[string]jpFunction{
+	"length": {[]jpType{jpValueType}, jpValueType, jpLength},
+	"count":  {[]jpType{jpNodesType}, jpValueType, jpCount},
+	"match":  {[]jpType{jpValueType, jpValueType}, jpLogicalType, jpRegexpFunc(true)},
+	"search": {[]jpType{jpValueType, jpValueType}, jpLogicalType, jpRegexpFunc(false)},
+	"value":  {[]jpType{jpNodesType}, jpValueType, jpValue},
+}
+
+type jpCall struct {
+	fn   jpFunction
+	args []jpExpr
+	re   *regexp.Regexp // literal pattern of match() or search(), compiled once
+}
+
+func (e jpCall) typ() jpType { return e.fn.result }
+
+func (e jpCall) eval(root, current Value) jpResult {
+	if e.re != nil {
+		return jpRegexpMatch(e.re, e.args[0].eval(root, current))
+	}
+	args := make([]jpResult, len(e.args))
+	for i, arg := range e.args {
+		args[i] = arg.eval(root, current)
+	}
+	return e.fn.call(args)
+}
+
+func jpLength(args []jpResult) jpResult {
+	if args[0].nothing {
+		return jpResult{nothing: true}
+	}
+	switch v := args[0].value.(type) {
+	case string:
+		return jpResult{value: float64(utf8.RuneCountInString(v))}
+	case []any:
+		return jpResult{value: float64(len(v))}
+	case *Map:
+		return jpResult{value: float64(v.Len())}
+	}
+	return jpResult{nothing: true}
+}
+
+func jpCount(args []jpResult) jpResult {
+	return jpResult{value: float64(len(args[0].nodes))}
+}
+
+func jpValue(args []jpResult) jpResult {
+	if len(args[0].nodes) == 1 {
+		return jpResult{value: args[0].nodes[0].Value}
+	}
+	return jpResult{nothing: true}
+}
+
+// jpRegexpFunc returns match() or search() for patterns computed from the document,
+// which are compiled on each call. Literal patterns are compiled by parseCall.
+func jpRegexpFunc(full bool) func(args []jpResult) jpResult {
+	return func(args []jpResult) jpResult {
+		pattern, ok := args[1].value.(string)
+		if !ok || args[1].nothing {
+			return jpResult{}
+		}
+		re, err := jpCompileRegexp(pattern, full)
+		if err != nil {
+			return jpResult{}
+		}
+		return jpRegexpMatch(re, args[0])
+	}
+}
+
+func jpRegexpMatch(re *regexp.Regexp, arg jpResult) jpResult {
+	s, ok := arg.value.(string)
+	if !ok || arg.nothing {
+		return jpResult{}
+	}
+	return jpResult{logical: re.MatchString(s)}
+}
+
+// jpCompileRegexp converts I-Regexp (RFC 9485) to Go regexp.
+// The only difference handled is "." which does not match \n and \r in I-Regexp.
+func jpCompileRegexp(pattern string, full bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if full {
+		b.WriteString(`\A(?:`)
+	}
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteByte(c)
+			i++
+			c = pattern[i]
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '.' && !inClass:
+			b.WriteString(`[^\n\r]`)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	if full {
+		b.WriteString(`)\z`)
+	}
+	return regexp.Compile(b.String())
+}
+
+// parser
+
+type jpParser struct {
+	src   string
+	pos   int
+	depth int // nesting of expressions
+}
+
+func (p *jpParser) errorf(format string, args ...any) error {
+	return &JSONPathError{Expr: p.src, Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// enter checks and increases nesting depth of expressions. Caller decreases it when done.
+func (p *jpParser) enter() error {
+	if p.depth == maxDepth {
+		return p.errorf("exceeded max depth %d", maxDepth)
+	}
+	p.depth++
+	return nil
+}
+
+func (p *jpParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *jpParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jpParser) skipBlank() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *jpParser) parseQuery() ([]jpSegment, error) {
+	if !p.consume("$") {
+		return nil, p.errorf("expected '$'")
+	}
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	}
+	return segments, nil
+}
+
+func (p *jpParser) parseSegments() ([]jpSegment, error) {
+	var segments []jpSegment
+	for {
+		start := p.pos
+		p.skipBlank()
+		var seg jpSegment
+		var err error
+		switch {
+		case p.consume(".."):
+			seg.descendant = true
+			switch {
+			case p.peek() == '[':
+				seg.selectors, err = p.parseBracket()
+			case p.consume("*"):
+				seg.selectors = []jpSelector{jpWildcard{}}
+			default:
+				seg.selectors, err = p.parseShorthand()
+			}
+		case p.consume("."):
+			if p.consume("*") {
+				seg.selectors = []jpSelector{jpWildcard{}}
+			} else {
+				seg.selectors, err = p.parseShorthand()
+			}
+		case p.peek() == '[':
+			seg.selectors, err = p.parseBracket()
+		default:
+			p.pos = start
+			return segments, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+}
+
+func (p *jpParser) parseShorthand() ([]jpSelector, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		nameFirst := r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= 0x80 && r != utf8.RuneError && (r <= 0xD7FF || r >= 0xE000)
+		if !nameFirst && !(p.pos > start && r >= '0' && r <= '9') {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return nil, p.errorf("expected member name")
+	}
+	return []jpSelector{jpName(p.src[start:p.pos])}, nil
+}
+
+func (p *jpParser) parseBracket() ([]jpSelector, error) {
+	p.pos++ // [
+	var selectors []jpSelector
+	for {
+		p.skipBlank()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		p.skipBlank()
+		if p.consume("]") {
+			return selectors, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+func (p *jpParser) parseSelector() (jpSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return jpName(s), err
+	case c == '*':
+		p.pos++
+		return jpWildcard{}, nil
+	case c == '?':
+		p.pos++
+		p.skipBlank()
+		expr, err := p.parseLogical()
+		if err != nil {
+			return nil, err
+		}
+		return jpFilter{expr}, nil
+	case c == '-' || c == ':' || c >= '0' && c <= '9':
+		return p.parseIndexOrSlice()
+	}
+	return nil, p.errorf("expected selector")
+}
+
+func (p *jpParser) parseIndexOrSlice() (jpSelector, error) {
+	var bounds [3]*int
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			p.skipBlank()
+			if !p.consume(":") {
+				if i == 1 {
+					return jpIndex(*bounds[0]), nil
+				}
+				break
+			}
+			p.skipBlank()
+		}
+		if c := p.peek(); c == '-' || c >= '0' && c <= '9' {
+			n, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			bounds[i] = &n
+		} else if i == 0 && c != ':' {
+			return nil, p.errorf("expected index")
+		}
+	}
+	s := jpSlice{start: bounds[0], end: bounds[1], step: 1}
+	if bounds[2] != nil {
+		s.step = *bounds[2]
+	}
+	return s, nil
+}
+
+const jpMaxInt = 1<<53 - 1 // I-JSON integer range
+
+func (p *jpParser) parseInt() (int, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	if p.pos == digits || p.src[digits] == '0' && (p.pos-digits > 1 || digits > start) {
+		p.pos = start
+		return 0, p.errorf("invalid integer")
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n > jpMaxInt || n < -jpMaxInt {
+		p.pos = start
+		return 0, p.errorf("integer out of range")
+	}
+	return int(n), nil
+}
+
+func (p *jpParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		p.pos++ // backslash
+		c = p.peek()
+		p.pos++
+		switch c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\':
+			b.WriteByte(c)
+		case 'u':
+			r, err := p.parseHex4()
+			if err != nil {
+				return "", err
+			}
+			if utf16.IsSurrogate(r) {
+				if r >= 0xDC00 || !p.consume(`\u`) {
+					return "", p.errorf("invalid surrogate pair")
+				}
+				r2, err := p.parseHex4()
+				if err != nil {
+					return "", err
+				}
+				if r = utf16.DecodeRune(r, r2); r == utf8.RuneError {
+					return "", p.errorf("invalid surrogate pair")
+				}
+			}
+			b.WriteRune(r)
+		default:
+			if c != quote {
+				p.pos--
+				return "", p.errorf("invalid escape")
+			}
+			b.WriteByte(c)
+		}
+	}
+}
+
+func (p *jpParser) parseHex4() (rune, error) {
+	if p.pos+4 > len(p.src) {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	p.pos += 4
+	return rune(n), nil
+}
+
+func (p *jpParser) parseLogical() (jpExpr, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	var or jpOr
+	for {
+		var and jpAnd
+		for {
+			expr, err := p.parseBasic()
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, expr)
+			p.skipBlank()
+			if !p.consume("&&") {
+				break
+			}
+			p.skipBlank()
+		}
+		if len(and) == 1 {
+			or = append(or, and[0])
+		} else {
+			or = append(or, and)
+		}
+		if !p.consume("||") {
+			break
+		}
+		p.skipBlank()
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *jpParser) parseBasic() (jpExpr, error) {
+	if p.consume("!") {
+		p.skipBlank()
+		if p.consume("(") {
+			return p.parseParen(true)
+		}
+		expr, err := p.parseTest()
+		if err != nil {
+			return nil, err
+		}
+		return jpNot{expr}, nil
+	}
+	if p.consume("(") {
+		return p.parseParen(false)
+	}
+
+	start := p.pos
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	afterLeft := p.pos
+	p.skipBlank()
+	op := ""
+	for _, o := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		p.pos = afterLeft
+		return p.toTest(left, start)
+	}
+	p.skipBlank()
+	rightStart := p.pos
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if left, err = p.toComparable(left, start); err != nil {
+		return nil, err
+	}
+	if right, err = p.toComparable(right, rightStart); err != nil {
+		return nil, err
+	}
+	return jpCompare{op, left, right}, nil
+}
+
+func (p *jpParser) parseParen(not bool) (jpExpr, error) {
+	p.skipBlank()
+	expr, err := p.parseLogical()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if !p.consume(")") {
+		return nil, p.errorf("expected ')'")
+	}
+	if not {
+		return jpNot{expr}, nil
+	}
+	return expr, nil
+}
+
+func (p *jpParser) parseTest() (jpExpr, error) {
+	start := p.pos
+	expr, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return p.toTest(expr, start)
+}
+
+// toTest checks that expression can be used as test expression, converting query to existence test.
+func (p *jpParser) toTest(expr jpExpr, start int) (jpExpr, error) {
+	switch expr.typ() {
+	case jpNodesType:
+		return jpConvert{expr, jpLogicalType}, nil
+	case jpLogicalType:
+		return expr, nil
+	}
+	p.pos = start
+	return nil, p.errorf("expected test or comparison")
+}
+
+// toComparable checks that expression can be compared: literal, singular query, or function returning value.
+func (p *jpParser) toComparable(expr jpExpr, start int) (jpExpr, error) {
+	switch e := expr.(type) {
+	case *jpQuery:
+		if e.singular {
+			return jpConvert{e, jpValueType}, nil
+		}
+	default:
+		if expr.typ() == jpValueType {
+			return expr, nil
+		}
+	}
+	p.pos = start
+	return nil, p.errorf("not comparable")
+}
+
+// parseOperand parses literal, filter query or function call.
+func (p *jpParser) parseOperand() (jpExpr, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	c := p.peek()
+	switch {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.parseSegments()
+		if err != nil {
+			return nil, err
+		}
+		q := &jpQuery{relative: c == '@', singular: true, segments: segments}
+		for _, seg := range segments {
+			if seg.descendant || len(seg.selectors) != 1 {
+				q.singular = false
+				continue
+			}
+			switch seg.selectors[0].(type) {
+			case jpName, jpIndex:
+			default:
+				q.singular = false
+			}
+		}
+		return q, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return jpLiteral{s}, err
+	case c == '-' || c >= '0' && c <= '9':
+		return p.parseNumber()
+	case p.consume("true"):
+		return jpLiteral{true}, nil
+	case p.consume("false"):
+		return jpLiteral{false}, nil
+	case p.consume("null"):
+		return jpLiteral{nil}, nil
+	case c >= 'a' && c <= 'z':
+		return p.parseCall()
+	}
+	return nil, p.errorf("expected expression")
+}
+
+func (p *jpParser) parseNumber() (jpExpr, error) {
+	start := p.pos
+	p.consume("-")
+	intStart := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == intStart || p.src[intStart] == '0' && p.pos-intStart > 1 {
+		p.pos = start
+		return nil, p.errorf("invalid number")
+	}
+	if p.consume(".") {
+		fracStart := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == fracStart {
+			return nil, p.errorf("invalid number")
+		}
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		expStart := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == expStart {
+			return nil, p.errorf("invalid number")
+		}
+	}
+	f, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil || math.IsInf(f, 0) {
+		p.pos = start
+		return nil, p.errorf("invalid number")
+	}
+	return jpLiteral{f}, nil
+}
+
+func (p *jpParser) parseCall() (jpExpr, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+			break
+		}
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	fn, ok := jpFunctions[name]
+	if !ok || !p.consume("(") {
+		p.pos = start
+		return nil, p.errorf("unknown function %q", name)
+	}
+	var args []jpExpr
+	for i := range fn.params {
+		p.skipBlank()
+		if i > 0 && !p.consume(",") {
+			return nil, p.errorf("expected ',' in %s()", name)
+		}
+		p.skipBlank()
+		argStart := p.pos
+		var arg jpExpr
+		var err error
+		if fn.params[i] == jpLogicalType {
+			arg, err = p.parseLogical()
+		} else {
+			arg, err = p.parseOperand()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if arg, err = p.toParam(arg, fn.params[i], argStart); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.skipBlank()
+	if !p.consume(")") {
+		return nil, p.errorf("expected ')' in %s()", name)
+	}
+	call := jpCall{fn: fn, args: args}
+	if lit, ok := args[len(args)-1].(jpLiteral); ok && (name == "match" || name == "search") {
+		if pattern, ok := lit.value.(string); ok {
+			call.re, _ = jpCompileRegexp(pattern, name == "match") // invalid pattern gives false on each call
+		}
+	}
+	return call, nil
+}
+
+// toParam checks that function argument is well-typed, see RFC 9535 section 2.4.3.
+func (p *jpParser) toParam(arg jpExpr, typ jpType, start int) (jpExpr, error) {
+	switch typ {
+	case jpValueType:
+		return p.toComparable(arg, start)
+	case jpLogicalType:
+		return p.toTest(arg, start)
+	}
+	if arg.typ() != jpNodesType {
+		p.pos = start
+		return nil, p.errorf("expected query")
+	}
+	return arg, nil
+}
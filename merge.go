@@ -0,0 +1,125 @@
+package jsonmap
+
+// Conflict is a value that could not be merged by Merge3, because both sides changed it differently.
+// HasBase, HasOurs and HasTheirs report whether the key exists in each version;
+// a missing key means it was not added, or was deleted.
+type Conflict struct {
+	Path                        Path
+	Base, Ours, Theirs          Value
+	HasBase, HasOurs, HasTheirs bool
+}
+
+// Merge3 does a three-way merge of maps ours and theirs, which both were derived from base.
+// Nested maps are merged key by key, all other values (including arrays) are merged as a whole.
+//
+// Order of keys is reconciled relative to base: if only one side reordered keys, its order wins,
+// otherwise order of ours is used. Keys added by either side are placed after the same preceding key
+// as on the side they were added.
+//
+// Values changed differently by both sides are returned as conflicts,
+// and the merged map holds the value from ours for them (or no key, if ours deleted it).
+// The input maps are not modified, but the merged map may share nested values with them.
+//
+//	merged, conflicts := jsonmap.Merge3(base, ours, theirs)
+func Merge3(base, ours, theirs *Map) (merged *Map, conflicts []Conflict) {
+	merged = New()
+	conflicts = mergeMap(merged, conflicts, nil, base, ours, theirs)
+	return merged, conflicts
+}
+
+func mergeMap(merged *Map, conflicts []Conflict, path Path, base, ours, theirs *Map) []Conflict {
+	for _, key := range mergeKeys(base, ours, theirs) {
+		b, hasB := base.Get(key)
+		o, hasO := ours.Get(key)
+		t, hasT := theirs.Get(key)
+		p := path.Append(key)
+		switch {
+		case hasO == hasT && (!hasO || equal(o, t)):
+			if hasO {
+				merged.Set(key, o)
+			}
+		case hasB == hasO && (!hasB || equal(b, o)):
+			if hasT {
+				merged.Set(key, t)
+			}
+		case hasB == hasT && (!hasB || equal(b, t)):
+			if hasO {
+				merged.Set(key, o)
+			}
+		default:
+			om, okO := o.(*Map)
+			tm, okT := t.(*Map)
+			bm, okB := b.(*Map)
+			if !hasB {
+				bm, okB = New(), true
+			}
+			if okO && okT && okB {
+				m := New()
+				conflicts = mergeMap(m, conflicts, p, bm, om, tm)
+				merged.Set(key, m)
+				continue
+			}
+			conflicts = append(conflicts, Conflict{
+				Path: p,
+				Base: b, Ours: o, Theirs: t,
+				HasBase: hasB, HasOurs: hasO, HasTheirs: hasT,
+			})
+			if hasO {
+				merged.Set(key, o)
+			}
+		}
+	}
+	return conflicts
+}
+
+// mergeKeys returns union of keys of ours and theirs in merged order.
+// Keys of the side which reordered keys relative to base form the skeleton,
+// and keys found only on the other side are inserted after their preceding key.
+func mergeKeys(base, ours, theirs *Map) []Key {
+	skeleton, other := ours, theirs
+	if !reordered(base, ours) && reordered(base, theirs) {
+		skeleton, other = theirs, ours
+	}
+
+	keys := skeleton.Keys()
+	index := make(map[Key]int, len(keys))
+	for i, key := range keys {
+		index[key] = i
+	}
+	pos := 0 // insert position: after the last seen key of other, which is in keys
+	for el := other.First(); el != nil; el = el.Next() {
+		if i, ok := index[el.key]; ok {
+			pos = i + 1
+			continue
+		}
+		keys = append(keys, "")
+		copy(keys[pos+1:], keys[pos:])
+		keys[pos] = el.key
+		for i := pos; i < len(keys); i++ {
+			index[keys[i]] = i
+		}
+		pos++
+	}
+	return keys
+}
+
+// reordered reports whether relative order of keys common to base and m differs between them.
+func reordered(base, m *Map) bool {
+	b := base.First()
+	for el := m.First(); el != nil; el = el.Next() {
+		if _, ok := base.elements[el.key]; !ok {
+			continue
+		}
+		for b != nil {
+			if _, ok := m.elements[b.key]; ok {
+				break
+			}
+			b = b.Next()
+		}
+		if b == nil || b.key != el.key {
+			return true
+		}
+		b = b.Next()
+	}
+	return false
+}
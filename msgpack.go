@@ -0,0 +1,471 @@
+package jsonmap
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// MsgpackExt is MessagePack extension value, other than timestamp.
+type MsgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// FormatError is malformed input of a binary format, like MessagePack.
+type FormatError struct {
+	Format string // name of the format, like "msgpack"
+	Offset int64  // byte offset of the error
+	Msg    string
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("jsonmap: %s: %s at offset %d", e.Format, e.Msg, e.Offset)
+}
+
+// MarshalMsgpack returns MessagePack encoding of the map. Maps are written in order of elements.
+//
+// Integers are written in the smallest form, float32 and float64 keep their size.
+// []byte is written as binary, time.Time as timestamp extension, and MsgpackExt as extension.
+// Other types are converted through JSON first.
+//
+//	data, err := jsonmap.MarshalMsgpack(m)
+func MarshalMsgpack(m *Map) ([]byte, error) {
+	var w msgpackWriter
+	if err := w.value(m); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+// UnmarshalMsgpack parses MessagePack map, and stores it in m, keeping order of keys.
+// Nested maps, including ones inside arrays, are decoded as *Map, and arrays as []any.
+//
+// Integers are decoded as int64, or uint64 if they don't fit, float32 as float32, and float64 as float64.
+// Binary is decoded as []byte, timestamps as time.Time in UTC, and other extensions as MsgpackExt.
+// Integer and binary map keys are converted to strings.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := jsonmap.UnmarshalMsgpack(data, m)
+func UnmarshalMsgpack(data []byte, m *Map) error {
+	r := &msgpackReader{data: data}
+	if len(data) > 0 && !(data[0]&0xf0 == 0x80 || data[0] == 0xde || data[0] == 0xdf) {
+		return r.errorf("expected map")
+	}
+	v, err := r.value()
+	if err != nil {
+		return err
+	}
+	if r.pos < len(data) {
+		return r.errorf("unexpected data after top-level value")
+	}
+	for el := v.(*Map).First(); el != nil; el = el.Next() {
+		m.Push(el.key, el.value)
+	}
+	return nil
+}
+
+type msgpackWriter struct {
+	buf []byte
+}
+
+func (w *msgpackWriter) value(v Value) error {
+	switch v := v.(type) {
+	case nil:
+		w.buf = append(w.buf, 0xc0)
+	case bool:
+		if v {
+			w.buf = append(w.buf, 0xc3)
+		} else {
+			w.buf = append(w.buf, 0xc2)
+		}
+	case *Map:
+		w.header(v.Len(), 0x80, 16, 0xde)
+		for el := v.First(); el != nil; el = el.Next() {
+			w.header(len(el.key), 0xa0, 32, 0xd9)
+			w.buf = append(w.buf, el.key...)
+			if err := w.value(el.value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		w.header(len(v), 0x90, 16, 0xdc)
+		for _, item := range v {
+			if err := w.value(item); err != nil {
+				return err
+			}
+		}
+	case string:
+		w.header(len(v), 0xa0, 32, 0xd9)
+		w.buf = append(w.buf, v...)
+	case []byte:
+		w.header(len(v), 0, 0, 0xc4)
+		w.buf = append(w.buf, v...)
+	case float64:
+		w.buf = append(w.buf, 0xcb)
+		w.buf = binary.BigEndian.AppendUint64(w.buf, math.Float64bits(v))
+	case float32:
+		w.buf = append(w.buf, 0xca)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, math.Float32bits(v))
+	case int:
+		w.int(int64(v))
+	case int8:
+		w.int(int64(v))
+	case int16:
+		w.int(int64(v))
+	case int32:
+		w.int(int64(v))
+	case int64:
+		w.int(v)
+	case uint:
+		w.uint(uint64(v))
+	case uint8:
+		w.uint(uint64(v))
+	case uint16:
+		w.uint(uint64(v))
+	case uint32:
+		w.uint(uint64(v))
+	case uint64:
+		w.uint(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			w.int(n)
+		} else if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			w.uint(n)
+		} else if f, err := v.Float64(); err == nil {
+			return w.value(f)
+		} else {
+			return fmt.Errorf("jsonmap: msgpack: invalid number %s", v)
+		}
+	case time.Time:
+		w.timestamp(v)
+	case MsgpackExt:
+		w.ext(v.Type, v.Data)
+	default:
+		value, err := convertJSON(v)
+		if err != nil {
+			return err
+		}
+		return w.value(value)
+	}
+	return nil
+}
+
+// header writes type byte and length. Lengths below fixLimit are written in the fix form,
+// others with code, code+1 and code+2 for 8-bit, 16-bit and 32-bit lengths.
+// Arrays and maps have no 8-bit form, and their code is for 16-bit length.
+func (w *msgpackWriter) header(n int, fix byte, fixLimit int, code byte) {
+	switch {
+	case n < fixLimit:
+		w.buf = append(w.buf, fix|byte(n))
+	case code == 0xdc || code == 0xde:
+		if n <= math.MaxUint16 {
+			w.buf = append(w.buf, code)
+			w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+		} else {
+			w.buf = append(w.buf, code+1)
+			w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+		}
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, code, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, code+1)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, code+2)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+	}
+}
+
+func (w *msgpackWriter) int(n int64) {
+	switch {
+	case n >= 0:
+		w.uint(uint64(n))
+	case n >= -32:
+		w.buf = append(w.buf, byte(n))
+	case n >= math.MinInt8:
+		w.buf = append(w.buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		w.buf = append(w.buf, 0xd1)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+	case n >= math.MinInt32:
+		w.buf = append(w.buf, 0xd2)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+	default:
+		w.buf = append(w.buf, 0xd3)
+		w.buf = binary.BigEndian.AppendUint64(w.buf, uint64(n))
+	}
+}
+
+func (w *msgpackWriter) uint(n uint64) {
+	switch {
+	case n <= 0x7f:
+		w.buf = append(w.buf, byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xcd)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+	case n <= math.MaxUint32:
+		w.buf = append(w.buf, 0xce)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+	default:
+		w.buf = append(w.buf, 0xcf)
+		w.buf = binary.BigEndian.AppendUint64(w.buf, n)
+	}
+}
+
+func (w *msgpackWriter) ext(typ int8, data []byte) {
+	switch len(data) {
+	case 1, 2, 4, 8, 16:
+		fixext := map[int]byte{1: 0xd4, 2: 0xd5, 4: 0xd6, 8: 0xd7, 16: 0xd8}
+		w.buf = append(w.buf, fixext[len(data)])
+	default:
+		w.header(len(data), 0, 0, 0xc7)
+	}
+	w.buf = append(w.buf, byte(typ))
+	w.buf = append(w.buf, data...)
+}
+
+// timestamp writes time as timestamp extension, in the smallest of 32-bit, 64-bit and 96-bit forms.
+func (w *msgpackWriter) timestamp(t time.Time) {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	var data []byte
+	switch {
+	case sec>>34 != 0:
+		data = binary.BigEndian.AppendUint32(nil, uint32(nsec))
+		data = binary.BigEndian.AppendUint64(data, uint64(sec))
+	case nsec == 0 && sec>>32 == 0:
+		data = binary.BigEndian.AppendUint32(nil, uint32(sec))
+	default:
+		data = binary.BigEndian.AppendUint64(nil, nsec<<34|uint64(sec))
+	}
+	w.ext(-1, data)
+}
+
+type msgpackReader struct {
+	data  []byte
+	pos   int
+	depth int // nesting of arrays and maps
+}
+
+// enter checks and increases nesting depth, before reading contents of array or map.
+func (r *msgpackReader) enter() error {
+	if r.depth == maxDepth {
+		return r.errorf("exceeded max depth %d", maxDepth)
+	}
+	r.depth++
+	return nil
+}
+
+func (r *msgpackReader) errorf(format string, args ...any) error {
+	return &FormatError{Format: "msgpack", Offset: int64(r.pos), Msg: fmt.Sprintf(format, args...)}
+}
+
+// next returns next n bytes.
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, r.errorf("unexpected end of input")
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// uint reads big-endian unsigned integer of size bytes.
+func (r *msgpackReader) uint(size int) (uint64, error) {
+	b, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+// length reads length of size bytes, and checks that input has at least n*min more bytes.
+func (r *msgpackReader) length(size, min int) (int, error) {
+	n, err := r.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(r.data)-r.pos)/uint64(min) {
+		return 0, r.errorf("unexpected end of input")
+	}
+	return int(n), nil
+}
+
+func (r *msgpackReader) value() (Value, error) {
+	start := r.pos
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return r.mapping(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return r.array(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		b, err := r.next(int(c & 0x1f))
+		return string(b), err
+	}
+
+	var n int
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6: // bin 8, 16, 32
+		if n, err = r.length(1<<(c-0xc4), 1); err != nil {
+			return nil, err
+		}
+		b, _ := r.next(n)
+		return append([]byte{}, b...), nil
+	case 0xc7, 0xc8, 0xc9: // ext 8, 16, 32
+		if n, err = r.length(1<<(c-0xc7), 1); err != nil {
+			return nil, err
+		}
+		return r.ext(n, start)
+	case 0xca:
+		bits, err := r.uint(4)
+		return math.Float32frombits(uint32(bits)), err
+	case 0xcb:
+		bits, err := r.uint(8)
+		return math.Float64frombits(bits), err
+	case 0xcc, 0xcd, 0xce, 0xcf: // uint 8, 16, 32, 64
+		u, err := r.uint(1 << (c - 0xcc))
+		if u > math.MaxInt64 {
+			return u, err
+		}
+		return int64(u), err
+	case 0xd0:
+		u, err := r.uint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := r.uint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := r.uint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := r.uint(8)
+		return int64(u), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext 1, 2, 4, 8, 16
+		return r.ext(1<<(c-0xd4), start)
+	case 0xd9, 0xda, 0xdb: // str 8, 16, 32
+		if n, err = r.length(1<<(c-0xd9), 1); err != nil {
+			return nil, err
+		}
+		b, _ := r.next(n)
+		return string(b), nil
+	case 0xdc, 0xdd: // array 16, 32
+		if n, err = r.length(2<<(c-0xdc), 1); err != nil {
+			return nil, err
+		}
+		return r.array(n)
+	case 0xde, 0xdf: // map 16, 32
+		if n, err = r.length(2<<(c-0xde), 2); err != nil {
+			return nil, err
+		}
+		return r.mapping(n)
+	}
+	r.pos = start
+	return nil, r.errorf("invalid type byte 0x%02x", c)
+}
+
+// ext reads extension type and n bytes of data. Start is offset of the extension, for errors.
+func (r *msgpackReader) ext(n int, start int) (Value, error) {
+	b, err := r.next(1 + n)
+	if err != nil {
+		return nil, err
+	}
+	typ, data := int8(b[0]), b[1:]
+	if typ != -1 {
+		return MsgpackExt{Type: typ, Data: append([]byte{}, data...)}, nil
+	}
+	var sec int64
+	var nsec uint32
+	switch n {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(u&(1<<34-1)), uint32(u>>34)
+	case 12:
+		nsec, sec = binary.BigEndian.Uint32(data), int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		r.pos = start
+		return nil, r.errorf("invalid timestamp length %d", n)
+	}
+	if nsec >= 1e9 {
+		r.pos = start
+		return nil, r.errorf("invalid timestamp nanoseconds %d", nsec)
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), nil
+}
+
+func (r *msgpackReader) array(n int) ([]any, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	a := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	r.depth--
+	return a, nil
+}
+
+func (r *msgpackReader) mapping(n int) (*Map, error) {
+	if err := r.enter(); err != nil {
+		return nil, err
+	}
+	m := New()
+	for i := 0; i < n; i++ {
+		start := r.pos
+		k, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		var key Key
+		switch k := k.(type) {
+		case string:
+			key = k
+		case []byte:
+			key = string(k)
+		case int64:
+			key = strconv.FormatInt(k, 10)
+		case uint64:
+			key = strconv.FormatUint(k, 10)
+		default:
+			r.pos = start
+			return nil, r.errorf("unsupported map key of type %T", k)
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		m.Push(key, v)
+	}
+	r.depth--
+	return m, nil
+}
@@ -0,0 +1,416 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Parser is an incremental push parser for a JSON object, which arrives in chunks.
+// Unlike Decoder, it doesn't block on reading: input is fed with Feed,
+// and the structure built so far is available with Snapshot at any moment.
+// Useful for chunked HTTP responses, or streamed output of language models.
+//
+//	p := jsonmap.NewParser()
+//	for chunk := range chunks {
+//	    if err := p.Feed(chunk); err != nil {
+//	        return err
+//	    }
+//	    render(p.Snapshot())
+//	}
+//	err := p.Close()
+type Parser struct {
+	root  *Map
+	b     builder
+	s     scanner // reused for complete tokens
+	state parserState
+	buf   []byte
+	pos   int   // start of unconsumed input in buf
+	off   int64 // offset of buf[0] in input
+	quote int   // scanned part of incomplete string, from pos
+	err   error
+}
+
+type parserState uint8
+
+const (
+	psValue      parserState = iota // value expected
+	psFirstKey                      // key or '}' expected
+	psKey                           // key expected
+	psColon                         // ':' expected
+	psFirstValue                    // array element or ']' expected
+	psNext                          // ',' or closing bracket expected
+	psDone                          // top-level object is complete
+)
+
+// Partial marks a value in Parser snapshot, which is not complete yet.
+// Value is the best-effort value so far: the received part of a string, the number without incomplete exponent or fraction,
+// or nil if nothing is known yet, like for a key without value, or an incomplete literal.
+// Incomplete objects and arrays are not marked, they are snapshotted as *Map and []any with elements received so far.
+//
+// Partial is encoded to JSON as its Value.
+type Partial struct {
+	Value Value
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (p Partial) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Value)
+}
+
+// NewParser returns a new parser, expecting a JSON object.
+//
+//	p := jsonmap.NewParser()
+func NewParser() *Parser {
+	root := New()
+	return &Parser{root: root, b: builder{root: root}}
+}
+
+// Feed parses the next chunk of input. Chunks can be split at any byte, even inside a string or a UTF-8 sequence.
+// Returns syntax error as soon as it is found. Errors are sticky: after the first error, Feed and Close return it.
+//
+//	err := p.Feed(chunk)
+func (p *Parser) Feed(data []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	// drop consumed input, keeping the incomplete token
+	if p.pos > 0 && p.pos >= len(p.buf)/2 {
+		n := copy(p.buf, p.buf[p.pos:])
+		p.buf = p.buf[:n]
+		p.off += int64(p.pos)
+		p.pos = 0
+	}
+	p.buf = append(p.buf, data...)
+	p.err = p.parse()
+	return p.err
+}
+
+// Close reports error if input is incomplete, or had a syntax error.
+//
+//	err := p.Close()
+func (p *Parser) Close() error {
+	if p.err == nil && p.state != psDone {
+		p.err = &ScanError{Offset: p.off + int64(len(p.buf)), Msg: "unexpected end of input"}
+	}
+	return p.err
+}
+
+// Snapshot returns a copy of the object built so far, with keys in order of input.
+// Incomplete values are marked as Partial, see its description. O(n) time and space.
+//
+//	m := p.Snapshot()
+func (p *Parser) Snapshot() *Map {
+	stack := p.b.stack
+	if len(stack) == 0 {
+		return p.root.Clone()
+	}
+	var child Value
+	var key Key
+	hasChild := false
+
+	// incomplete value in the innermost container
+	top := stack[len(stack)-1]
+	switch {
+	case top.m != nil && (p.state == psColon || p.state == psValue):
+		child, key, hasChild = p.partial(), p.b.key, true
+	case top.m == nil && (p.state == psValue || p.state == psFirstValue) && p.pos < len(p.buf):
+		child, hasChild = p.partial(), true
+	}
+
+	// copy open containers, from the innermost one
+	for i := len(stack) - 1; i >= 0; i-- {
+		f := stack[i]
+		var v Value
+		if f.m != nil {
+			m := f.m.Clone()
+			if hasChild {
+				m.Push(key, child)
+			}
+			v = m
+		} else {
+			a := cloneValue(f.a).([]any)
+			if hasChild {
+				a = append(a, child)
+			}
+			v = a
+		}
+		child, key, hasChild = v, f.key, true
+	}
+	return child.(*Map)
+}
+
+// partial returns best-effort value of incomplete token.
+func (p *Parser) partial() Partial {
+	if p.state == psColon || p.pos >= len(p.buf) {
+		return Partial{}
+	}
+	tok := p.buf[p.pos:]
+	switch c := tok[0]; {
+	case c == '"':
+		if s, ok := partialString(tok); ok {
+			return Partial{Value: s}
+		}
+	case c == '-' || c >= '0' && c <= '9':
+		if f, err := strconv.ParseFloat(strings.TrimRight(string(tok), ".eE+-"), 64); err == nil {
+			return Partial{Value: f}
+		}
+	}
+	return Partial{}
+}
+
+// partialString decodes received part of a string, without incomplete escape or UTF-8 sequence at the end.
+func partialString(tok []byte) (string, bool) {
+	end := len(tok)
+	for i := 1; i < len(tok); i++ {
+		if tok[i] != '\\' {
+			continue
+		}
+		switch {
+		case i+1 >= len(tok):
+			end = i
+		case tok[i+1] != 'u':
+			i++
+			continue
+		case i+6 > len(tok):
+			end = i
+		default:
+			// high surrogate can be followed by the low one
+			r, err := strconv.ParseUint(string(tok[i+2:i+6]), 16, 16)
+			if err == nil && utf16.IsSurrogate(rune(r)) && r < 0xDC00 && i+6 == len(tok) {
+				end = i
+			}
+			i += 5
+			continue
+		}
+		break
+	}
+	for k := 1; k <= utf8.UTFMax-1 && end-k > 0; k++ {
+		if utf8.RuneStart(tok[end-k]) {
+			if !utf8.FullRune(tok[end-k : end]) {
+				end -= k
+			}
+			break
+		}
+	}
+	s := &scanner{buf: append(tok[:end:end], '"')}
+	str, err := s.string()
+	return string(str), err == nil
+}
+
+func (p *Parser) parse() error {
+	for {
+		for p.pos < len(p.buf) && isSpace(p.buf[p.pos]) {
+			p.pos++
+		}
+		if p.pos == len(p.buf) {
+			return nil
+		}
+		c := p.buf[p.pos]
+		switch p.state {
+		case psFirstKey:
+			if c == '}' {
+				if err := p.end(EventEndObject); err != nil {
+					return err
+				}
+				continue
+			}
+			fallthrough
+		case psKey:
+			if c != '"' {
+				return p.errorf("expected string key")
+			}
+			key, ok, err := p.string()
+			if !ok || err != nil {
+				return err
+			}
+			if err := p.event(EventKey, key); err != nil {
+				return err
+			}
+			p.state = psColon
+		case psColon:
+			if c != ':' {
+				return p.errorf("expected ':' after object key")
+			}
+			p.pos++
+			p.state = psValue
+		case psFirstValue:
+			if c == ']' {
+				if err := p.end(EventEndArray); err != nil {
+					return err
+				}
+				continue
+			}
+			fallthrough
+		case psValue:
+			ok, err := p.value(c)
+			if !ok || err != nil {
+				return err
+			}
+		case psNext:
+			object := p.b.stack[len(p.b.stack)-1].m != nil
+			switch {
+			case c == ',' && object:
+				p.pos++
+				p.state = psKey
+			case c == ',':
+				p.pos++
+				p.state = psValue
+			case c == '}' && object:
+				if err := p.end(EventEndObject); err != nil {
+					return err
+				}
+			case c == ']' && !object:
+				if err := p.end(EventEndArray); err != nil {
+					return err
+				}
+			case object:
+				return p.errorf("expected ',' or '}' after object value")
+			default:
+				return p.errorf("expected ',' or ']' after array element")
+			}
+		case psDone:
+			return p.errorf("unexpected data after top-level value")
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// value parses a value starting with c. Returns ok=false if the value is not complete yet.
+func (p *Parser) value(c byte) (ok bool, err error) {
+	if len(p.b.stack) == 0 && c != '{' {
+		return false, errors.New("expected '{'")
+	}
+	switch {
+	case c == '{':
+		p.pos++
+		p.state = psFirstKey
+		return true, p.event(EventStartObject, nil)
+	case c == '[':
+		p.pos++
+		p.state = psFirstValue
+		return true, p.event(EventStartArray, nil)
+	case c == '"':
+		str, ok, err := p.string()
+		if !ok || err != nil {
+			return false, err
+		}
+		return true, p.event(EventValue, str)
+	case c == '-' || c >= '0' && c <= '9':
+		end := p.pos
+		for end < len(p.buf) && strings.IndexByte("0123456789+-.eE", p.buf[end]) >= 0 {
+			end++
+		}
+		if end == len(p.buf) {
+			return false, nil // number can continue
+		}
+		s := p.scanner(end)
+		num, err := s.number()
+		if err != nil {
+			return false, err
+		}
+		f, err := strconv.ParseFloat(string(num), 64)
+		if err != nil {
+			return false, p.errorf("invalid number %s", num)
+		}
+		p.pos = s.pos
+		return true, p.event(EventValue, f)
+	case c == 't' || c == 'f' || c == 'n':
+		var lit string
+		var v Value
+		switch c {
+		case 't':
+			lit, v = "true", true
+		case 'f':
+			lit, v = "false", false
+		case 'n':
+			lit = "null"
+		}
+		rest := p.buf[p.pos:]
+		if len(rest) < len(lit) && bytes.HasPrefix([]byte(lit), rest) {
+			return false, nil
+		}
+		s := p.scanner(len(p.buf))
+		if err := s.literal(lit); err != nil {
+			return false, err
+		}
+		p.pos = s.pos
+		return true, p.event(EventValue, v)
+	}
+	return false, p.errorf("invalid character %q looking for beginning of value", c)
+}
+
+// string parses a string token. Returns ok=false if the closing quote is not received yet.
+func (p *Parser) string() (str string, ok bool, err error) {
+	i := p.pos + 1 + p.quote
+	for {
+		j := bytes.IndexAny(p.buf[i:], `"\`)
+		if j < 0 {
+			p.quote = len(p.buf) - p.pos - 1
+			return "", false, nil
+		}
+		i += j
+		if p.buf[i] == '"' {
+			break
+		}
+		if i+1 == len(p.buf) {
+			p.quote = i - p.pos - 1 // rescan the backslash
+			return "", false, nil
+		}
+		i += 2
+	}
+	p.quote = 0
+	s := p.scanner(i + 1)
+	b, err := s.string()
+	if err != nil {
+		return "", false, err
+	}
+	p.pos = s.pos
+	return string(b), true, nil
+}
+
+// scanner returns scanner of input up to end, at current position.
+func (p *Parser) scanner(end int) *scanner {
+	p.s.buf = p.buf[:end]
+	p.s.pos = p.pos
+	p.s.base = p.off
+	return &p.s
+}
+
+// end closes the innermost object or array.
+func (p *Parser) end(kind EventKind) error {
+	p.pos++
+	if err := p.event(kind, nil); err != nil {
+		return err
+	}
+	if len(p.b.stack) == 0 {
+		p.state = psDone
+	} else {
+		p.state = psNext
+	}
+	return nil
+}
+
+func (p *Parser) event(kind EventKind, v Value) error {
+	e := Event{Kind: kind, Value: v, Offset: p.off + int64(p.pos)}
+	if kind == EventKey {
+		e.Key = v.(string)
+		e.Value = nil
+	}
+	if kind == EventValue {
+		p.state = psNext
+	}
+	return p.b.event(&e)
+}
+
+func (p *Parser) errorf(format string, args ...any) error {
+	return &ScanError{Offset: p.off + int64(p.pos), Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,167 @@
+package jsonmap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a location of a value inside nested maps and arrays.
+// Each step is either a Key (string) for map elements, or an int for array elements.
+//
+//	path := jsonmap.Path{"store", "book", 0, "title"}
+//	fmt.Println(path) // /store/book/0/title
+type Path []any
+
+// String returns the path as JSON Pointer (RFC 6901). O(n) time.
+//
+//	s := path.String()
+func (p Path) String() string {
+	var b strings.Builder
+	for _, step := range p {
+		b.WriteByte('/')
+		switch s := step.(type) {
+		case int:
+			b.WriteString(strconv.Itoa(s))
+		case string:
+			b.WriteString(pointerEscaper.Replace(s))
+		}
+	}
+	return b.String()
+}
+
+// Append returns a new path with the step added to the end.
+// The original path is not modified.
+//
+//	child := path.Append("key")
+func (p Path) Append(step any) Path {
+	p2 := make(Path, len(p), len(p)+1)
+	copy(p2, p)
+	return append(p2, step)
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Normalized returns the path as normalized JSONPath (RFC 9535), like $['store']['book'][0].
+//
+//	s := path.Normalized()
+func (p Path) Normalized() string {
+	var b strings.Builder
+	b.WriteByte('$')
+	for _, step := range p {
+		switch s := step.(type) {
+		case int:
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(s))
+			b.WriteByte(']')
+		case string:
+			b.WriteString("['")
+			for _, r := range s {
+				switch r {
+				case '\b':
+					b.WriteString(`\b`)
+				case '\f':
+					b.WriteString(`\f`)
+				case '\n':
+					b.WriteString(`\n`)
+				case '\r':
+					b.WriteString(`\r`)
+				case '\t':
+					b.WriteString(`\t`)
+				case '\'':
+					b.WriteString(`\'`)
+				case '\\':
+					b.WriteString(`\\`)
+				default:
+					if r < 0x20 {
+						fmt.Fprintf(&b, `\u%04x`, r)
+					} else {
+						b.WriteRune(r)
+					}
+				}
+			}
+			b.WriteString("']")
+		}
+	}
+	return b.String()
+}
+
+// Get returns the value at the path inside nested maps and arrays of root.
+// Returns ok=false if the path does not exist.
+// O(len(path)) time.
+//
+//	value, ok := path.Get(m)
+func (p Path) Get(root Value) (value Value, ok bool) {
+	value = root
+	for _, step := range p {
+		switch s := step.(type) {
+		case string:
+			m, isMap := value.(*Map)
+			if !isMap {
+				return nil, false
+			}
+			if value, ok = m.Get(s); !ok {
+				return nil, false
+			}
+		case int:
+			a, isArray := value.([]any)
+			if !isArray || s < 0 || s >= len(a) {
+				return nil, false
+			}
+			value = a[s]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Set replaces the value at the path inside nested maps and arrays of root.
+// Parent of the path must exist. Map keys are added if missing, array indices must be in range.
+// Returns false if the value could not be set, including the case of empty path.
+// O(len(path)) time.
+//
+//	ok := path.Set(m, value)
+func (p Path) Set(root Value, value Value) bool {
+	if len(p) == 0 {
+		return false
+	}
+	parent, ok := p[:len(p)-1].Get(root)
+	if !ok {
+		return false
+	}
+	switch s := p[len(p)-1].(type) {
+	case string:
+		m, ok := parent.(*Map)
+		if !ok {
+			return false
+		}
+		m.Set(s, value)
+		return true
+	case int:
+		a, ok := parent.([]any)
+		if !ok || s < 0 || s >= len(a) {
+			return false
+		}
+		a[s] = value
+		return true
+	}
+	return false
+}
+
+// parsePointer splits JSON Pointer (RFC 6901) into unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with '/'", pointer)
+	}
+	parts := strings.Split(pointer[1:], "/")
+	for i, part := range parts {
+		parts[i] = pointerUnescaper.Replace(part)
+	}
+	return parts, nil
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
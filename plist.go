@@ -0,0 +1,837 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// PlistUID is UID of property list, used by NSKeyedArchiver.
+// In XML it's written as <dict> with single "CF$UID" key.
+type PlistUID uint64
+
+// plistEpoch is reference date of binary property list dates, 2001-01-01 UTC, in Unix seconds.
+const plistEpoch = 978307200
+
+// UnmarshalPlist parses XML or binary (bplist00) property list with <dict> root, and stores it in m, keeping order of keys.
+// Format is detected by content.
+//
+// Dictionaries are decoded as *Map, arrays as []any, integers as int64, or uint64 if they don't fit,
+// reals as float64, dates as time.Time in UTC, and data as []byte.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := jsonmap.UnmarshalPlist(data, m)
+func UnmarshalPlist(data []byte, m *Map) error {
+	var v Value
+	var err error
+	if bytes.HasPrefix(data, []byte("bplist00")) {
+		v, err = unmarshalBinaryPlist(data)
+	} else {
+		v, err = unmarshalXMLPlist(data)
+	}
+	if err != nil {
+		return err
+	}
+	root, ok := v.(*Map)
+	if !ok {
+		return fmt.Errorf("jsonmap: plist: root is %T, not a dict", v)
+	}
+	for el := root.First(); el != nil; el = el.Next() {
+		m.Push(el.key, el.value)
+	}
+	return nil
+}
+
+// plistNormalize converts integers to int64 or uint64, json.Number to int64 or float64,
+// and unknown types through JSON. Property lists have no null, so nil is an error.
+func plistNormalize(v Value) (Value, error) {
+	switch n := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("jsonmap: plist: null is not supported")
+	case int:
+		return int64(n), nil
+	case int8:
+		return int64(n), nil
+	case int16:
+		return int64(n), nil
+	case int32:
+		return int64(n), nil
+	case uint8:
+		return int64(n), nil
+	case uint16:
+		return int64(n), nil
+	case uint32:
+		return int64(n), nil
+	case uint:
+		return plistNormalize(uint64(n))
+	case uint64:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("jsonmap: plist: invalid number %s", n)
+		}
+		return f, nil
+	case int64, float64, float32, string, bool, []byte, time.Time, PlistUID, *Map, []any:
+		return v, nil
+	}
+	v, err := normalizeValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return plistNormalize(v)
+}
+
+// MarshalPlist returns XML property list of the map, keeping order of keys, indented with tabs as Apple tools do.
+//
+// Nested maps are written as <dict>, []any as <array>, time.Time as <date> with seconds precision, and []byte as <data>.
+// Other types are converted through JSON first. nil is not supported.
+//
+//	data, err := jsonmap.MarshalPlist(m)
+func MarshalPlist(m *Map) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n" +
+		`<plist version="1.0">` + "\n")
+	if err := plistXMLValue(&b, m, 0); err != nil {
+		return nil, err
+	}
+	b.WriteString("</plist>\n")
+	return b.Bytes(), nil
+}
+
+func plistXMLValue(b *bytes.Buffer, v Value, depth int) error {
+	v, err := plistNormalize(v)
+	if err != nil {
+		return err
+	}
+	indent := strings.Repeat("\t", depth)
+	b.WriteString(indent)
+	switch v := v.(type) {
+	case *Map:
+		if v.Len() == 0 {
+			b.WriteString("<dict/>\n")
+			return nil
+		}
+		b.WriteString("<dict>\n")
+		for el := v.First(); el != nil; el = el.Next() {
+			if err := xmlCheckText("plist", el.key); err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "%s\t<key>%s</key>\n", indent, xmlTextEscaper.Replace(el.key))
+			if err := plistXMLValue(b, el.value, depth+1); err != nil {
+				return err
+			}
+		}
+		b.WriteString(indent + "</dict>\n")
+		return nil
+	case []any:
+		if len(v) == 0 {
+			b.WriteString("<array/>\n")
+			return nil
+		}
+		b.WriteString("<array>\n")
+		for _, item := range v {
+			if err := plistXMLValue(b, item, depth+1); err != nil {
+				return err
+			}
+		}
+		b.WriteString(indent + "</array>\n")
+		return nil
+	case PlistUID:
+		fmt.Fprintf(b, "<dict>\n%s\t<key>CF$UID</key>\n%s\t<integer>%d</integer>\n%s</dict>\n", indent, indent, v, indent)
+		return nil
+	case string:
+		if err := xmlCheckText("plist", v); err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "<string>%s</string>\n", xmlTextEscaper.Replace(v))
+	case bool:
+		fmt.Fprintf(b, "<%t/>\n", v)
+	case int64:
+		fmt.Fprintf(b, "<integer>%d</integer>\n", v)
+	case uint64:
+		fmt.Fprintf(b, "<integer>%d</integer>\n", v)
+	case float32:
+		fmt.Fprintf(b, "<real>%s</real>\n", plistReal(float64(v), 32))
+	case float64:
+		fmt.Fprintf(b, "<real>%s</real>\n", plistReal(v, 64))
+	case time.Time:
+		fmt.Fprintf(b, "<date>%s</date>\n", v.UTC().Format("2006-01-02T15:04:05Z"))
+	case []byte:
+		fmt.Fprintf(b, "<data>%s</data>\n", base64.StdEncoding.EncodeToString(v))
+	}
+	return nil
+}
+
+func plistReal(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "+infinity"
+	case math.IsInf(f, -1):
+		return "-infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+func unmarshalXMLPlist(data []byte) (Value, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	tok, err := plistNextElement(d)
+	if err != nil {
+		return nil, err
+	}
+	start, ok := tok.(xml.StartElement)
+	if !ok {
+		return nil, xmlSyntaxError(d, "expected element")
+	}
+	if start.Name.Local == "plist" {
+		if tok, err = plistNextElement(d); err != nil {
+			return nil, err
+		}
+		if start, ok = tok.(xml.StartElement); !ok {
+			return nil, xmlSyntaxError(d, "empty plist")
+		}
+		v, err := plistXMLRead(d, start, 0)
+		if err != nil {
+			return nil, err
+		}
+		if tok, err = plistNextElement(d); err != nil {
+			return nil, err
+		}
+		if _, ok := tok.(xml.EndElement); !ok {
+			return nil, xmlSyntaxError(d, "plist must have a single root value")
+		}
+		return v, nil
+	}
+	return plistXMLRead(d, start, 0)
+}
+
+// plistNextElement returns next start or end element, skipping whitespace, comments and directives.
+func plistNextElement(d *xml.Decoder) (xml.Token, error) {
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, xmlSyntaxError(d, "unexpected EOF")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement, xml.EndElement:
+			return tok, nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return nil, xmlSyntaxError(d, "unexpected text")
+			}
+		}
+	}
+}
+
+// plistXMLText reads text content till the end of current element.
+func plistXMLText(d *xml.Decoder) (string, error) {
+	var b strings.Builder
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return "", xmlSyntaxError(d, "unexpected EOF")
+		}
+		if err != nil {
+			return "", err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			b.Write(tok)
+		case xml.StartElement:
+			return "", xmlSyntaxError(d, fmt.Sprintf("unexpected element <%s> in text", tok.Name.Local))
+		case xml.EndElement:
+			return b.String(), nil
+		}
+	}
+}
+
+// plistXMLRead reads value of the element. Depth is the number of its ancestors, limited to maxDepth.
+func plistXMLRead(d *xml.Decoder, start xml.StartElement, depth int) (Value, error) {
+	if depth == maxDepth {
+		return nil, xmlSyntaxError(d, fmt.Sprintf("exceeded max depth %d", maxDepth))
+	}
+	switch start.Name.Local {
+	case "dict":
+		m := New()
+		for {
+			tok, err := plistNextElement(d)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := tok.(xml.StartElement)
+			if !ok {
+				break
+			}
+			if key.Name.Local != "key" {
+				return nil, xmlSyntaxError(d, fmt.Sprintf("expected <key> in <dict>, got <%s>", key.Name.Local))
+			}
+			k, err := plistXMLText(d)
+			if err != nil {
+				return nil, err
+			}
+			if tok, err = plistNextElement(d); err != nil {
+				return nil, err
+			}
+			valueStart, ok := tok.(xml.StartElement)
+			if !ok {
+				return nil, xmlSyntaxError(d, fmt.Sprintf("missing value of key %q", k))
+			}
+			v, err := plistXMLRead(d, valueStart, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(k, v)
+		}
+		if m.Len() == 1 {
+			if uid, ok := m.First().Value().(int64); ok && m.First().Key() == "CF$UID" && uid >= 0 {
+				return PlistUID(uid), nil
+			}
+		}
+		return m, nil
+	case "array":
+		a := make([]any, 0)
+		for {
+			tok, err := plistNextElement(d)
+			if err != nil {
+				return nil, err
+			}
+			itemStart, ok := tok.(xml.StartElement)
+			if !ok {
+				return a, nil
+			}
+			v, err := plistXMLRead(d, itemStart, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+	}
+
+	text, err := plistXMLText(d)
+	if err != nil {
+		return nil, err
+	}
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		text = strings.TrimSpace(text)
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(text, 10, 64); err == nil {
+			return n, nil
+		}
+	case "real":
+		if f, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+			return f, nil
+		}
+	case "true", "false":
+		if strings.TrimSpace(text) == "" {
+			return start.Name.Local == "true", nil
+		}
+	case "date":
+		if t, err := time.Parse(time.RFC3339, strings.TrimSpace(text)); err == nil {
+			return t.UTC(), nil
+		}
+	case "data":
+		text = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, text)
+		if data, err := base64.StdEncoding.DecodeString(text); err == nil {
+			return data, nil
+		}
+	default:
+		return nil, xmlSyntaxError(d, fmt.Sprintf("unknown element <%s>", start.Name.Local))
+	}
+	return nil, xmlSyntaxError(d, fmt.Sprintf("invalid <%s> value %q", start.Name.Local, text))
+}
+
+// MarshalBinaryPlist returns binary property list (bplist00) of the map, keeping order of keys.
+// Equal strings and numbers are stored once.
+//
+// Nested maps are written as dicts, []any as arrays, time.Time as dates, []byte as data, and PlistUID as UID.
+// Other types are converted through JSON first. nil is not supported.
+//
+//	data, err := jsonmap.MarshalBinaryPlist(m)
+func MarshalBinaryPlist(m *Map) ([]byte, error) {
+	w := &bplistWriter{unique: map[any]int{}}
+	if _, err := w.add(m); err != nil {
+		return nil, err
+	}
+
+	refSize := bplistIntSize(uint64(len(w.objects)))
+	buf := []byte("bplist00")
+	offsets := make([]uint64, len(w.objects))
+	for i, obj := range w.objects {
+		offsets[i] = uint64(len(buf))
+		buf = w.object(buf, obj, refSize)
+	}
+	tableOffset := uint64(len(buf))
+	offsetSize := bplistIntSize(tableOffset)
+	for _, offset := range offsets {
+		buf = bplistAppendUint(buf, offset, offsetSize)
+	}
+	buf = append(buf, 0, 0, 0, 0, 0, 0, byte(offsetSize), byte(refSize))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(len(w.objects)))
+	buf = binary.BigEndian.AppendUint64(buf, 0)
+	buf = binary.BigEndian.AppendUint64(buf, tableOffset)
+	return buf, nil
+}
+
+// bplistObject is a value to write, with references to objects of array items, or dict keys followed by values.
+type bplistObject struct {
+	value Value
+	refs  []int
+}
+
+// bplistDataKey and bplistDateKey are keys of unique data and dates, as []byte and time.Time can't be compared directly.
+type (
+	bplistDataKey string
+	bplistDateKey struct {
+		sec  int64
+		nsec int
+	}
+)
+
+type bplistWriter struct {
+	objects []bplistObject
+	unique  map[any]int // index of scalar objects by value
+}
+
+// add adds the value and its children as objects, and returns its index.
+func (w *bplistWriter) add(v Value) (int, error) {
+	v, err := plistNormalize(v)
+	if err != nil {
+		return 0, err
+	}
+	var key any
+	switch v := v.(type) {
+	case *Map:
+		i := len(w.objects)
+		w.objects = append(w.objects, bplistObject{value: v})
+		refs := make([]int, 0, 2*v.Len())
+		for el := v.First(); el != nil; el = el.Next() {
+			ref, err := w.add(el.key)
+			if err != nil {
+				return 0, err
+			}
+			refs = append(refs, ref)
+		}
+		for el := v.First(); el != nil; el = el.Next() {
+			ref, err := w.add(el.value)
+			if err != nil {
+				return 0, err
+			}
+			refs = append(refs, ref)
+		}
+		w.objects[i].refs = refs
+		return i, nil
+	case []any:
+		i := len(w.objects)
+		w.objects = append(w.objects, bplistObject{value: v})
+		refs := make([]int, 0, len(v))
+		for _, item := range v {
+			ref, err := w.add(item)
+			if err != nil {
+				return 0, err
+			}
+			refs = append(refs, ref)
+		}
+		w.objects[i].refs = refs
+		return i, nil
+	case []byte:
+		key = bplistDataKey(v)
+	case time.Time:
+		key = bplistDateKey{v.Unix(), v.Nanosecond()}
+	default:
+		key = v
+	}
+	if i, ok := w.unique[key]; ok {
+		return i, nil
+	}
+	i := len(w.objects)
+	w.objects = append(w.objects, bplistObject{value: v})
+	w.unique[key] = i
+	return i, nil
+}
+
+func (w *bplistWriter) object(buf []byte, obj bplistObject, refSize int) []byte {
+	switch v := obj.value.(type) {
+	case *Map:
+		buf = bplistAppendMarker(buf, 0xd0, len(obj.refs)/2)
+	case []any:
+		buf = bplistAppendMarker(buf, 0xa0, len(obj.refs))
+	case bool:
+		if v {
+			return append(buf, 0x09)
+		}
+		return append(buf, 0x08)
+	case int64:
+		return bplistAppendInt(buf, v)
+	case uint64:
+		buf = append(buf, 0x14, 0, 0, 0, 0, 0, 0, 0, 0)
+		return binary.BigEndian.AppendUint64(buf, v)
+	case float32:
+		buf = append(buf, 0x22)
+		return binary.BigEndian.AppendUint32(buf, math.Float32bits(v))
+	case float64:
+		buf = append(buf, 0x23)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+	case time.Time:
+		seconds := float64(v.Unix()-plistEpoch) + float64(v.Nanosecond())/1e9
+		buf = append(buf, 0x33)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(seconds))
+	case []byte:
+		buf = bplistAppendMarker(buf, 0x40, len(v))
+		return append(buf, v...)
+	case string:
+		ascii := true
+		for i := 0; i < len(v); i++ {
+			if v[i] >= 0x80 {
+				ascii = false
+				break
+			}
+		}
+		if ascii {
+			buf = bplistAppendMarker(buf, 0x50, len(v))
+			return append(buf, v...)
+		}
+		units := utf16.Encode([]rune(v))
+		buf = bplistAppendMarker(buf, 0x60, len(units))
+		for _, u := range units {
+			buf = binary.BigEndian.AppendUint16(buf, u)
+		}
+		return buf
+	case PlistUID:
+		size := bplistIntSize(uint64(v))
+		buf = append(buf, 0x80|byte(size-1))
+		return bplistAppendUint(buf, uint64(v), size)
+	}
+	for _, ref := range obj.refs {
+		buf = bplistAppendUint(buf, uint64(ref), refSize)
+	}
+	return buf
+}
+
+// bplistIntSize returns number of bytes, 1, 2, 4 or 8, enough to store n.
+func bplistIntSize(n uint64) int {
+	switch {
+	case n <= math.MaxUint8:
+		return 1
+	case n <= math.MaxUint16:
+		return 2
+	case n <= math.MaxUint32:
+		return 4
+	}
+	return 8
+}
+
+func bplistAppendUint(buf []byte, n uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		buf = append(buf, byte(n>>(8*i)))
+	}
+	return buf
+}
+
+// bplistAppendInt appends integer object. Only 8-byte integers are signed.
+func bplistAppendInt(buf []byte, n int64) []byte {
+	size := 8
+	if n >= 0 {
+		size = bplistIntSize(uint64(n))
+	}
+	switch size {
+	case 1:
+		buf = append(buf, 0x10)
+	case 2:
+		buf = append(buf, 0x11)
+	case 4:
+		buf = append(buf, 0x12)
+	default:
+		buf = append(buf, 0x13)
+	}
+	return bplistAppendUint(buf, uint64(n), size)
+}
+
+// bplistAppendMarker appends marker with count, which is stored in low 4 bits, or as integer object after it.
+func bplistAppendMarker(buf []byte, marker byte, count int) []byte {
+	if count < 15 {
+		return append(buf, marker|byte(count))
+	}
+	return bplistAppendInt(append(buf, marker|0x0f), int64(count))
+}
+
+type bplistReader struct {
+	data      []byte
+	offsets   []uint64
+	refSize   int
+	budget    int    // number of values left to decode, to limit expansion of shared objects
+	decoding  []bool // objects being decoded, to detect cycles
+	tableSize uint64
+}
+
+func (r *bplistReader) errorf(offset uint64, format string, args ...any) error {
+	return &FormatError{Format: "bplist", Offset: int64(offset), Msg: fmt.Sprintf(format, args...)}
+}
+
+func unmarshalBinaryPlist(data []byte) (Value, error) {
+	r := &bplistReader{data: data, budget: len(data)}
+	if len(data) < 8+32 {
+		return nil, r.errorf(uint64(len(data)), "unexpected end of input")
+	}
+	trailer := data[len(data)-32:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	count := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:])
+	trailerOffset := uint64(len(data) - 32)
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 ||
+		count == 0 || top >= count || tableOffset < 8 || tableOffset > trailerOffset ||
+		count > (trailerOffset-tableOffset)/uint64(offsetSize) {
+		return nil, r.errorf(trailerOffset, "invalid trailer")
+	}
+	r.refSize = refSize
+	r.offsets = make([]uint64, count)
+	for i := range r.offsets {
+		start := tableOffset + uint64(i*offsetSize)
+		r.offsets[i] = bplistUint(data[start : start+uint64(offsetSize)])
+		if r.offsets[i] < 8 || r.offsets[i] >= tableOffset {
+			return nil, r.errorf(start, "invalid offset of object %d", i)
+		}
+	}
+	r.tableSize = tableOffset
+	r.decoding = make([]bool, count)
+	return r.object(top)
+}
+
+func bplistUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// next returns n bytes at offset, within objects area.
+func (r *bplistReader) next(offset, n uint64) ([]byte, error) {
+	if n > r.tableSize || offset > r.tableSize-n {
+		return nil, r.errorf(offset, "unexpected end of object")
+	}
+	return r.data[offset : offset+n], nil
+}
+
+// count returns count from low bits of the marker, or from integer object after it, and offset of content.
+func (r *bplistReader) count(offset uint64, marker byte) (uint64, uint64, error) {
+	offset++
+	if marker&0x0f != 0x0f {
+		return uint64(marker & 0x0f), offset, nil
+	}
+	b, err := r.next(offset, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if b[0]&0xf0 != 0x10 || b[0]&0x0f > 3 {
+		return 0, 0, r.errorf(offset, "invalid count")
+	}
+	size := uint64(1) << (b[0] & 0x0f)
+	n, err := r.next(offset+1, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	return bplistUint(n), offset + 1 + size, nil
+}
+
+func (r *bplistReader) object(index uint64) (Value, error) {
+	if r.budget--; r.budget < 0 {
+		return nil, r.errorf(r.offsets[index], "too many objects")
+	}
+	if r.decoding[index] {
+		return nil, r.errorf(r.offsets[index], "cyclic reference to object %d", index)
+	}
+	offset := r.offsets[index]
+	b, err := r.next(offset, 1)
+	if err != nil {
+		return nil, err
+	}
+	marker := b[0]
+	switch marker >> 4 {
+	case 0x0:
+		switch marker {
+		case 0x00:
+			return nil, nil
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+	case 0x1:
+		size := uint64(1) << (marker & 0x0f)
+		if size > 16 {
+			break
+		}
+		b, err := r.next(offset+1, size)
+		if err != nil {
+			return nil, err
+		}
+		switch size {
+		case 8:
+			return int64(bplistUint(b)), nil
+		case 16:
+			high, low := bplistUint(b[:8]), bplistUint(b[8:])
+			switch {
+			case high == 0 && low > math.MaxInt64:
+				return low, nil
+			case high == 0 || high == math.MaxUint64 && int64(low) < 0:
+				return int64(low), nil
+			}
+			return nil, r.errorf(offset, "integer overflows 64 bits")
+		}
+		return int64(bplistUint(b)), nil
+	case 0x2:
+		switch marker & 0x0f {
+		case 2:
+			b, err := r.next(offset+1, 4)
+			if err != nil {
+				return nil, err
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 3:
+			b, err := r.next(offset+1, 8)
+			if err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+	case 0x3:
+		if marker != 0x33 {
+			break
+		}
+		b, err := r.next(offset+1, 8)
+		if err != nil {
+			return nil, err
+		}
+		seconds := math.Float64frombits(binary.BigEndian.Uint64(b))
+		if math.IsNaN(seconds) || math.Abs(seconds) > 1<<62 {
+			return nil, r.errorf(offset, "invalid date")
+		}
+		whole := math.Floor(seconds)
+		t := time.Unix(int64(whole)+plistEpoch, int64(math.Round((seconds-whole)*1e9)))
+		return t.Round(time.Microsecond).UTC(), nil
+	case 0x4, 0x5, 0x6:
+		n, start, err := r.count(offset, marker)
+		if err != nil {
+			return nil, err
+		}
+		if marker>>4 == 0x6 {
+			if n > r.tableSize/2 {
+				return nil, r.errorf(offset, "unexpected end of object")
+			}
+			b, err := r.next(start, 2*n)
+			if err != nil {
+				return nil, err
+			}
+			units := make([]uint16, n)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(b[2*i:])
+			}
+			return string(utf16.Decode(units)), nil
+		}
+		b, err := r.next(start, n)
+		if err != nil {
+			return nil, err
+		}
+		if marker>>4 == 0x5 {
+			return string(b), nil
+		}
+		return append([]byte{}, b...), nil
+	case 0x8:
+		b, err := r.next(offset+1, uint64(marker&0x0f)+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 8 {
+			return nil, r.errorf(offset, "invalid UID size")
+		}
+		return PlistUID(bplistUint(b)), nil
+	case 0xa, 0xd:
+		n, start, err := r.count(offset, marker)
+		if err != nil {
+			return nil, err
+		}
+		refCount := n
+		if marker>>4 == 0xd {
+			refCount *= 2
+		}
+		if n > r.tableSize || refCount > r.tableSize/uint64(r.refSize) {
+			return nil, r.errorf(offset, "unexpected end of object")
+		}
+		b, err := r.next(start, refCount*uint64(r.refSize))
+		if err != nil {
+			return nil, err
+		}
+		refs := make([]uint64, refCount)
+		for i := range refs {
+			refs[i] = bplistUint(b[i*r.refSize : (i+1)*r.refSize])
+			if refs[i] >= uint64(len(r.offsets)) {
+				return nil, r.errorf(start+uint64(i*r.refSize), "invalid reference %d", refs[i])
+			}
+		}
+
+		r.decoding[index] = true
+		defer func() { r.decoding[index] = false }()
+		if marker>>4 == 0xa {
+			a := make([]any, 0, n)
+			for _, ref := range refs {
+				v, err := r.object(ref)
+				if err != nil {
+					return nil, err
+				}
+				a = append(a, v)
+			}
+			return a, nil
+		}
+		m := New()
+		for i := uint64(0); i < n; i++ {
+			k, err := r.object(refs[i])
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, r.errorf(r.offsets[refs[i]], "dict key is %T, not a string", k)
+			}
+			v, err := r.object(refs[n+i])
+			if err != nil {
+				return nil, err
+			}
+			m.Set(key, v)
+		}
+		return m, nil
+	}
+	return nil, r.errorf(offset, "invalid object marker 0x%02x", marker)
+}
@@ -0,0 +1,222 @@
+package jsonmap
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// KeyValueOptions configures reading and writing of flat key-value formats, Java .properties and dotenv.
+// Zero value is usable, and reads keys as is.
+//
+//	opts := jsonmap.KeyValueOptions{Unflatten: true, Separator: "__"}
+type KeyValueOptions struct {
+	// Unflatten makes readers rebuild nested maps and arrays from keys joined by Separator, like "db.hosts.0".
+	// Writers always write nested maps and arrays with joined keys.
+	Unflatten bool
+
+	// Separator of nested keys. Default is ".".
+	Separator string
+}
+
+func (o KeyValueOptions) flattener() Flattener {
+	sep := o.Separator
+	if sep == "" {
+		sep = "."
+	}
+	return Flattener{Separator: sep, Arrays: true}
+}
+
+// store stores flat map in m, unflattening it if requested.
+func (o KeyValueOptions) store(flat, m *Map) error {
+	if o.Unflatten {
+		var err error
+		if flat, err = o.flattener().Unflatten(flat); err != nil {
+			return err
+		}
+	}
+	for el := flat.First(); el != nil; el = el.Next() {
+		m.Push(el.key, el.value)
+	}
+	return nil
+}
+
+// UnmarshalProperties parses Java .properties file, and stores it in m, keeping order of keys.
+// Shortcut for KeyValueOptions{}.UnmarshalProperties(data, m).
+//
+//	err := jsonmap.UnmarshalProperties(data, m)
+func UnmarshalProperties(data []byte, m *Map) error {
+	return KeyValueOptions{}.UnmarshalProperties(data, m)
+}
+
+// MarshalProperties returns Java .properties encoding of the map, with nested keys joined by ".".
+// Shortcut for KeyValueOptions{}.MarshalProperties(m).
+//
+//	data, err := jsonmap.MarshalProperties(m)
+func MarshalProperties(m *Map) ([]byte, error) {
+	return KeyValueOptions{}.MarshalProperties(m)
+}
+
+// UnmarshalProperties parses Java .properties file in UTF-8, and stores it in m, keeping order of keys.
+// It follows rules of java.util.Properties.load: lines starting with "#" or "!" are comments,
+// keys end at first unescaped "=", ":" or whitespace, lines ending with odd number of backslashes continue
+// on the next line, and escapes \t, \n, \r, \f and \uXXXX are decoded. Values are strings.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := opts.UnmarshalProperties(data, m)
+func (o KeyValueOptions) UnmarshalProperties(data []byte, m *Map) error {
+	flat := New()
+	lines := textLines(data)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		start := i + 1
+		for propertiesContinued(line) {
+			line = line[:len(line)-1]
+			if i+1 == len(lines) {
+				break
+			}
+			i++
+			line += strings.TrimLeft(lines[i], " \t\f")
+		}
+
+		end := 0
+		for end < len(line) && !strings.ContainsRune("=: \t\f", rune(line[end])) {
+			if line[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end > len(line) {
+			end = len(line)
+		}
+		rest := strings.TrimLeft(line[end:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+		key, err := propertiesUnescape(line[:end])
+		if err != nil {
+			return &LineError{Format: "properties", Line: start, Msg: err.Error()}
+		}
+		value, err := propertiesUnescape(rest)
+		if err != nil {
+			return &LineError{Format: "properties", Line: start, Msg: err.Error()}
+		}
+		flat.Set(key, value)
+	}
+	return o.store(flat, m)
+}
+
+// propertiesContinued tells if the line ends with odd number of backslashes.
+func propertiesContinued(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+func propertiesUnescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	var units []uint16 // pending \uXXXX escapes, to join surrogate pairs
+	flush := func() {
+		b.WriteString(string(utf16.Decode(units)))
+		units = units[:0]
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 == len(s) {
+			flush()
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		c = s[i]
+		if c == 'u' {
+			if i+5 > len(s) {
+				return "", fmt.Errorf(`malformed \uxxxx escape`)
+			}
+			n, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf(`malformed \uxxxx escape`)
+			}
+			units = append(units, uint16(n))
+			i += 4
+			continue
+		}
+		flush()
+		switch c {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+	return b.String(), nil
+}
+
+// MarshalProperties returns Java .properties encoding of the map in UTF-8, with "key=value" lines.
+// Nested maps and arrays are written with keys joined by Separator. nil is written as empty value,
+// strings as is, and other values in JSON format.
+// Special characters are escaped like java.util.Properties.store does, except non-ASCII characters, which are kept.
+//
+//	data, err := opts.MarshalProperties(m)
+func (o KeyValueOptions) MarshalProperties(m *Map) ([]byte, error) {
+	var b bytes.Buffer
+	for el := o.flattener().Flatten(m).First(); el != nil; el = el.Next() {
+		v, err := normalizeValue(el.value)
+		if err != nil {
+			return nil, err
+		}
+		text, err := formatText(v)
+		if err != nil {
+			return nil, err
+		}
+		propertiesEscape(&b, el.key, true)
+		b.WriteByte('=')
+		propertiesEscape(&b, text, false)
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+func propertiesEscape(b *bytes.Buffer, s string, key bool) {
+	for i, r := range s {
+		switch r {
+		case ' ':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(' ')
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\\', '=', ':', '#', '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+}
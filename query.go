@@ -0,0 +1,187 @@
+package jsonmap
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EncodeQuery returns URL query or form encoding of the map, with parameters in order of elements,
+// unlike url.Values.Encode, which sorts them.
+// Nested maps and arrays are expanded in qs/Rails style, like "a[b][0]=x". Empty nested maps and arrays are skipped.
+// nil is written as empty value, strings as is, and other values in JSON format.
+// Empty keys are written as is, but ParseQuery can't read them back.
+//
+//	query := jsonmap.EncodeQuery(m) // {"b":1,"a":{"x":["y"]}} -> "b=1&a[x][0]=y"
+func EncodeQuery(m *Map) string {
+	var b strings.Builder
+	for el := m.First(); el != nil; el = el.Next() {
+		encodeQuery(&b, url.QueryEscape(el.key), el.value)
+	}
+	return b.String()
+}
+
+// encodeQuery writes nested value, prefix is its key, which can be empty.
+func encodeQuery(b *strings.Builder, prefix string, v Value) {
+	if n, err := normalizeValue(v); err == nil {
+		v = n
+	}
+	switch v := v.(type) {
+	case *Map:
+		for el := v.First(); el != nil; el = el.Next() {
+			encodeQuery(b, prefix+"["+url.QueryEscape(el.key)+"]", el.value)
+		}
+		return
+	case []any:
+		for i, item := range v {
+			encodeQuery(b, prefix+"["+strconv.Itoa(i)+"]", item)
+		}
+		return
+	}
+	text, err := formatText(v)
+	if err != nil {
+		text = fmt.Sprint(v)
+	}
+	if b.Len() > 0 {
+		b.WriteByte('&')
+	}
+	b.WriteString(prefix)
+	b.WriteByte('=')
+	b.WriteString(url.QueryEscape(text))
+}
+
+// ParseQuery parses URL query or form encoding, and returns it as a map, keeping order of parameters at every level.
+// Keys like "a[b][0]" build nested maps and arrays, and "a[]" appends to array.
+// Repeated keys become arrays. Values are strings.
+// Brackets escaped as %5B and %5D are part of key names. Empty keys, like in "=x", are an error.
+//
+//	m, err := jsonmap.ParseQuery("b=1&a[x][]=y") // {"b":"1","a":{"x":["y"]}}
+func ParseQuery(query string) (*Map, error) {
+	m := New()
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		if rawKey == "" {
+			return nil, fmt.Errorf("jsonmap: query: empty key in %q", pair)
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, fmt.Errorf("jsonmap: query: %w", err)
+		}
+		parts, err := queryKey(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("jsonmap: query: %w", err)
+		}
+		child, _ := m.Get(parts[0])
+		v, err := queryPut(child, parts[1:], value)
+		if err != nil {
+			return nil, fmt.Errorf("jsonmap: query: key %q: %w", rawKey, err)
+		}
+		m.Set(parts[0], v)
+	}
+	return m, nil
+}
+
+// queryKey splits raw key like "a[b][]" into unescaped parts "a", "b", "".
+// Keys not in this form are taken as a whole. Nesting is limited to maxDepth, as queryPut recurses on parts.
+func queryKey(raw string) ([]string, error) {
+	var parts []string
+	rest := raw
+	if i := strings.IndexByte(raw, '['); i > 0 {
+		parts = append(parts, raw[:i])
+		rest = raw[i:]
+		for rest != "" && rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				break
+			}
+			if len(parts) > maxDepth {
+				return nil, fmt.Errorf("key has more than %d nested parts", maxDepth)
+			}
+			parts = append(parts, rest[1:end])
+			rest = rest[end+1:]
+		}
+	}
+	if rest != "" {
+		parts = []string{raw}
+	}
+	for i, part := range parts {
+		var err error
+		if parts[i], err = url.QueryUnescape(part); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
+
+// queryPut puts value by key parts into the node, and returns the updated node.
+// Node is nil if absent.
+func queryPut(node Value, parts []string, value string) (Value, error) {
+	if len(parts) == 0 {
+		switch node := node.(type) {
+		case nil:
+			return value, nil
+		case string:
+			return []any{node, value}, nil
+		case []any:
+			return append(node, value), nil
+		}
+		return nil, fmt.Errorf("value conflicts with nested keys")
+	}
+
+	part, rest := parts[0], parts[1:]
+	index, isIndex := queryIndex(part)
+	if node == nil {
+		if part == "" || isIndex {
+			node = []any{}
+		} else {
+			node = New()
+		}
+	}
+	switch node := node.(type) {
+	case *Map:
+		child, _ := node.Get(part)
+		v, err := queryPut(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		node.Set(part, v)
+		return node, nil
+	case []any:
+		if part == "" {
+			// like Rails, a[][x]=1&a[][y]=2 fills the last map, until its key repeats
+			if len(rest) > 0 && len(node) > 0 {
+				if last, ok := node[len(node)-1].(*Map); ok {
+					if _, ok := last.Get(rest[0]); !ok {
+						v, err := queryPut(last, rest, value)
+						node[len(node)-1] = v
+						return node, err
+					}
+				}
+			}
+			index = len(node)
+		} else if !isIndex {
+			return nil, fmt.Errorf("array can't have key %q", part)
+		}
+		switch {
+		case index < len(node):
+			v, err := queryPut(node[index], rest, value)
+			node[index] = v
+			return node, err
+		case index == len(node):
+			v, err := queryPut(nil, rest, value)
+			return append(node, v), err
+		}
+		return nil, fmt.Errorf("array index %d is out of order", index)
+	}
+	return nil, fmt.Errorf("nested keys conflict with value")
+}
+
+// queryIndex parses array index without leading zeros.
+func queryIndex(part string) (int, bool) {
+	n, err := strconv.Atoi(part)
+	return n, err == nil && n >= 0 && strconv.Itoa(n) == part
+}
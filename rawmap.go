@@ -0,0 +1,226 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// RawMap is an ordered map of JSON object, which defers decoding of values until they are requested.
+// Keys are decoded in order, but each value is kept as raw JSON slice of the input, and decoded on first Get.
+//
+// MarshalJSON writes keys and values, which were not requested as maps or arrays, back byte-for-byte.
+// Useful for proxies, which modify few values and forward the rest as is. Zero value is ready to use.
+//
+//	r := jsonmap.NewRawMap()
+//	err := json.Unmarshal(data, r)
+//	r.Set("modified", true)
+//	out, err := r.MarshalJSON() // other values are not re-encoded
+type RawMap struct {
+	m *Map // values are *rawEntry
+}
+
+type rawEntry struct {
+	rawKey  []byte // quoted key, nil if set by user
+	raw     []byte // raw value, nil if set by user
+	value   Value
+	decoded bool
+}
+
+// NewRawMap returns a new empty RawMap. O(1) time.
+//
+//	r := jsonmap.NewRawMap()
+func NewRawMap() *RawMap {
+	return &RawMap{m: New()}
+}
+
+// init makes zero RawMap usable, as json.Unmarshal allocates it for pointer fields.
+func (r *RawMap) init() *Map {
+	if r.m == nil {
+		r.m = New()
+	}
+	return r.m
+}
+
+// Len returns the number of elements in the map. O(1) time.
+func (r *RawMap) Len() int {
+	return r.init().Len()
+}
+
+// Keys returns all keys in the map. O(n) time and space.
+func (r *RawMap) Keys() []Key {
+	return r.init().Keys()
+}
+
+// Get returns the value for the key, decoding it on first request.
+// Nested objects are decoded as *Map, same as UnmarshalJSON does.
+// Returns ok=false if the key is not in the map, or the value can't be decoded.
+//
+// Maps and arrays returned by Get can be modified, so they are encoded again by MarshalJSON.
+//
+//	value, ok := r.Get(key)
+func (r *RawMap) Get(key Key) (value Value, ok bool) {
+	v, ok := r.init().Get(key)
+	if !ok {
+		return nil, false
+	}
+	value, err := v.(*rawEntry).get()
+	return value, err == nil
+}
+
+func (e *rawEntry) get() (Value, error) {
+	if !e.decoded {
+		value, err := unmarshalValue(e.raw)
+		if err != nil {
+			return nil, err
+		}
+		e.value = value
+		e.decoded = true
+	}
+	return e.value, nil
+}
+
+// GetRaw returns raw JSON of the value, without decoding it.
+// For values set with Set, or maps and arrays requested with Get, it encodes the current value.
+// Returns ok=false if the key is not in the map.
+//
+//	raw, ok := r.GetRaw(key)
+func (r *RawMap) GetRaw(key Key) (raw json.RawMessage, ok bool) {
+	v, ok := r.init().Get(key)
+	if !ok {
+		return nil, false
+	}
+	raw, err := v.(*rawEntry).marshal()
+	return raw, err == nil
+}
+
+// Set sets the value for the key, keeping position of existing key. O(1) time.
+//
+//	r.Set(key, value)
+func (r *RawMap) Set(key Key, value Value) {
+	r.init().Set(key, &rawEntry{value: value, decoded: true})
+}
+
+// Delete removes the element from the map. O(1) time.
+//
+//	r.Delete(key)
+func (r *RawMap) Delete(key Key) {
+	r.init().Delete(key)
+}
+
+// Map decodes all values, and returns them as a regular map. O(n) time.
+//
+//	m, err := r.Map()
+func (r *RawMap) Map() (*Map, error) {
+	m := New()
+	for el := r.init().First(); el != nil; el = el.Next() {
+		value, err := el.value.(*rawEntry).get()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", el.key, err)
+		}
+		m.Set(el.key, value)
+	}
+	return m, nil
+}
+
+func (e *rawEntry) marshal() ([]byte, error) {
+	if e.raw != nil {
+		switch e.value.(type) {
+		case *Map, []any: // could be modified after Get
+		default:
+			return e.raw, nil
+		}
+	}
+	return json.Marshal(e.value)
+}
+
+// MarshalJSON implements json.Marshaler interface.
+// Keys and values, which were not set or requested as maps or arrays, are written back as they were in input.
+// Note that json.Marshal compacts the output, and escapes HTML characters in it, call MarshalJSON directly to avoid that.
+//
+//	data, err := r.MarshalJSON()
+func (r *RawMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for el := r.init().First(); el != nil; el = el.Next() {
+		if el != r.m.First() {
+			buf.WriteByte(',')
+		}
+		e := el.value.(*rawEntry)
+		if e.rawKey != nil {
+			buf.Write(e.rawKey)
+		} else {
+			key, err := json.Marshal(el.key)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(key)
+		}
+		buf.WriteByte(':')
+		value, err := e.marshal()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface.
+// It decodes only keys, and keeps values as raw slices of a copy of data.
+//
+// Note: same as Map.UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := r.UnmarshalJSON(data)
+func (r *RawMap) UnmarshalJSON(data []byte) error {
+	data = append([]byte(nil), data...) // data can be reused by caller
+	s := &scanner{buf: data, fn: func(*Event) error { return nil }}
+	s.skipSpace()
+	if s.peek() != '{' {
+		return errors.New("expected '{'")
+	}
+	s.pos++
+	s.skipSpace()
+	if s.peek() == '}' {
+		s.pos++
+		return s.checkEnd()
+	}
+	for {
+		s.skipSpace()
+		if s.peek() != '"' {
+			return s.errorf("expected string key")
+		}
+		keyStart := s.pos
+		key, err := s.string()
+		if err != nil {
+			return err
+		}
+		e := &rawEntry{rawKey: data[keyStart:s.pos:s.pos]}
+		k := string(key) // scanner reuses the key buffer
+		s.skipSpace()
+		if s.peek() != ':' {
+			return s.errorf("expected ':' after object key")
+		}
+		s.pos++
+		s.skipSpace()
+		start := s.pos
+		if err := s.skipValue(); err != nil {
+			return err
+		}
+		e.raw = data[start:s.pos:s.pos]
+		r.init().Push(k, e)
+
+		s.skipSpace()
+		switch s.peek() {
+		case ',':
+			s.pos++
+		case '}':
+			s.pos++
+			return s.checkEnd()
+		default:
+			return s.errorf("expected ',' or '}' after object value")
+		}
+	}
+}
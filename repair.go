@@ -0,0 +1,494 @@
+package jsonmap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Fix describes a single change made by Repair.
+type Fix struct {
+	Offset int64 // byte offset in the original input
+	Msg    string
+}
+
+func (f Fix) String() string {
+	return fmt.Sprintf("offset %d: %s", f.Offset, f.Msg)
+}
+
+// Repair fixes common problems of machine-generated JSON, and returns valid JSON with the list of fixes made.
+// Parts of input, which don't need fixes, are kept as is, including whitespace and order of keys.
+//
+// It handles:
+// trailing and missing commas, missing colons, unquoted keys and values, single and smart quotes,
+// Python and JavaScript literals like True, None, NaN and undefined, comments,
+// invalid numbers like +1, .5, 01 or 1., control characters and invalid escapes in strings,
+// mismatched brackets, markdown code fences, data after the top-level value,
+// and input truncated at any point, including inside a string.
+// Values nested deeper than 10000 levels are removed with the rest of input, see UnmarshalLenient.
+//
+// Valid JSON is returned unchanged, with no fixes.
+//
+//	data, fixes := jsonmap.Repair(data)
+func Repair(data []byte) ([]byte, []Fix) {
+	r := &repairer{in: data, out: make([]byte, 0, len(data)+16)}
+	r.repair()
+	return r.out, r.fixes
+}
+
+// UnmarshalLenient is same as UnmarshalJSON, but repairs the input first, see Repair.
+// Returns the list of fixes made, and error if the input is nested too deep, or the repaired input is not a JSON object.
+//
+//	fixes, err := jsonmap.UnmarshalLenient(data, m)
+func UnmarshalLenient(data []byte, m *Map) ([]Fix, error) {
+	r := &repairer{in: data, out: make([]byte, 0, len(data)+16)}
+	r.repair()
+	if r.err != nil {
+		return r.fixes, r.err
+	}
+	return r.fixes, m.UnmarshalJSON(r.out)
+}
+
+type repairer struct {
+	in      []byte
+	pos     int
+	out     []byte
+	fixes   []Fix
+	closers []byte // expected closing brackets of open containers
+	err     error  // set if input is nested too deep
+}
+
+func (r *repairer) fix(offset int, format string, args ...any) {
+	r.fixes = append(r.fixes, Fix{Offset: int64(offset), Msg: fmt.Sprintf(format, args...)})
+}
+
+func (r *repairer) eof() bool {
+	return r.pos >= len(r.in)
+}
+
+func (r *repairer) peek() byte {
+	if r.eof() {
+		return 0
+	}
+	return r.in[r.pos]
+}
+
+func (r *repairer) repair() {
+	r.space()
+	if bytes.HasPrefix(r.in[r.pos:], []byte("```")) {
+		r.fix(r.pos, "removed code fence")
+		if i := bytes.IndexByte(r.in[r.pos:], '\n'); i >= 0 {
+			r.pos += i + 1
+		} else {
+			r.pos = len(r.in)
+		}
+		r.space()
+	}
+	r.value()
+	r.space()
+	if !r.eof() {
+		r.fix(r.pos, "removed data after top-level value")
+		r.pos = len(r.in)
+	}
+}
+
+// space copies whitespace to output, and removes comments.
+func (r *repairer) space() {
+	for !r.eof() {
+		switch c := r.in[r.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			r.out = append(r.out, c)
+			r.pos++
+		case bytes.HasPrefix(r.in[r.pos:], []byte("//")) || c == '#':
+			r.fix(r.pos, "removed comment")
+			if i := bytes.IndexByte(r.in[r.pos:], '\n'); i >= 0 {
+				r.pos += i
+			} else {
+				r.pos = len(r.in)
+			}
+		case bytes.HasPrefix(r.in[r.pos:], []byte("/*")):
+			r.fix(r.pos, "removed comment")
+			if i := bytes.Index(r.in[r.pos+2:], []byte("*/")); i >= 0 {
+				r.pos += i + 4
+			} else {
+				r.pos = len(r.in)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// closes reports whether c closes an open container.
+func (r *repairer) closes(c byte) bool {
+	return bytes.IndexByte(r.closers, c) >= 0
+}
+
+// value repairs a value. It always writes a value to output.
+func (r *repairer) value() {
+	for {
+		r.space()
+		c := r.peek()
+		switch {
+		case r.eof() || c == ',' || c == '}' || c == ']':
+			r.fix(r.pos, "added missing value")
+			r.out = append(r.out, "null"...)
+			return
+		case (c == '{' || c == '[') && len(r.closers) == maxDepth:
+			r.fix(r.pos, "removed data nested deeper than %d", maxDepth)
+			r.err = fmt.Errorf("jsonmap: repair: offset %d: exceeded max depth %d", r.pos, maxDepth)
+			r.pos = len(r.in)
+			r.out = append(r.out, "null"...)
+			return
+		case c == '{':
+			r.container('{', '}')
+			return
+		case c == '[':
+			r.container('[', ']')
+			return
+		case quoteEnd(r.in[r.pos:]) != "":
+			r.string()
+			return
+		case isBare(c):
+			r.bare()
+			return
+		}
+		r.fix(r.pos, "removed unexpected character %q", c)
+		r.pos++
+	}
+}
+
+// container repairs object or array, whose opening bracket is at current position.
+func (r *repairer) container(open, close byte) {
+	r.out = append(r.out, open)
+	r.pos++
+	r.closers = append(r.closers, close)
+	defer func() { r.closers = r.closers[:len(r.closers)-1] }()
+
+	comma := -1   // index of the last comma in output, if there is no element after it
+	commaPos := 0 // offset of that comma in input
+	first := true
+	for {
+		r.space()
+		c := r.peek()
+		switch {
+		case r.eof() || c == '}' || c == ']':
+			if comma >= 0 {
+				r.fix(commaPos, "removed trailing comma")
+				r.out = append(r.out[:comma], r.out[comma+1:]...)
+			}
+			switch {
+			case c == close:
+				r.pos++
+			case r.eof():
+				r.fix(r.pos, "added missing %q at end of input", close)
+			case r.closes(c):
+				r.fix(r.pos, "added missing %q", close)
+			default:
+				r.fix(r.pos, "replaced %q with %q", c, close)
+				r.pos++
+			}
+			r.out = append(r.out, close)
+			return
+		case c == ',':
+			if comma >= 0 || first {
+				r.fix(r.pos, "removed extra comma")
+			} else {
+				comma, commaPos = len(r.out), r.pos
+				r.out = append(r.out, ',')
+			}
+			r.pos++
+			continue
+		case close == '}' && quoteEnd(r.in[r.pos:]) == "" && !isBare(c):
+			r.fix(r.pos, "removed unexpected character %q", c)
+			r.pos++
+			continue
+		}
+		if comma < 0 && !first {
+			r.fix(r.pos, "added missing comma")
+			r.out = append(r.out, ',')
+		}
+		comma = -1
+		first = false
+		if close == '}' {
+			r.member()
+		} else {
+			r.value()
+		}
+	}
+}
+
+// member repairs key and value of object member, starting at current position.
+func (r *repairer) member() {
+	if quoteEnd(r.in[r.pos:]) != "" {
+		r.string()
+	} else {
+		start := r.pos
+		r.pos = r.bareEnd()
+		r.fix(start, "quoted key")
+		r.out = appendQuoted(r.out, string(r.in[start:r.pos]))
+	}
+	r.space()
+	switch r.peek() {
+	case ':':
+		r.out = append(r.out, ':')
+		r.pos++
+	case '=':
+		r.fix(r.pos, "replaced '=' with ':'")
+		r.out = append(r.out, ':')
+		r.pos++
+	default:
+		r.fix(r.pos, "added missing colon")
+		r.out = append(r.out, ':')
+	}
+	r.value()
+}
+
+var smartQuotes = map[string]string{
+	"“": "”“", // “ closed by ” or “
+	"‘": "’‘", // ‘ closed by ’ or ‘
+	"”": "”",  // ” used as both quotes
+	"’": "’",  // ’ used as both quotes
+}
+
+// quoteEnd returns closing quotes for opening quote at the start of b, or "" if there is no quote.
+func quoteEnd(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case '"', '\'', '`':
+		return string(b[:1])
+	case 0xE2:
+		_, size := utf8.DecodeRune(b)
+		return smartQuotes[string(b[:size])]
+	}
+	return ""
+}
+
+// string repairs a string in any quotes, writing it in double quotes.
+func (r *repairer) string() {
+	start := r.pos
+	open, size := utf8.DecodeRune(r.in[r.pos:])
+	end := quoteEnd(r.in[r.pos:])
+	switch {
+	case open == '\'':
+		r.fix(start, "replaced single quotes")
+	case open == '`':
+		r.fix(start, "replaced backquotes")
+	case open != '"':
+		r.fix(start, "replaced smart quotes")
+	}
+	r.pos += size
+	r.out = append(r.out, '"')
+	for {
+		if r.eof() {
+			r.fix(r.pos, "closed truncated string")
+			r.out = append(r.out, '"')
+			return
+		}
+		c, size := utf8.DecodeRune(r.in[r.pos:])
+		switch {
+		case strings.ContainsRune(end, c):
+			r.pos += size
+			r.out = append(r.out, '"')
+			return
+		case c == '"':
+			r.out = append(r.out, '\\', '"')
+		case c == '\\':
+			r.escape()
+			continue
+		case c < 0x20:
+			r.fix(r.pos, "escaped control character")
+			r.out = appendEscaped(r.out, byte(c))
+		default:
+			r.out = append(r.out, r.in[r.pos:r.pos+size]...)
+		}
+		r.pos += size
+	}
+}
+
+// escape repairs escape sequence in a string.
+func (r *repairer) escape() {
+	start := r.pos
+	r.pos++ // backslash
+	if r.eof() {
+		return // truncated, the string is closed by caller
+	}
+	switch c := r.in[r.pos]; c {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		r.out = append(r.out, '\\', c)
+		r.pos++
+	case '\'', '`':
+		r.out = append(r.out, c)
+		r.pos++
+	case 'u':
+		n := 0
+		for n < 4 && r.pos+1+n < len(r.in) && isHex(r.in[r.pos+1+n]) {
+			n++
+		}
+		switch {
+		case n == 4:
+			r.out = append(r.out, r.in[start:r.pos+5]...)
+			r.pos += 5
+		case r.pos+1+n == len(r.in):
+			r.pos = len(r.in) // truncated
+		default:
+			r.fix(start, "escaped backslash")
+			r.out = append(r.out, '\\', '\\')
+		}
+	default:
+		r.fix(start, "escaped backslash")
+		r.out = append(r.out, '\\', '\\')
+	}
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// isBare reports whether c can be a part of unquoted word or number.
+// Smart quotes are checked separately.
+func isBare(c byte) bool {
+	return c > ' ' && strings.IndexByte(`,:{}[]"'`+"`=/#", c) < 0
+}
+
+// bareEnd returns end of unquoted word or number at current position.
+func (r *repairer) bareEnd() int {
+	i := r.pos
+	for i < len(r.in) && isBare(r.in[i]) && quoteEnd(r.in[i:]) == "" {
+		i++
+	}
+	return i
+}
+
+var bareLiterals = map[string]string{
+	"true":      "true",
+	"false":     "false",
+	"null":      "null",
+	"True":      "true",
+	"False":     "false",
+	"None":      "null",
+	"TRUE":      "true",
+	"FALSE":     "false",
+	"NULL":      "null",
+	"Null":      "null",
+	"nil":       "null",
+	"undefined": "null",
+	"NaN":       "null",
+	"Infinity":  "null",
+	"-Infinity": "null",
+	"+Infinity": "null",
+}
+
+// bare repairs unquoted literal, number or word.
+func (r *repairer) bare() {
+	start := r.pos
+	r.pos = r.bareEnd()
+	word := string(r.in[start:r.pos])
+	if lit, ok := bareLiterals[word]; ok {
+		if lit != word {
+			r.fix(start, "replaced %s with %s", word, lit)
+		}
+		r.out = append(r.out, lit...)
+		return
+	}
+	if r.eof() {
+		for _, lit := range []string{"true", "false", "null"} {
+			if strings.HasPrefix(lit, word) {
+				r.fix(start, "completed truncated %s", lit)
+				r.out = append(r.out, lit...)
+				return
+			}
+		}
+	}
+	if num, ok := repairNumber(word, r.eof()); ok {
+		if num != word {
+			r.fix(start, "replaced number %s with %s", word, num)
+		}
+		r.out = append(r.out, num...)
+		return
+	}
+	r.fix(start, "quoted %s", word)
+	r.out = appendQuoted(r.out, word)
+}
+
+// repairNumber returns valid JSON number for number-like word, like +1, .5, 01, 1. or 1e.
+// Incomplete exponent is accepted only if the number is truncated.
+func repairNumber(word string, truncated bool) (string, bool) {
+	var b strings.Builder
+	i := 0
+	digits := func() string {
+		j := i
+		for i < len(word) && word[i] >= '0' && word[i] <= '9' {
+			i++
+		}
+		return word[j:i]
+	}
+	if i < len(word) && (word[i] == '-' || word[i] == '+') {
+		if word[i] == '-' {
+			b.WriteByte('-')
+		}
+		i++
+	}
+	intPart := strings.TrimLeft(digits(), "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	b.WriteString(intPart)
+	hasDigits := i > 0 && word[i-1] >= '0' && word[i-1] <= '9'
+	if i < len(word) && word[i] == '.' {
+		i++
+		if frac := digits(); frac != "" {
+			b.WriteString("." + frac)
+			hasDigits = true
+		}
+	}
+	if !hasDigits {
+		return "", false
+	}
+	if i < len(word) && (word[i] == 'e' || word[i] == 'E') {
+		exp := word[i : i+1]
+		i++
+		if i < len(word) && (word[i] == '-' || word[i] == '+') {
+			exp += word[i : i+1]
+			i++
+		}
+		if d := digits(); d != "" {
+			b.WriteString(exp + d)
+		} else if !truncated {
+			return "", false
+		}
+	}
+	return b.String(), i == len(word)
+}
+
+// appendQuoted appends s as JSON string.
+func appendQuoted(out []byte, s string) []byte {
+	out = append(out, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			out = append(out, '\\', c)
+		case c < 0x20:
+			out = appendEscaped(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return append(out, '"')
+}
+
+func appendEscaped(out []byte, c byte) []byte {
+	switch c {
+	case '\n':
+		return append(out, '\\', 'n')
+	case '\r':
+		return append(out, '\\', 'r')
+	case '\t':
+		return append(out, '\\', 't')
+	}
+	const hex = "0123456789abcdef"
+	return append(out, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+}
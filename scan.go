@@ -0,0 +1,685 @@
+package jsonmap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// EventKind is a kind of event reported by Scan.
+type EventKind int
+
+const (
+	EventStartObject EventKind = iota // '{'
+	EventEndObject                    // '}'
+	EventStartArray                   // '['
+	EventEndArray                     // ']'
+	EventKey                          // object key, followed by its value
+	EventValue                        // string, number, boolean or null
+)
+
+var eventKindNames = [...]string{
+	EventStartObject: "StartObject",
+	EventEndObject:   "EndObject",
+	EventStartArray:  "StartArray",
+	EventEndArray:    "EndArray",
+	EventKey:         "Key",
+	EventValue:       "Value",
+}
+
+// String returns the name of the event kind.
+func (k EventKind) String() string {
+	if int(k) < len(eventKindNames) {
+		return eventKindNames[k]
+	}
+	return fmt.Sprintf("EventKind(%d)", int(k))
+}
+
+// Event is reported by Scan for each JSON token.
+//
+// Path is the location of the current value: for EventKey it ends with the key,
+// for events inside arrays it ends with the index. Path is reused between events,
+// copy it if you need to keep it after the callback returns.
+type Event struct {
+	Kind   EventKind
+	Path   Path
+	Key    Key   // EventKey only
+	Value  Value // EventValue only: string, float64, bool or nil
+	Offset int64 // byte offset of the token in input
+}
+
+var (
+	// SkipValue is returned by Scan callback on EventStartObject, EventStartArray or EventKey,
+	// to skip the whole value without reporting its events. Returned on other events, it is ignored.
+	SkipValue = errors.New("skip this value")
+
+	// StopScan is returned by Scan callback to stop scanning. Scan returns nil in this case.
+	StopScan = errors.New("stop scanning")
+)
+
+// ScanError is a syntax error in JSON input, found by Scan.
+type ScanError struct {
+	Offset int64 // byte offset of the error
+	Msg    string
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("jsonmap: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Scan parses JSON document, calling fn for each event, without building maps.
+// Skipped values are not decoded, only strings and nesting of brackets are checked in them.
+// Error returned by fn stops scanning, and is returned by Scan, except for SkipValue and StopScan.
+//
+//	err := jsonmap.Scan(data, func(e *jsonmap.Event) error {
+//	    if e.Kind == jsonmap.EventKey && e.Key != "id" {
+//	        return jsonmap.SkipValue
+//	    }
+//	    if e.Kind == jsonmap.EventValue {
+//	        fmt.Println(e.Path, e.Value)
+//	    }
+//	    return nil
+//	})
+func Scan(data []byte, fn func(e *Event) error) error {
+	s := &scanner{buf: data, fn: fn}
+	return s.run()
+}
+
+// ScanReader is same as Scan, but reads input from r, keeping only a small buffer in memory.
+//
+//	err := jsonmap.ScanReader(file, handler)
+func ScanReader(r io.Reader, fn func(e *Event) error) error {
+	s := &scanner{r: r, buf: make([]byte, 0, 64*1024), fn: fn}
+	return s.run()
+}
+
+type scanner struct {
+	r    io.Reader // nil if all input is in buf
+	buf  []byte
+	pos  int
+	base int64 // offset of buf[0] in input
+	rerr error // read error
+
+	fn    func(e *Event) error
+	event Event
+	path  Path
+	str   []byte // scratch for strings
+	depth int    // nesting of objects and arrays
+
+	dialect dialect
+}
+
+// maxDepth is the maximum nesting of objects and arrays, same as in encoding/json.
+// Deeper input is rejected, instead of overflowing the stack.
+const maxDepth = 10000
+
+// dialect of JSON accepted by scanner.
+type dialect uint8
+
+const (
+	dialectJSON  dialect = iota // RFC 8259
+	dialectJSONC                // comments and trailing commas
+	dialectJSON5                // JSONC with JSON5 extensions
+)
+
+func (s *scanner) run() error {
+	err := s.value()
+	if err == nil {
+		err = s.checkEnd()
+	}
+	if err == StopScan {
+		return nil
+	}
+	return err
+}
+
+// checkEnd checks that only whitespace is left after top-level value.
+func (s *scanner) checkEnd() error {
+	s.skipSpace()
+	if _, ok := s.rerr.(*ScanError); ok { // unterminated comment
+		return s.rerr
+	}
+	if s.more() {
+		return s.errorf("unexpected data after top-level value")
+	}
+	return nil
+}
+
+func (s *scanner) offset() int64 {
+	return s.base + int64(s.pos)
+}
+
+func (s *scanner) errorf(format string, args ...any) error {
+	if s.rerr != nil && s.rerr != io.EOF {
+		return s.rerr
+	}
+	return &ScanError{Offset: s.offset(), Msg: fmt.Sprintf(format, args...)}
+}
+
+// more reports whether there are unread bytes, reading more input if needed.
+func (s *scanner) more() bool {
+	return s.pos < len(s.buf) || s.fill(1)
+}
+
+// fill reads more input, until at least n bytes are unread or input ends.
+// Reports whether there are any unread bytes.
+func (s *scanner) fill(n int) bool {
+	if s.r == nil || s.rerr != nil || len(s.buf)-s.pos >= n {
+		return s.pos < len(s.buf)
+	}
+	// move unread bytes to the start of the buffer
+	rest := copy(s.buf[:cap(s.buf)], s.buf[s.pos:])
+	s.base += int64(s.pos)
+	s.buf = s.buf[:rest]
+	s.pos = 0
+	for len(s.buf) < n && s.rerr == nil {
+		var m int
+		m, s.rerr = s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+m]
+	}
+	return len(s.buf) > 0
+}
+
+func (s *scanner) peek() byte {
+	if !s.more() {
+		return 0
+	}
+	return s.buf[s.pos]
+}
+
+func (s *scanner) skipSpace() {
+	for s.more() {
+		switch s.buf[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			if s.dialect == dialectJSON || !s.skipComment() {
+				return
+			}
+		}
+	}
+}
+
+func (s *scanner) emit(kind EventKind, value Value, offset int64) error {
+	s.event = Event{Kind: kind, Path: s.path, Value: value, Offset: offset}
+	if kind == EventKey {
+		s.event.Key = value.(string)
+		s.event.Value = nil
+	}
+	return s.fn(&s.event)
+}
+
+func (s *scanner) value() error {
+	s.skipSpace()
+	offset := s.offset()
+	switch c := s.peek(); {
+	case c == '{':
+		s.pos++
+		if err := s.emit(EventStartObject, nil, offset); err != nil {
+			if err == SkipValue {
+				return s.skipContainer(c, offset)
+			}
+			return err
+		}
+		return s.nested(s.object)
+	case c == '[':
+		s.pos++
+		if err := s.emit(EventStartArray, nil, offset); err != nil {
+			if err == SkipValue {
+				return s.skipContainer(c, offset)
+			}
+			return err
+		}
+		return s.nested(s.array)
+	case c == '"' || c == '\'' && s.dialect == dialectJSON5:
+		str, err := s.string()
+		if err != nil {
+			return err
+		}
+		return s.emitValue(string(str), offset)
+	case s.dialect == dialectJSON5 && (c == '-' || c == '+' || c == '.' || c == 'I' || c == 'N' || c >= '0' && c <= '9'):
+		f, err := s.number5()
+		if err != nil {
+			return err
+		}
+		return s.emitValue(f, offset)
+	case c == '-' || c >= '0' && c <= '9':
+		num, err := s.number()
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(string(num), 64)
+		if err != nil {
+			s.pos -= len(num)
+			return s.errorf("invalid number %s", num)
+		}
+		return s.emitValue(f, offset)
+	case c == 't':
+		if err := s.literal("true"); err != nil {
+			return err
+		}
+		return s.emitValue(true, offset)
+	case c == 'f':
+		if err := s.literal("false"); err != nil {
+			return err
+		}
+		return s.emitValue(false, offset)
+	case c == 'n':
+		if err := s.literal("null"); err != nil {
+			return err
+		}
+		return s.emitValue(nil, offset)
+	case c == 0 && !s.more():
+		return s.errorf("unexpected end of input")
+	default:
+		return s.errorf("invalid character %q looking for beginning of value", c)
+	}
+}
+
+// nested reads the rest of object or array with parse, limiting the depth of nesting.
+func (s *scanner) nested(parse func() error) error {
+	if s.depth == maxDepth {
+		s.pos-- // point to the opening bracket
+		return s.errorf("exceeded max depth %d", maxDepth)
+	}
+	s.depth++
+	err := parse()
+	s.depth--
+	return err
+}
+
+func (s *scanner) emitValue(v Value, offset int64) error {
+	if err := s.emit(EventValue, v, offset); err != nil && err != SkipValue {
+		return err
+	}
+	return nil
+}
+
+// emitEnd reports end of object or array, whose closing bracket was just read.
+func (s *scanner) emitEnd(kind EventKind) error {
+	if err := s.emit(kind, nil, s.offset()-1); err != nil && err != SkipValue {
+		return err
+	}
+	return nil
+}
+
+func (s *scanner) object() error {
+	s.skipSpace()
+	if s.peek() == '}' {
+		s.pos++
+		return s.emitEnd(EventEndObject)
+	}
+	for {
+		s.skipSpace()
+		offset := s.offset()
+		key, err := s.key()
+		if err != nil {
+			return err
+		}
+		s.skipSpace()
+		if s.peek() != ':' {
+			return s.errorf("expected ':' after object key")
+		}
+		s.pos++
+		s.path = append(s.path, string(key))
+		err = s.emit(EventKey, s.path[len(s.path)-1], offset)
+		switch err {
+		case nil:
+			err = s.value()
+		case SkipValue:
+			err = s.skipValue()
+		}
+		if err != nil {
+			return err
+		}
+		s.path = s.path[:len(s.path)-1]
+
+		s.skipSpace()
+		switch s.peek() {
+		case ',':
+			s.pos++
+			if s.trailingComma('}') {
+				return s.emitEnd(EventEndObject)
+			}
+		case '}':
+			s.pos++
+			return s.emitEnd(EventEndObject)
+		default:
+			return s.errorf("expected ',' or '}' after object value")
+		}
+	}
+}
+
+func (s *scanner) array() error {
+	s.skipSpace()
+	if s.peek() == ']' {
+		s.pos++
+		return s.emitEnd(EventEndArray)
+	}
+	for i := 0; ; i++ {
+		s.path = append(s.path, i)
+		if err := s.value(); err != nil {
+			return err
+		}
+		s.path = s.path[:len(s.path)-1]
+
+		s.skipSpace()
+		switch s.peek() {
+		case ',':
+			s.pos++
+			if s.trailingComma(']') {
+				return s.emitEnd(EventEndArray)
+			}
+		case ']':
+			s.pos++
+			return s.emitEnd(EventEndArray)
+		default:
+			return s.errorf("expected ',' or ']' after array element")
+		}
+	}
+}
+
+// key reads object key.
+func (s *scanner) key() ([]byte, error) {
+	switch c := s.peek(); {
+	case c == '"' || c == '\'' && s.dialect == dialectJSON5:
+		return s.string()
+	case s.dialect == dialectJSON5 && c != 0:
+		return s.identifier()
+	}
+	return nil, s.errorf("expected string key")
+}
+
+// trailingComma skips closing bracket after comma, if dialect allows trailing commas.
+func (s *scanner) trailingComma(close byte) bool {
+	if s.dialect == dialectJSON {
+		return false
+	}
+	s.skipSpace()
+	if s.peek() == close {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func (s *scanner) literal(lit string) error {
+	for i := 0; i < len(lit); i++ {
+		if s.peek() != lit[i] {
+			return s.errorf("invalid literal, expected %q", lit)
+		}
+		s.pos++
+	}
+	return nil
+}
+
+// number reads JSON number, and returns its text.
+func (s *scanner) number() ([]byte, error) {
+	s.str = s.str[:0]
+	digits := func() int {
+		n := 0
+		for c := s.peek(); c >= '0' && c <= '9'; c = s.peek() {
+			s.str = append(s.str, c)
+			s.pos++
+			n++
+		}
+		return n
+	}
+	if s.peek() == '-' {
+		s.str = append(s.str, '-')
+		s.pos++
+	}
+	if s.peek() == '0' {
+		s.str = append(s.str, '0')
+		s.pos++
+	} else if digits() == 0 {
+		return nil, s.errorf("invalid number")
+	}
+	if s.peek() == '.' {
+		s.str = append(s.str, '.')
+		s.pos++
+		if digits() == 0 {
+			return nil, s.errorf("invalid number")
+		}
+	}
+	if c := s.peek(); c == 'e' || c == 'E' {
+		s.str = append(s.str, c)
+		s.pos++
+		if c := s.peek(); c == '+' || c == '-' {
+			s.str = append(s.str, c)
+			s.pos++
+		}
+		if digits() == 0 {
+			return nil, s.errorf("invalid number")
+		}
+	}
+	return s.str, nil
+}
+
+// string reads JSON string, and returns its unescaped value in scratch buffer.
+// Invalid UTF-8 and unpaired surrogates are replaced with U+FFFD, same as encoding/json does.
+// JSON5 strings can be in single quotes.
+func (s *scanner) string() ([]byte, error) {
+	quote := s.buf[s.pos]
+	s.pos++ // opening quote
+	s.str = s.str[:0]
+	for {
+		if !s.more() {
+			return nil, s.errorf("unexpected end of input in string")
+		}
+		// fast path: copy plain ASCII bytes
+		start := s.pos
+		for s.pos < len(s.buf) {
+			c := s.buf[s.pos]
+			if c == '"' || c == '\'' || c == '\\' || c < 0x20 || c >= utf8.RuneSelf {
+				break
+			}
+			s.pos++
+		}
+		s.str = append(s.str, s.buf[start:s.pos]...)
+		if s.pos == len(s.buf) {
+			continue
+		}
+
+		switch c := s.buf[s.pos]; {
+		case c == quote:
+			s.pos++
+			return s.str, nil
+		case c == '"' || c == '\'':
+			s.str = append(s.str, c)
+			s.pos++
+		case c < 0x20 && (s.dialect != dialectJSON5 || c == '\n' || c == '\r'):
+			return nil, s.errorf("invalid control character in string")
+		case c < 0x20:
+			s.str = append(s.str, c)
+			s.pos++
+		case c == '\\':
+			s.pos++
+			r, err := s.escape()
+			if err != nil {
+				return nil, err
+			}
+			if r >= 0 {
+				s.str = utf8.AppendRune(s.str, r)
+			}
+		default:
+			r, err := s.rune()
+			if err != nil {
+				return nil, err
+			}
+			s.str = utf8.AppendRune(s.str, r)
+		}
+	}
+}
+
+// rune reads UTF-8 encoded rune. Invalid encoding is read as one byte, and returned as U+FFFD.
+func (s *scanner) rune() (rune, error) {
+	s.fill(utf8.UTFMax)
+	r, size := utf8.DecodeRune(s.buf[s.pos:])
+	s.pos += size
+	return r, nil
+}
+
+func (s *scanner) escape() (rune, error) {
+	c := s.peek()
+	s.pos++
+	switch c {
+	case '"', '\\', '/':
+		return rune(c), nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		r, err := s.hex4()
+		if err != nil {
+			return 0, err
+		}
+		if !utf16.IsSurrogate(r) {
+			return r, nil
+		}
+		// try to read the low surrogate
+		if s.peek() != '\\' {
+			return utf8.RuneError, nil
+		}
+		s.pos++
+		if s.peek() != 'u' {
+			s.pos-- // not a \u escape, reprocess it
+			return utf8.RuneError, nil
+		}
+		s.pos++
+		r2, err := s.hex4()
+		if err != nil {
+			return 0, err
+		}
+		if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+			return dec, nil
+		}
+		// not a pair: first is invalid, second is kept
+		s.str = utf8.AppendRune(s.str, utf8.RuneError)
+		if utf16.IsSurrogate(r2) {
+			return utf8.RuneError, nil
+		}
+		return r2, nil
+	}
+	if s.dialect == dialectJSON5 {
+		return s.escape5(c)
+	}
+	s.pos--
+	return 0, s.errorf("invalid escape character %q in string", c)
+}
+
+func (s *scanner) hex4() (rune, error) {
+	var r rune
+	for i := 0; i < 4; i++ {
+		c := s.peek()
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c -= 'a' - 10
+		case c >= 'A' && c <= 'F':
+			c -= 'A' - 10
+		default:
+			return 0, s.errorf("invalid \\u escape in string")
+		}
+		r = r<<4 | rune(c)
+		s.pos++
+	}
+	return r, nil
+}
+
+// skipValue checks syntax of the next value, without reporting events.
+func (s *scanner) skipValue() error {
+	s.skipSpace()
+	switch c := s.peek(); c {
+	case '{', '[':
+		offset := s.offset()
+		s.pos++
+		return s.skipContainer(c, offset)
+	case '"':
+		return s.skipString()
+	}
+	fn := s.fn
+	s.fn = func(*Event) error { return nil }
+	err := s.value()
+	s.fn = fn
+	return err
+}
+
+// skipContainer skips the rest of object or array, whose opening bracket was at offset.
+// Only strings and nesting of brackets are checked, to skip fast.
+func (s *scanner) skipContainer(open byte, offset int64) error {
+	if s.dialect != dialectJSON {
+		// brackets can be in comments and unquoted keys, so check full syntax
+		fn := s.fn
+		s.fn = func(*Event) error { return nil }
+		defer func() { s.fn = fn }()
+		if open == '{' {
+			return s.nested(s.object)
+		}
+		return s.nested(s.array)
+	}
+	closers := []byte{open + 2} // '{'+2 is '}', '['+2 is ']'
+	for len(closers) > 0 {
+		if !s.more() {
+			return s.errorf("unexpected end of input in value started at offset %d", offset)
+		}
+		i := bytes.IndexAny(s.buf[s.pos:], `"{}[]`)
+		if i < 0 {
+			s.pos = len(s.buf)
+			continue
+		}
+		s.pos += i
+		switch c := s.buf[s.pos]; c {
+		case '"':
+			if err := s.skipString(); err != nil {
+				return err
+			}
+			continue
+		case '{', '[':
+			closers = append(closers, c+2)
+		default:
+			if c != closers[len(closers)-1] {
+				return s.errorf("unexpected %q in value started at offset %d", c, offset)
+			}
+			closers = closers[:len(closers)-1]
+		}
+		s.pos++
+	}
+	return nil
+}
+
+func (s *scanner) skipString() error {
+	s.pos++ // opening quote
+	for {
+		if !s.more() {
+			return s.errorf("unexpected end of input in string")
+		}
+		i := bytes.IndexAny(s.buf[s.pos:], `"\`)
+		if i < 0 {
+			s.pos = len(s.buf)
+			continue
+		}
+		s.pos += i
+		if s.buf[s.pos] == '"' {
+			s.pos++
+			return nil
+		}
+		s.pos++ // backslash
+		if !s.more() {
+			return s.errorf("unexpected end of input in string")
+		}
+		s.pos++ // escaped character
+	}
+}
@@ -0,0 +1,112 @@
+package jsonmap
+
+import (
+	"errors"
+	"strconv"
+)
+
+// UnmarshalSelect is same as UnmarshalJSON, but decodes only values at the given paths,
+// skipping everything else without decoding it.
+//
+// Paths are JSON Pointers, like "/meta/id", where "*" step matches any key or array index, like "/items/*/id".
+// Selected values keep their parent maps and arrays, with keys in original relative order.
+// Arrays keep only elements with selected values, so indices may shift.
+//
+// Note: it does not clear the map before unmarshaling.
+//
+//	err := jsonmap.UnmarshalSelect(data, m, "/id", "/items/*/name")
+func UnmarshalSelect(data []byte, m *Map, paths ...string) error {
+	sel := selector{builder: builder{root: m}, capture: -1}
+	for _, p := range paths {
+		steps, err := parsePointer(p)
+		if err != nil {
+			return err
+		}
+		sel.patterns = append(sel.patterns, steps)
+	}
+	return Scan(data, sel.event)
+}
+
+type matchKind int
+
+const (
+	matchNone   matchKind = iota
+	matchPrefix           // path leads to selected values
+	matchFull             // path is selected
+)
+
+// selector builds maps from Scan events, skipping values which don't match patterns.
+type selector struct {
+	builder
+	patterns [][]string
+	capture  int // depth of selected subtree being captured, or -1
+}
+
+func (s *selector) event(e *Event) error {
+	if s.capture >= 0 {
+		if (e.Kind == EventEndObject || e.Kind == EventEndArray) && len(e.Path) == s.capture {
+			s.capture = -1
+		}
+		return s.builder.event(e)
+	}
+
+	if e.Kind == EventEndObject || e.Kind == EventEndArray {
+		return s.builder.event(e)
+	}
+	if len(e.Path) == 0 && e.Kind != EventStartObject {
+		return errors.New("expected '{'")
+	}
+
+	match := s.match(e.Path)
+	switch {
+	case match == matchNone:
+		return SkipValue
+	case e.Kind == EventKey:
+		return s.builder.event(e)
+	case match == matchFull:
+		if e.Kind != EventValue {
+			s.capture = len(e.Path)
+		}
+		return s.builder.event(e)
+	case e.Kind == EventValue:
+		return nil // scalar can't lead to selected values
+	}
+	// container leading to selected values, dropped if nothing is selected in it
+	err := s.builder.event(e)
+	if len(s.stack) > 1 {
+		s.stack[len(s.stack)-1].optional = true
+	}
+	return err
+}
+
+func (s *selector) match(path Path) matchKind {
+	best := matchNone
+	for _, pattern := range s.patterns {
+		if len(path) > len(pattern) {
+			continue // deeper paths are handled by capture
+		}
+		ok := true
+		for i, step := range path {
+			if pattern[i] == "*" {
+				continue
+			}
+			switch step := step.(type) {
+			case string:
+				ok = pattern[i] == step
+			case int:
+				ok = pattern[i] == strconv.Itoa(step)
+			}
+			if !ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if len(path) == len(pattern) {
+			return matchFull
+		}
+		best = matchPrefix
+	}
+	return best
+}
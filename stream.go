@@ -0,0 +1,252 @@
+package jsonmap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ArrayStream reads elements of a JSON array one at a time, from a document too large to decode at once.
+// The array is located by JSON Pointer, like "/items". Only the current element is kept in memory.
+//
+// The rest of the document is decoded as usual, and is available via Map after the stream is finished.
+// In that map, the streamed array is replaced with an empty array, keeping its position.
+//
+//	s := jsonmap.NewArrayStream(r, "/items")
+//	for s.Next() {
+//	    item, _ := s.Value().(*jsonmap.Map)
+//	    fmt.Println(item)
+//	}
+//	if err := s.Err(); err != nil {
+//	    return err
+//	}
+//	fmt.Println(s.Map()) // all keys except "items"
+type ArrayStream struct {
+	dec     *json.Decoder
+	pointer string
+	root    *Map
+	frames  []func() error // finish decoding of containers around the array, innermost last
+	started bool
+	done    bool
+	index   int
+	value   Value
+	err     error
+}
+
+// ErrPathNotFound is returned by ArrayStream.Err if the document has no array at the requested path.
+var ErrPathNotFound = errors.New("path not found")
+
+// NewArrayStream returns a stream of elements of the array at JSON Pointer path in r.
+// Empty pointer "" means the document itself is an array.
+//
+//	s := jsonmap.NewArrayStream(r, "/data/items")
+func NewArrayStream(r io.Reader, pointer string) *ArrayStream {
+	return &ArrayStream{dec: json.NewDecoder(r), pointer: pointer, index: -1}
+}
+
+// Next decodes the next element of the array, and reports whether there was one.
+// When the array ends, Next decodes the rest of the document and returns false.
+// Returns false on error too, check Err after the loop.
+func (s *ArrayStream) Next() bool {
+	if s.done {
+		return false
+	}
+	if !s.started {
+		s.started = true
+		if err := s.seek(); err != nil {
+			return s.fail(err)
+		}
+	}
+	tok, err := s.dec.Token()
+	if err != nil {
+		return s.fail(err)
+	}
+	if tok == json.Delim(']') {
+		s.done = true
+		s.value = nil
+		for i := len(s.frames) - 1; i >= 0; i-- {
+			if err := s.frames[i](); err != nil {
+				return s.fail(err)
+			}
+		}
+		return false
+	}
+	s.value, err = decodeValue(s.dec, tok)
+	if err != nil {
+		return s.fail(err)
+	}
+	s.index++
+	return true
+}
+
+func (s *ArrayStream) fail(err error) bool {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	s.err = err
+	s.done = true
+	s.value = nil
+	return false
+}
+
+// Value returns the current element. Nested objects are decoded as *Map.
+func (s *ArrayStream) Value() Value {
+	return s.value
+}
+
+// Index returns index of the current element in the array.
+func (s *ArrayStream) Index() int {
+	return s.index
+}
+
+// Err returns the first error met by Next.
+func (s *ArrayStream) Err() error {
+	return s.err
+}
+
+// Map returns the document without the streamed array.
+// Keys before the array are available after the first call to Next,
+// and keys after the array when Next returned false.
+// Returns nil if the document itself is the streamed array.
+func (s *ArrayStream) Map() *Map {
+	return s.root
+}
+
+// seek decodes the document up to the start of the array.
+func (s *ArrayStream) seek() error {
+	steps, err := parsePointer(s.pointer)
+	if err != nil {
+		return err
+	}
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		if tok != json.Delim('[') {
+			return fmt.Errorf("%w: %q is not an array", ErrPathNotFound, s.pointer)
+		}
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return errors.New("expected '{'")
+	}
+	s.root = New()
+
+	// current container is either map m, or array a, which is stored by storeArray when finished
+	m := s.root
+	var a *[]any
+	var storeArray func(Value)
+	for i, step := range steps {
+		step := step
+		var set func(Value)
+		if m != nil {
+			tok, err = s.seekKey(m, step)
+			parent := m
+			set = func(v Value) { parent.Set(step, v) }
+			s.frames = append(s.frames, func() error { return decodeMap(s.dec, parent) })
+		} else {
+			tok, err = s.seekIndex(a, step)
+			parent, n, store := a, len(*a), storeArray
+			*parent = append(*parent, nil)
+			set = func(v Value) { (*parent)[n] = v }
+			s.frames = append(s.frames, func() error {
+				if err := s.finishArray(parent); err != nil {
+					return err
+				}
+				store(*parent)
+				return nil
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		last := i == len(steps)-1
+		switch {
+		case last && tok == json.Delim('['):
+			set([]any{})
+			return nil
+		case !last && tok == json.Delim('{'):
+			child := New()
+			set(child)
+			m, a = child, nil
+		case !last && tok == json.Delim('['):
+			child := []any{}
+			set(child)
+			m, a, storeArray = nil, &child, set
+		default:
+			return fmt.Errorf("%w: %q", ErrPathNotFound, s.pointer)
+		}
+	}
+	return nil
+}
+
+// seekKey decodes elements of map m until the key, and returns the first token of its value.
+// Returns nil token if the map has no such key.
+func (s *ArrayStream) seekKey(m *Map, key string) (json.Token, error) {
+	for {
+		tok, err := s.dec.Token()
+		if err != nil || tok == json.Delim('}') {
+			return nil, err
+		}
+		k, ok := tok.(string)
+		if !ok {
+			return nil, errors.New("expected string key")
+		}
+		if tok, err = s.dec.Token(); err != nil {
+			return nil, err
+		}
+		if k == key {
+			return tok, nil
+		}
+		v, err := decodeValue(s.dec, tok)
+		if err != nil {
+			return nil, err
+		}
+		m.Push(k, v)
+	}
+}
+
+// seekIndex decodes elements of array a until the index, and returns the first token of the element.
+// Returns nil token if the array is shorter.
+func (s *ArrayStream) seekIndex(a *[]any, step string) (json.Token, error) {
+	index, err := strconv.Atoi(step)
+	if err != nil || index < 0 {
+		return nil, fmt.Errorf("%w: %q is not an array index", ErrPathNotFound, step)
+	}
+	for {
+		tok, err := s.dec.Token()
+		if err != nil || tok == json.Delim(']') {
+			return nil, err
+		}
+		if len(*a) == index {
+			return tok, nil
+		}
+		v, err := decodeValue(s.dec, tok)
+		if err != nil {
+			return nil, err
+		}
+		*a = append(*a, v)
+	}
+}
+
+// finishArray decodes the rest of array elements into a.
+func (s *ArrayStream) finishArray(a *[]any) error {
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+		if tok == json.Delim(']') {
+			return nil
+		}
+		v, err := decodeValue(s.dec, tok)
+		if err != nil {
+			return err
+		}
+		*a = append(*a, v)
+	}
+}
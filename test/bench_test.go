@@ -1,6 +1,7 @@
 package test_test
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -193,3 +194,45 @@ func (m GoMap) Keys() []string {
 	}
 	return keys
 }
+
+func BenchmarkUnmarshal(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"items":[`)
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `{"id":%d,"name":"item %d","tags":["a","b"],"price":%d.5,"ok":true}`, i, i, i)
+	}
+	buf.WriteString(`]}`)
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := jsonmap.New()
+		if err := m.UnmarshalJSON(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalSelect(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"items":[`)
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `{"id":%d,"name":"item %d","tags":["a","b"],"price":%d.5,"ok":true}`, i, i, i)
+	}
+	fmt.Fprintf(&buf, `],"total":%d}`, 1000)
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := jsonmap.New()
+		if err := jsonmap.UnmarshalSelect(data, m, "/total", "/items/0/id"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package test_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestMarshalBSON(t *testing.T) {
+	m := jsonmap.New()
+	m.Set("hello", "world")
+	data, err := m.MarshalBSON()
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "\x16\x00\x00\x00\x02hello\x00\x06\x00\x00\x00world\x00\x00")
+
+	m = jsonmap.New()
+	m.Set("BSON", []any{"awesome", 5.05, 1986})
+	data, err = m.MarshalBSON()
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "\x31\x00\x00\x00\x04BSON\x00\x26\x00\x00\x00\x020\x00\x08\x00\x00\x00awesome\x00"+
+		"\x011\x00\x33\x33\x33\x33\x33\x33\x14\x40\x102\x00\xc2\x07\x00\x00\x00\x00")
+
+	back := &jsonmap.Map{} // zero value, as allocated by MongoDB driver
+	assert.NoError(t, back.UnmarshalBSON(data))
+	v, _ := back.Get("BSON")
+	assert.Equal(t, v, []any{"awesome", 5.05, int32(1986)})
+}
+
+func TestBSONRoundTrip(t *testing.T) {
+	scope := jsonmap.New()
+	scope.Set("x", int32(1))
+	nested := jsonmap.New()
+	nested.Set("z", "last")
+	nested.Set("y", []any{})
+	values := []any{
+		1.5, "", "text", nested, []any{nil, true, false},
+		[]byte{1, 2, 3},
+		jsonmap.BSONBinary{Subtype: 4, Data: []byte("0123456789abcdef")},
+		jsonmap.BSONBinary{Subtype: 2, Data: []byte{7}},
+		jsonmap.BSONObjectID{0x50, 0x7f, 0x1f, 0x77, 0xbc, 0xf8, 0x6c, 0xd7, 0x99, 0x43, 0x90, 0x11},
+		time.Date(2024, 2, 29, 12, 30, 0, 123e6, time.UTC),
+		int32(math.MinInt32), int64(math.MaxInt64),
+		jsonmap.BSONDecimal128{High: 0x3040000000000000, Low: 1},
+		jsonmap.BSONTimestamp{T: 1700000000, I: 3},
+		jsonmap.BSONRegex{Pattern: "^a.*", Options: "im"},
+		jsonmap.BSONJavaScript("function() {}"),
+		jsonmap.BSONCodeWithScope{Code: "x", Scope: scope},
+		jsonmap.BSONSymbol("sym"),
+		jsonmap.BSONDBPointer{Ref: "db.coll", ID: jsonmap.BSONObjectID{1}},
+		jsonmap.BSONMinKey{}, jsonmap.BSONMaxKey{}, nil,
+	}
+	m := jsonmap.New()
+	for i, v := range values {
+		m.Set(string(rune('z'-i)), v)
+	}
+	data, err := m.MarshalBSON()
+	assert.NoError(t, err)
+
+	back := jsonmap.New()
+	assert.NoError(t, back.UnmarshalBSON(data))
+	assert.Equal(t, back.Keys(), m.Keys())
+	i := 0
+	for el := back.First(); el != nil; el = el.Next() {
+		switch v := el.Value().(type) {
+		case *jsonmap.Map:
+			assert.Equal(t, v.Keys(), []string{"z", "y"})
+		case jsonmap.BSONCodeWithScope:
+			assert.Equal(t, v.Code, "x")
+			assert.Equal(t, v.Scope.Keys(), []string{"x"})
+		default:
+			assert.Equal(t, v, values[i])
+		}
+		i++
+	}
+
+	data2, err := back.MarshalBSON()
+	assert.NoError(t, err)
+	assert.Equal(t, data2, data)
+}
+
+func TestMarshalBSONTypes(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, json.Unmarshal([]byte(`{"small":1,"big":10000000000,"float":1.5}`), m))
+	m.Set("u16", uint16(7))
+	m.Set("u32", uint32(7))
+	m.Set("f32", float32(0.5))
+	m.Set("struct", struct{ A int }{1}) // converted through JSON
+	data, err := m.MarshalBSON()
+	assert.NoError(t, err)
+
+	back := jsonmap.New()
+	assert.NoError(t, back.UnmarshalBSON(data))
+	out, err := json.Marshal(back)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"small":1,"big":10000000000,"float":1.5,"u16":7,"u32":7,"f32":0.5,"struct":{"A":1}}`)
+	for key, want := range map[string]any{
+		"small": 1.0, "big": 1e10, "float": 1.5, // JSON numbers are float64
+		"u16": int32(7), "u32": int64(7), "f32": 0.5,
+	} {
+		v, _ := back.Get(key)
+		assert.Equal(t, v, want)
+	}
+
+	m = jsonmap.New()
+	m.Set("u", uint64(math.MaxUint64))
+	_, err = m.MarshalBSON()
+	assert.Error(t, err)
+
+	m = jsonmap.New()
+	m.Set("a\x00b", 1)
+	_, err = m.MarshalBSON()
+	assert.Error(t, err)
+}
+
+func TestBSONDecimal128(t *testing.T) {
+	for _, tc := range []struct {
+		high, low uint64
+		want      string
+	}{
+		{0x3040000000000000, 0, "0"},
+		{0x3040000000000000, 1, "1"},
+		{0xb040000000000000, 1, "-1"},
+		{0x303a000000000000, 1234, "1.234"},
+		{0x3034000000000000, 1, "0.000001"},
+		{0x3032000000000000, 1, "1E-7"},
+		{0x3046000000000000, 1, "1E+3"},
+		{0x3046000000000000, 12, "1.2E+4"},
+		{0x7800000000000000, 0, "Infinity"},
+		{0xf800000000000000, 0, "-Infinity"},
+		{0x7c00000000000000, 0, "NaN"},
+	} {
+		assert.Equal(t, jsonmap.BSONDecimal128{High: tc.high, Low: tc.low}.String(), tc.want)
+	}
+}
+
+func TestUnmarshalBSONErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"\x05\x00\x00",                              // truncated length
+		"\x06\x00\x00\x00\x00",                      // length mismatch
+		"\x05\x00\x00\x00\x00\x00",                  // trailing data
+		"\x08\x00\x00\x00\x0aa\x00\x01",             // no terminating zero
+		"\x09\x00\x00\x00\x08a\x00\x02\x00",         // invalid boolean
+		"\x08\x00\x00\x00\x14a\x00\x00",             // unknown type
+		"\x0c\x00\x00\x00\x02a\x00\x09\x00\x00\x00", // truncated string
+		"\x0d\x00\x00\x00\x05a\x00\xff\xff\xff\xff\x00\x00", // negative binary length
+	} {
+		err := (&jsonmap.Map{}).UnmarshalBSON([]byte(data))
+		assert.Error(t, err)
+	}
+
+	err := jsonmap.New().UnmarshalBSON([]byte("\x08\x00\x00\x00\x14a\x00\x00"))
+	var formatErr *jsonmap.FormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.Equal(t, err.Error(), "jsonmap: bson: unknown element type 0x14 at offset 7")
+
+	// documents nested deeper than 10000 levels
+	const depth = 10001
+	var deep []byte
+	for i := depth; i > 0; i-- {
+		deep = binary.LittleEndian.AppendUint32(deep, uint32(5+8*i))
+		deep = append(deep, 0x03, 'a', 0)
+	}
+	deep = append(deep, 5, 0, 0, 0, 0)
+	deep = append(deep, make([]byte, depth)...)
+	err = jsonmap.New().UnmarshalBSON(deep)
+	assert.True(t, errors.As(err, &formatErr))
+	assert.True(t, strings.Contains(err.Error(), "exceeded max depth"))
+}
@@ -0,0 +1,193 @@
+package test_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+// cborValue decodes hex of a value, wrapped in map {"v": value}.
+func cborValue(t *testing.T, h string) any {
+	t.Helper()
+	data, err := hex.DecodeString("a16176" + h)
+	assert.NoError(t, err)
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalCBOR(data, m))
+	v, ok := m.Get("v")
+	assert.True(t, ok)
+	return v
+}
+
+// cborHex encodes map {"v": value}, and returns hex of the value.
+func cborHex(t *testing.T, v any) string {
+	t.Helper()
+	m := jsonmap.New()
+	m.Set("v", v)
+	data, err := jsonmap.MarshalCBOR(m)
+	assert.NoError(t, err)
+	return hex.EncodeToString(data)[6:]
+}
+
+func TestCBORVectors(t *testing.T) {
+	bigPos, _ := new(big.Int).SetString("18446744073709551616", 10)
+	bigNeg, _ := new(big.Int).SetString("-18446744073709551617", 10)
+	for _, tc := range []struct {
+		value any
+		hex   string
+	}{
+		{int64(0), "00"},
+		{int64(23), "17"},
+		{int64(24), "1818"},
+		{int64(1000000), "1a000f4240"},
+		{uint64(math.MaxUint64), "1bffffffffffffffff"},
+		{bigPos, "c249010000000000000000"},
+		{bigNeg, "c349010000000000000000"},
+		{int64(-1), "20"},
+		{int64(-1000), "3903e7"},
+		{0.0, "f90000"},
+		{math.Copysign(0, -1), "f98000"},
+		{1.0, "f93c00"},
+		{1.1, "fb3ff199999999999a"},
+		{1.5, "f93e00"},
+		{65504.0, "f97bff"},
+		{100000.0, "fa47c35000"},
+		{3.4028234663852886e+38, "fa7f7fffff"},
+		{1.0e+300, "fb7e37e43c8800759c"},
+		{5.960464477539063e-8, "f90001"},
+		{0.00006103515625, "f90400"},
+		{-4.0, "f9c400"},
+		{-4.1, "fbc010666666666666"},
+		{math.Inf(1), "f97c00"},
+		{math.Inf(-1), "f9fc00"},
+		{false, "f4"},
+		{true, "f5"},
+		{nil, "f6"},
+		{jsonmap.CBORSimple(16), "f0"},
+		{jsonmap.CBORSimple(255), "f8ff"},
+		{time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), "c11a514b67b0"},
+		{jsonmap.CBORTag{Number: 32, Content: "http://www.example.com"}, "d82076687474703a2f2f7777772e6578616d706c652e636f6d"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{"", "60"},
+		{"ü", "62c3bc"},
+		{"\U00010151", "64f0908591"},
+		{[]any{}, "80"},
+		{[]any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}, "8301820203820405"},
+	} {
+		assert.Equal(t, cborHex(t, tc.value), tc.hex)
+		assert.Equal(t, cborValue(t, tc.hex), tc.value)
+	}
+
+	assert.True(t, math.IsNaN(cborValue(t, "f97e00").(float64)))
+	assert.Equal(t, cborHex(t, math.NaN()), "f97e00")
+	assert.Equal(t, cborValue(t, "fa47c35000"), 100000.0)
+	assert.Equal(t, cborValue(t, "fb3ff0000000000000"), 1.0)
+	assert.Equal(t, cborValue(t, "f7"), nil) // undefined
+	assert.Equal(t, cborValue(t, "c074323031332d30332d32315432303a30343a30305a"), time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC))
+	assert.Equal(t, cborValue(t, "c1fb41d452d9ec200000"), time.Date(2013, 3, 21, 20, 4, 0, 5e8, time.UTC))
+	assert.Equal(t, cborHex(t, time.Date(2013, 3, 21, 20, 4, 0, 5e8, time.UTC)), "c076323031332d30332d32315432303a30343a30302e355a")
+	assert.Equal(t, cborValue(t, "d9d9f71818"), int64(24)) // self-described
+	assert.Equal(t, cborValue(t, "5f42010243030405ff"), []byte{1, 2, 3, 4, 5})
+	assert.Equal(t, cborValue(t, "7f657374726561646d696e67ff"), "streaming")
+	assert.Equal(t, cborValue(t, "9fff"), []any{})
+	assert.Equal(t, cborValue(t, "9f018202039f0405ffff"), []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}})
+}
+
+func TestCBORMaps(t *testing.T) {
+	data, err := hex.DecodeString("bf61620161619f0203ff6163a2617a01617902ff")
+	assert.NoError(t, err)
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalCBOR(data, m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"b":1,"a":[2,3],"c":{"z":1,"y":2}}`)
+
+	// nested maps are *Map, also inside arrays and tags
+	data, err = hex.DecodeString("a1617881d82aa1617901")
+	assert.NoError(t, err)
+	m = jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalCBOR(data, m))
+	x, _ := m.Get("x")
+	tag := x.([]any)[0].(jsonmap.CBORTag)
+	assert.Equal(t, tag.Number, uint64(42))
+	assert.Equal(t, tag.Content.(*jsonmap.Map).Keys(), []string{"y"})
+
+	// integer keys
+	data, err = hex.DecodeString("a20126206178")
+	assert.NoError(t, err)
+	m = jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalCBOR(data, m))
+	assert.Equal(t, m.Keys(), []string{"1", "-1"})
+}
+
+func TestCBORDeterministic(t *testing.T) {
+	m := jsonmap.New()
+	m.Set("b", 1)
+	m.Set("aa", 2)
+	m.Set("a", 3)
+	m.Set("-1", 4)
+	m.Set("10", 5)
+	nested := jsonmap.New()
+	nested.Set("z", 1.0)
+	nested.Set("y", 2.5)
+	m.Set("n", nested)
+
+	data, err := jsonmap.MarshalCBOR(m)
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(data), "a6"+"616201"+"62616102"+"616103"+"622d3104"+"62313005"+"616e"+"a2617af93c006179f94100")
+
+	data, err = jsonmap.CBOREncoder{Deterministic: true}.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(data), "a6"+"616103"+"616201"+"616e"+"a26179f94100617af93c00"+"622d3104"+"62313005"+"62616102")
+
+	data, err = jsonmap.CBOREncoder{Deterministic: true, IntegerKeys: true, SelfDescribed: true}.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(data), "d9d9f7"+"a6"+"0a05"+"2004"+"616103"+"616201"+"616e"+"a26179f94100617af93c00"+"62616102")
+
+	back := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalCBOR(data, back))
+	assert.Equal(t, back.Keys(), []string{"10", "-1", "a", "b", "n", "aa"})
+}
+
+func TestUnmarshalCBORErrors(t *testing.T) {
+	for _, h := range []string{
+		"",
+		"8101",               // not a map
+		"a16161",             // truncated
+		"a1616101ff",         // trailing data
+		"a1f601",             // null key
+		"a16161ff",           // break in definite map
+		"a161611c",           // reserved additional information
+		"a16161f818",         // two-byte simple value below 32
+		"a161615f4101610200", // text chunk in byte string
+		"a1616162c328",       // invalid UTF-8 in text string
+		"a16161c06161",       // date/time tag with invalid string
+		"bbffffffffffffffff", // huge map
+	} {
+		data, err := hex.DecodeString(h)
+		assert.NoError(t, err)
+		err = jsonmap.UnmarshalCBOR(data, jsonmap.New())
+		assert.Error(t, err)
+	}
+
+	data, _ := hex.DecodeString("a161611c")
+	err := jsonmap.UnmarshalCBOR(data, jsonmap.New())
+	var formatErr *jsonmap.FormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.Equal(t, err.Error(), "jsonmap: cbor: invalid additional information 28 at offset 3")
+
+	for _, nested := range []byte{0x81, 0xc6} { // arrays and tags
+		deep := append([]byte{0xa1, 0x61, 'a'}, bytes.Repeat([]byte{nested}, 5000000)...)
+		err = jsonmap.UnmarshalCBOR(deep, jsonmap.New())
+		assert.True(t, errors.As(err, &formatErr))
+		assert.True(t, strings.Contains(err.Error(), "exceeded max depth"))
+	}
+}
@@ -0,0 +1,130 @@
+package test_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+const manifest = `// package manifest
+{
+  "name": "app", // the name
+  "version": "1.2.3",
+
+  /* build settings */
+  "scripts": {
+    "build": "go build",
+    "test": "go test"
+  },
+  "files": ["a.go",  "b.go"],
+  "ratio": 1.50,
+  "unicode": "é",
+  "empty": { },
+}
+`
+
+func TestPreserveRoundTrip(t *testing.T) {
+	for _, data := range []string{
+		manifest,
+		`{"a":1,"b":[1,2],"c":{}}`,
+		"\t{ \"a\" : 1 /* c */ , \"b\" : { \"x\" : null } }\n\n",
+		`{}`,
+	} {
+		m := jsonmap.New()
+		assert.NoError(t, jsonmap.UnmarshalPreserve([]byte(data), m))
+		out, err := jsonmap.MarshalPreserve(m)
+		assert.NoError(t, err)
+		assert.Equal(t, string(out), data)
+	}
+}
+
+func TestPreserveEdit(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalPreserve([]byte(manifest), m))
+
+	m.Set("version", "1.2.4")
+	m.InsertAfter("license", "MIT", m.GetElement("version"))
+	m.Delete("unicode")
+	scripts, _ := jsonmap.GetAs[*jsonmap.Map](m, "scripts")
+	scripts.Delete("test")
+	scripts.Set("lint", "go vet")
+	files, _ := m.Get("files")
+	m.Set("files", append(files.([]any), "c.go"))
+	deps := jsonmap.New()
+	deps.Set("x", "^1.0.0")
+	m.Set("deps", deps)
+
+	out, err := jsonmap.MarshalPreserve(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `// package manifest
+{
+  "name": "app", // the name
+  "version": "1.2.4",
+  "license": "MIT",
+
+  /* build settings */
+  "scripts": {
+    "build": "go build",
+    "lint": "go vet"
+  },
+  "files": ["a.go", "b.go", "c.go"],
+  "ratio": 1.50,
+  "empty": { },
+  "deps": {
+    "x": "^1.0.0"
+  },
+}
+`)
+}
+
+func TestPreserveClear(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalPreserve([]byte(manifest), m))
+	m.Clear()
+	m.Set("name", "other")
+	out, err := jsonmap.MarshalPreserve(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), "{\n  \"name\": \"other\"\n}")
+}
+
+func TestPreserveInline(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalPreserve([]byte(`{"a":1,"b":2} // end`), m))
+	m.Delete("a")
+	m.Set("c", []any{1, 2})
+	m.PushFront("a", 0)
+	out, err := jsonmap.MarshalPreserve(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"a":0,"b":2,"c":[1, 2]} // end`)
+
+	// moved element keeps its comment
+	m = jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalPreserve([]byte("{\n\t\"a\": 1, // first\n\t\"b\": 2 // last\n}"), m))
+	m.InsertBefore("b", 3, m.GetElement("a"))
+	m.Set("n", jsonmap.New())
+	n, _ := jsonmap.GetAs[*jsonmap.Map](m, "n")
+	n.Set("x", []any{true})
+	out, err = jsonmap.MarshalPreserve(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), "{\n\t\"b\": 3, // last\n\t\"a\": 1, // first\n\t\"n\": {\n\t\t\"x\": [\n\t\t\ttrue\n\t\t]\n\t}\n}")
+}
+
+func TestPreserveErrors(t *testing.T) {
+	for _, data := range []string{`[1]`, `{"a" 1}`, `{"a": 1} x`, `{"a": 1 /* x`, `{"a": [1 2]}`} {
+		assert.Error(t, jsonmap.UnmarshalPreserve([]byte(data), jsonmap.New()))
+	}
+
+	// same depth limit as UnmarshalJSONC
+	for _, n := range []int{9999, 10000} {
+		data := []byte(`{"a":` + strings.Repeat("[", n) + strings.Repeat("]", n) + "}")
+		errC := jsonmap.UnmarshalJSONC(data, jsonmap.New())
+		err := jsonmap.UnmarshalPreserve(data, jsonmap.New())
+		assert.Equal(t, fmt.Sprint(err), fmt.Sprint(errC))
+	}
+	err := jsonmap.UnmarshalPreserve([]byte(`{"a":`+strings.Repeat(`{"a":`, 3000000)), jsonmap.New())
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "exceeded max depth 10000"))
+}
@@ -0,0 +1,88 @@
+package test_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func csvRows(t *testing.T, docs ...string) []*jsonmap.Map {
+	t.Helper()
+	rows := make([]*jsonmap.Map, len(docs))
+	for i, doc := range docs {
+		rows[i] = parse(t, doc)
+	}
+	return rows
+}
+
+func TestWriteCSV(t *testing.T) {
+	rows := csvRows(t,
+		`{"id":1,"name":"Ann","tags":["a","b"],"addr":{"city":"Oslo","zip":"0150"}}`,
+		`{"id":2,"email":"bob@example.com","name":"Bob, Jr.","active":true,"addr":null}`,
+	)
+
+	var buf bytes.Buffer
+	assert.NoError(t, jsonmap.WriteCSV(&buf, rows, jsonmap.CSVOptions{}))
+	assert.Equal(t, buf.String(), "id,name,tags,addr,email,active\n"+
+		`1,Ann,"[""a"",""b""]","{""city"":""Oslo"",""zip"":""0150""}",,`+"\n"+
+		`2,"Bob, Jr.",,,bob@example.com,true`+"\n")
+
+	buf.Reset()
+	assert.NoError(t, jsonmap.WriteCSV(&buf, rows, jsonmap.CSVOptions{Comma: '\t', Flatten: true}))
+	assert.Equal(t, buf.String(), "id\tname\ttags.0\ttags.1\taddr.city\taddr.zip\temail\tactive\taddr\n"+
+		"1\tAnn\ta\tb\tOslo\t0150\t\t\t\n"+
+		"2\tBob, Jr.\t\t\t\t\tbob@example.com\ttrue\t\n")
+}
+
+func TestReadCSV(t *testing.T) {
+	rows, err := jsonmap.ReadCSV(strings.NewReader("\uFEFFz,a,m\n1,true,\n\"x,y\",02,-1.5e3\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, len(rows), 2)
+	assert.Equal(t, rows[0].Keys(), []string{"z", "a", "m"})
+	out, err := json.Marshal(rows[1])
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"z":"x,y","a":"02","m":"-1.5e3"}`)
+
+	rows, err = jsonmap.CSVOptions{Infer: true}.ReadCSV(strings.NewReader("z,a,m\n1,true,\n\"x,y\",02,-1.5e3\n"))
+	assert.NoError(t, err)
+	out, err = json.Marshal(rows)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `[{"z":1,"a":true,"m":null},{"z":"x,y","a":"02","m":-1500}]`)
+
+	rows, err = jsonmap.CSVOptions{Comma: '\t', Flatten: true, Infer: true}.ReadCSV(strings.NewReader(
+		"id\ttags.0\ttags.1\taddr.city\n1\ta\tb\tOslo\n"))
+	assert.NoError(t, err)
+	out, err = json.Marshal(rows)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `[{"id":1,"tags":["a","b"],"addr":{"city":"Oslo"}}]`)
+
+	rows, err = jsonmap.ReadCSV(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Equal(t, len(rows), 0)
+
+	_, err = jsonmap.ReadCSV(strings.NewReader("a,a\n1,2\n"))
+	assert.Error(t, err)
+	_, err = jsonmap.ReadCSV(strings.NewReader("a,b\n1\n"))
+	assert.Error(t, err)
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	rows := csvRows(t,
+		`{"b":1,"a":{"y":[true,"s"],"x":2.5}}`,
+		`{"b":3,"a":{"y":[false,"t"],"x":-1}}`,
+	)
+	opts := jsonmap.CSVOptions{Flatten: true, Infer: true, Separator: "/"}
+	var buf bytes.Buffer
+	assert.NoError(t, jsonmap.WriteCSV(&buf, rows, opts))
+	back, err := opts.ReadCSV(&buf)
+	assert.NoError(t, err)
+	want, err := json.Marshal(rows)
+	assert.NoError(t, err)
+	got, err := json.Marshal(back)
+	assert.NoError(t, err)
+	assert.Equal(t, string(got), string(want))
+}
@@ -0,0 +1,70 @@
+package test_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func decodeAll(t *testing.T, input string) []string {
+	t.Helper()
+	d := jsonmap.NewDecoder(strings.NewReader(input))
+	var out []string
+	for d.More() {
+		m := jsonmap.New()
+		assert.NoError(t, d.Decode(m))
+		data, err := json.Marshal(m)
+		assert.NoError(t, err)
+		out = append(out, string(data))
+	}
+	m := jsonmap.New()
+	assert.Equal(t, d.Decode(m), io.EOF)
+	return out
+}
+
+func TestDecoder(t *testing.T) {
+	const want = `{"b":1,"a":2} {"z":{"y":[1]},"x":null}`
+	for name, input := range map[string]string{
+		"NDJSON":       "{\"b\":1,\"a\":2}\n{\"z\":{\"y\":[1]},\"x\":null}\n",
+		"Sequence":     "\x1e{\"b\":1,\"a\":2}\n\x1e{\"z\":{\"y\":[1]},\"x\":null}\n",
+		"Concatenated": `{"b":1,"a":2}{"z":{"y":[1]},"x":null}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, strings.Join(decodeAll(t, input), " "), want)
+		})
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	d := jsonmap.NewDecoder(strings.NewReader("{\"a\":1}\n[1]\n"))
+	assert.NoError(t, d.Decode(jsonmap.New()))
+	err := d.Decode(jsonmap.New())
+	var decErr *jsonmap.DecodeError
+	assert.True(t, errors.As(err, &decErr))
+	assert.Equal(t, decErr.Record, 2)
+
+	d = jsonmap.NewDecoder(strings.NewReader(`{"a":1}{"b":`))
+	assert.NoError(t, d.Decode(jsonmap.New()))
+	assert.True(t, errors.Is(d.Decode(jsonmap.New()), io.ErrUnexpectedEOF))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d = jsonmap.NewDecoderContext(ctx, strings.NewReader(`{"a":1} {"b":2}`))
+	assert.NoError(t, d.Decode(jsonmap.New()))
+	cancel()
+	assert.False(t, d.More())
+	err = d.Decode(jsonmap.New())
+	assert.True(t, errors.As(err, &decErr))
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, decErr.Record, 2)
+
+	// RS is a separator only outside strings
+	d = jsonmap.NewDecoder(strings.NewReader("\x1e{\"a\":\"\\\"\x1e\"}\n"))
+	err = d.Decode(jsonmap.New())
+	assert.True(t, errors.As(err, &decErr))
+}
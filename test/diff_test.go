@@ -0,0 +1,47 @@
+package test_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func parse(t *testing.T, data string) *jsonmap.Map {
+	t.Helper()
+	m := jsonmap.New()
+	err := json.Unmarshal([]byte(data), &m)
+	assert.NoError(t, err)
+	return m
+}
+
+func TestDiff(t *testing.T) {
+	a := parse(t, `{"a":1,"b":2,"c":3,"d":{"x":1,"y":[1,2,3]},"e":5}`)
+	b := parse(t, `{"c":3,"a":1,"b":20,"d":{"x":1,"y":[1,4]},"f":6}`)
+
+	changes := jsonmap.Diff(a, b)
+	assert.Equal(t, jsonmap.FormatDiff(changes), ""+
+		"- /e: 5\n"+
+		"~ /c: 2 -> 0\n"+
+		"- /b: 2\n"+
+		"+ /b: 20\n"+
+		"- /d/y/1: 2\n"+
+		"+ /d/y/1: 4\n"+
+		"- /d/y/2: 3\n"+
+		"+ /f: 6\n")
+
+	data, err := json.Marshal(changes[:3])
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `[{"kind":"removed","path":"/e","old":5},{"kind":"moved","path":"/c","from":2,"to":0},{"kind":"changed","path":"/b","old":2,"new":20}]`)
+
+	assert.Equal(t, len(jsonmap.Diff(a, a)), 0)
+}
+
+func TestDiffPathEscaping(t *testing.T) {
+	a := parse(t, `{"a/b":{"c~d":1}}`)
+	b := parse(t, `{"a/b":{"c~d":2}}`)
+	changes := jsonmap.Diff(a, b)
+	assert.Equal(t, len(changes), 1)
+	assert.Equal(t, changes[0].Path.String(), "/a~1b/c~0d")
+}
@@ -0,0 +1,73 @@
+package test_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestUnmarshalDotenv(t *testing.T) {
+	data := `# settings
+export APP_NAME=demo
+DB__HOST = localhost   # inline comment
+DB__PORT=5432
+URL=http://x/#anchor
+SINGLE='no \n escapes # here'
+DOUBLE="line1\nline2 \"q\"" # comment
+MULTI="first
+second"
+EMPTY=
+  INDENTED=yes
+`
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalDotenv([]byte(data), m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"APP_NAME":"demo","DB__HOST":"localhost","DB__PORT":"5432","URL":"http://x/#anchor",`+
+		`"SINGLE":"no \\n escapes # here","DOUBLE":"line1\nline2 \"q\"","MULTI":"first\nsecond","EMPTY":"","INDENTED":"yes"}`)
+
+	m = jsonmap.New()
+	assert.NoError(t, jsonmap.KeyValueOptions{Unflatten: true, Separator: "__"}.UnmarshalDotenv([]byte(data), m))
+	assert.Equal(t, m.Keys(), []string{"APP_NAME", "DB", "URL", "SINGLE", "DOUBLE", "MULTI", "EMPTY", "INDENTED"})
+	db, _ := m.Get("DB")
+	assert.Equal(t, db.(*jsonmap.Map).Keys(), []string{"HOST", "PORT"})
+
+	for _, data := range []string{
+		"1KEY=x",
+		"KEY x",
+		`KEY="unterminated`,
+		`KEY="x" y`,
+	} {
+		err := jsonmap.UnmarshalDotenv([]byte(data), jsonmap.New())
+		assert.Error(t, err)
+	}
+	err = jsonmap.UnmarshalDotenv([]byte("A=1\nB=\"x\nC=2\n"), jsonmap.New())
+	assert.Equal(t, err.Error(), `jsonmap: dotenv: unterminated quoted value of key "B" at line 2`)
+}
+
+func TestMarshalDotenv(t *testing.T) {
+	m := parse(t, `{"Z":"plain-value_1.0","db":{"host":"h","ports":[1,2]},"SPACE":"a b","QUOTE":"it's","NL":"a\nb","NULL":null}`)
+	data, err := jsonmap.MarshalDotenv(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `Z=plain-value_1.0
+db.host=h
+db.ports.0=1
+db.ports.1=2
+SPACE='a b'
+QUOTE="it's"
+NL="a\nb"
+NULL=
+`)
+
+	opts := jsonmap.KeyValueOptions{Unflatten: true}
+	back := jsonmap.New()
+	assert.NoError(t, opts.UnmarshalDotenv(data, back))
+	out, err := json.Marshal(back)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"Z":"plain-value_1.0","db":{"host":"h","ports":["1","2"]},"SPACE":"a b","QUOTE":"it's","NL":"a\nb","NULL":""}`)
+
+	_, err = jsonmap.MarshalDotenv(parse(t, `{"bad key":1}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package test_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestEncoder(t *testing.T) {
+	meta := parse(t, `{"b":1,"a":[1,{"y":"<>","x":null}]}`)
+
+	encode := func(e *jsonmap.Encoder) {
+		assert.NoError(t, e.BeginObject())
+		assert.NoError(t, e.Key("meta"))
+		assert.NoError(t, e.WriteMap(meta))
+		assert.NoError(t, e.Key("items"))
+		assert.NoError(t, e.BeginArray())
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, e.Value(i))
+		}
+		assert.NoError(t, e.BeginObject())
+		assert.NoError(t, e.EndObject())
+		assert.NoError(t, e.EndArray())
+		assert.NoError(t, e.EndObject())
+	}
+
+	var buf bytes.Buffer
+	encode(jsonmap.NewEncoder(&buf))
+	expected, err := json.Marshal(meta)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.String(), `{"meta":`+string(expected)+`,"items":[0,1,2,{}]}`+"\n")
+
+	buf.Reset()
+	e := jsonmap.NewEncoder(&buf)
+	e.SetIndent("", "  ")
+	e.SetEscapeHTML(false)
+	encode(e)
+	assert.Equal(t, buf.String(), `{
+  "meta": {
+    "b": 1,
+    "a": [
+      1,
+      {
+        "y": "<>",
+        "x": null
+      }
+    ]
+  },
+  "items": [
+    0,
+    1,
+    2,
+    {}
+  ]
+}
+`)
+}
+
+func TestEncoderNil(t *testing.T) {
+	m := jsonmap.New()
+	m.Set("m", (*jsonmap.Map)(nil))
+	m.Set("a", []any(nil))
+	m.Set("v", nil)
+	expected, err := json.Marshal(m)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	e := jsonmap.NewEncoder(&buf)
+	assert.NoError(t, e.WriteMap(m))
+	assert.NoError(t, e.Value((*jsonmap.Map)(nil)))
+	assert.Equal(t, buf.String(), string(expected)+"\nnull\n")
+}
+
+func TestEncoderFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	e := jsonmap.NewEncoder(w)
+	e.SetFlushEvery(2)
+	assert.NoError(t, e.BeginArray())
+	assert.NoError(t, e.Value(1))
+	assert.False(t, w.Flushed)
+	assert.NoError(t, e.Value(2))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, w.Body.String(), `[1,2`)
+}
+
+// flushRecorder records output at each flush.
+type flushRecorder struct {
+	bytes.Buffer
+	flushed []string
+}
+
+func (w *flushRecorder) Flush() error {
+	w.flushed = append(w.flushed, w.String())
+	return nil
+}
+
+func TestEncoderFlushNested(t *testing.T) {
+	w := &flushRecorder{}
+	e := jsonmap.NewEncoder(w)
+	e.SetFlushEvery(2)
+	assert.NoError(t, e.BeginArray())
+	assert.NoError(t, e.Value([]any{1, 2, 3}))
+	assert.Equal(t, w.flushed, []string{`[[1,2`, `[[1,2,3]`})
+}
+
+func TestEncoderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	e := jsonmap.NewEncoder(&buf)
+	assert.Error(t, e.Key("a"))
+	assert.Error(t, e.BeginObject()) // sticky
+
+	e = jsonmap.NewEncoder(&buf)
+	assert.NoError(t, e.BeginObject())
+	assert.Error(t, e.Value(1))
+
+	e = jsonmap.NewEncoder(&buf)
+	assert.NoError(t, e.BeginArray())
+	assert.Error(t, e.EndObject())
+
+	e = jsonmap.NewEncoder(&buf)
+	assert.NoError(t, e.BeginObject())
+	assert.NoError(t, e.Key("a"))
+	assert.Error(t, e.EndObject())
+}
@@ -0,0 +1,91 @@
+package test_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestFlatten(t *testing.T) {
+	const nested = `{"z":1,"a":{"y":[1,{"c":2},[]],"b":{}},"x":null}`
+	m := parse(t, nested)
+
+	flat := jsonmap.Flatten(m, ".")
+	data, err := json.Marshal(flat)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"z":1,"a.y.0":1,"a.y.1.c":2,"a.y.2":[],"a.b":{},"x":null}`)
+
+	m2, err := jsonmap.Unflatten(flat, ".")
+	assert.NoError(t, err)
+	data, err = json.Marshal(m2)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), nested)
+
+	m3, err := jsonmap.Flattener{Separator: "."}.Unflatten(flat)
+	assert.NoError(t, err)
+	data, err = json.Marshal(m3)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"z":1,"a":{"y":{"0":1,"1":{"c":2},"2":[]},"b":{}},"x":null}`)
+}
+
+func TestFlattenOptions(t *testing.T) {
+	const nested = `{"a.b":{"c\\d":[1,2]}}`
+	m := parse(t, nested)
+
+	f := jsonmap.Flattener{
+		Separator:   ".",
+		Escape:      `\`,
+		FormatIndex: func(i int) string { return fmt.Sprintf("[%d]", i) },
+		ParseIndex: func(s string) (int, bool) {
+			if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+				return 0, false
+			}
+			i, err := strconv.Atoi(s[1 : len(s)-1])
+			return i, err == nil
+		},
+		Arrays: true,
+	}
+	flat := f.Flatten(m)
+	assert.Equal(t, strings.Join(flat.Keys(), " "), `a\.b.c\\d.[0] a\.b.c\\d.[1]`)
+
+	m2, err := f.Unflatten(flat)
+	assert.NoError(t, err)
+	data, err := json.Marshal(m2)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), nested)
+}
+
+func TestUnflattenConflict(t *testing.T) {
+	_, err := jsonmap.Unflatten(parse(t, `{"a":1,"a.b":2}`), ".")
+	assert.Error(t, err)
+	_, err = jsonmap.Unflatten(parse(t, `{"a.b":1,"a":2}`), ".")
+	assert.Error(t, err)
+}
+
+func TestFlattenEmptyKeys(t *testing.T) {
+	const nested = `{"":{"a":1,"":{"":2}},"b":[{"":3}]}`
+	flat := jsonmap.Flatten(parse(t, nested), ".")
+	assert.Equal(t, strings.Join(flat.Keys(), " "), ".a .. b.0.")
+
+	m, err := jsonmap.Unflatten(flat, ".")
+	assert.NoError(t, err)
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), nested)
+}
+
+func TestFlattenEmptySeparator(t *testing.T) {
+	// defaults to "."
+	flat := jsonmap.Flatten(parse(t, `{"a":{"b":1}}`), "")
+	assert.Equal(t, flat.Keys(), []string{"a.b"})
+	m, err := jsonmap.Unflatten(flat, "")
+	assert.NoError(t, err)
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"a":{"b":1}}`)
+}
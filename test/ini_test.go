@@ -0,0 +1,89 @@
+package test_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestUnmarshalINI(t *testing.T) {
+	data := "\uFEFF; global\r\n" +
+		"name = app\r\n" +
+		"debug\r\n" +
+		"\r\n" +
+		"[server]\r\n" +
+		"port: 8080\r\n" +
+		"host=  example.com  \r\n" +
+		"motd = first line\r\n" +
+		"  second line\r\n" +
+		"# comment\r\n" +
+		"[database]\r\n" +
+		`dsn = "user:pw@/db\t\"x\""` + "\r\n" +
+		`raw = '  \n  '` + "\r\n" +
+		"[server]\r\n" +
+		"tls = on\r\n"
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalINI([]byte(data), m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"name":"app","debug":null,`+
+		`"server":{"port":"8080","host":"example.com","motd":"first line\nsecond line","tls":"on"},`+
+		`"database":{"dsn":"user:pw@/db\t\"x\"","raw":"  \\n  "}}`)
+
+	for _, data := range []string{
+		"[server",
+		"[ ]",
+		"a=1\n[a]",
+		"= 1",
+		`a = "x`,
+		`a = "x"y"`,
+	} {
+		err := jsonmap.UnmarshalINI([]byte(data), jsonmap.New())
+		assert.Error(t, err)
+	}
+
+	err = jsonmap.UnmarshalINI([]byte("a=1\n\n[a]\n"), jsonmap.New())
+	var lineErr *jsonmap.LineError
+	assert.True(t, errors.As(err, &lineErr))
+	assert.Equal(t, err.Error(), `jsonmap: ini: section "a" conflicts with key at line 3`)
+}
+
+func TestMarshalINI(t *testing.T) {
+	m := parse(t, `{"z":{"b":"2","a":" padded "},"name":"app","flag":null,"n":1.5,"y":{"multi":"a\nb","q":"'x'"}}`)
+	data, err := jsonmap.MarshalINI(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `name = app
+flag
+n = 1.5
+
+[z]
+b = 2
+a = " padded "
+
+[y]
+multi = "a\nb"
+q = "'x'"
+`)
+
+	back := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalINI(data, back))
+	assert.Equal(t, back.Keys(), []string{"name", "flag", "n", "z", "y"})
+	z, _ := back.Get("z")
+	assert.Equal(t, z.(*jsonmap.Map).Keys(), []string{"b", "a"})
+	v, _ := z.(*jsonmap.Map).Get("a")
+	assert.Equal(t, v, " padded ")
+
+	for _, doc := range []string{
+		`{"a":[1]}`,
+		`{"s":{"t":{"u":1}}}`,
+		`{"a=b":1}`,
+		`{";a":1}`,
+		`{"s]":{}}`,
+	} {
+		_, err := jsonmap.MarshalINI(parse(t, doc))
+		assert.Error(t, err)
+	}
+}
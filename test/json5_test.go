@@ -0,0 +1,99 @@
+package test_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestUnmarshalJSONC(t *testing.T) {
+	const data = `// settings
+{
+	"z": 1, // line comment
+	/* block
+	   comment */ "a": [1, 2,],
+	"s": "not // a comment",
+}
+/* end */`
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalJSONC([]byte(data), m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"z":1,"a":[1,2],"s":"not // a comment"}`)
+
+	// JSON5 extensions are not allowed
+	assert.Error(t, jsonmap.UnmarshalJSONC([]byte(`{a: 1}`), jsonmap.New()))
+	assert.Error(t, jsonmap.UnmarshalJSONC([]byte(`{"a": 1} /* unterminated`), jsonmap.New()))
+	assert.Error(t, jsonmap.UnmarshalJSONC([]byte(`{"a": 1,,}`), jsonmap.New()))
+	// and comments are not allowed in strict JSON
+	assert.Error(t, jsonmap.New().UnmarshalJSON([]byte(`{"a": 1} // comment`)))
+}
+
+func TestUnmarshalJSON5(t *testing.T) {
+	const data = `{
+	// comments
+	unquoted: 'and you can quote me on that',
+	singleQuotes: 'I can use "double quotes" here',
+	lineBreaks: "Look, Mom! \
+No \\n's!",
+	hexadecimal: 0xdecaf,
+	leadingDecimalPoint: .8675309, andTrailing: 8675309.,
+	positiveSign: +1,
+	trailingComma: 'in objects', andIn: ['arrays',],
+	"backwardsCompatible": "with JSON",
+	escapes: '\x41\v\0\'',
+	$_ünicode: -0x10,
+}`
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalJSON5([]byte(data), m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"unquoted":"and you can quote me on that",`+
+		`"singleQuotes":"I can use \"double quotes\" here","lineBreaks":"Look, Mom! No \\n's!",`+
+		`"hexadecimal":912559,"leadingDecimalPoint":0.8675309,"andTrailing":8675309,"positiveSign":1,`+
+		`"trailingComma":"in objects","andIn":["arrays"],"backwardsCompatible":"with JSON",`+
+		`"escapes":"A\u000b\u0000'","$_ünicode":-16}`)
+
+	m = jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalJSON5([]byte(`{a: Infinity, b: -Infinity, c: NaN}`), m))
+	v, _ := m.Get("b")
+	assert.True(t, math.IsInf(v.(float64), -1))
+	v, _ = m.Get("c")
+	assert.True(t, math.IsNaN(v.(float64)))
+
+	for _, data := range []string{
+		`{a: 01}`,
+		`{a: 0x}`,
+		`{a: '\1'}`,
+		`{a: 'line
+break'}`,
+		`{1a: 1}`,
+		`{a: Inf}`,
+		`{a: 'x\`,
+	} {
+		assert.Error(t, jsonmap.UnmarshalJSON5([]byte(data), jsonmap.New()))
+	}
+}
+
+func TestMarshalJSON5(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalJSON5([]byte(`{z: NaN, "a b": [Infinity, {$x: "<y>"}], "1": -Infinity}`), m))
+	out, err := jsonmap.MarshalJSON5(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{z:NaN,"a b":[Infinity,{$x:"<y>"}],"1":-Infinity}`)
+
+	m2 := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalJSON5(out, m2))
+	assert.Equal(t, m2.Keys(), m.Keys())
+
+	var buf bytes.Buffer
+	e := jsonmap.NewEncoder(&buf)
+	e.SetJSON5(true)
+	e.SetIndent("", "  ")
+	assert.NoError(t, e.WriteMap(m))
+	assert.Equal(t, buf.String(), "{\n  z: NaN,\n  \"a b\": [\n    Infinity,\n    {\n      $x: \"\\u003cy\\u003e\"\n    }\n  ],\n  \"1\": -Infinity\n}\n")
+}
@@ -129,3 +129,29 @@ func testJSONMapOnce(t *testing.T, i int) {
 	assert.False(t, ok)
 	assert.Nil(t, v)
 }
+
+func TestInsertBeforeAfter(t *testing.T) {
+	m := jsonmap.New()
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.InsertBefore("x", 0, m.GetElement("a"))
+	m.InsertAfter("y", 3, m.GetElement("a"))
+	assert.Equal(t, m.String(), "map[x:0 a:1 y:3 b:2]")
+
+	// existing key is moved
+	m.InsertAfter("x", 4, m.GetElement("b"))
+	assert.Equal(t, m.String(), "map[a:1 y:3 b:2 x:4]")
+	assert.Equal(t, m.Last().Key(), "x")
+	assert.Equal(t, m.Last().Prev().Key(), "b")
+	m.InsertBefore("x", 5, m.First())
+	assert.Equal(t, m.String(), "map[x:5 a:1 y:3 b:2]")
+	assert.Nil(t, m.First().Prev())
+	assert.Equal(t, m.Last().Key(), "b")
+
+	// mark of another map only sets the value
+	other := jsonmap.New()
+	other.Set("o", 0)
+	m.InsertBefore("a", 6, other.First())
+	m.InsertAfter("z", 7, nil)
+	assert.Equal(t, m.String(), "map[x:5 a:6 y:3 b:2 z:7]")
+}
@@ -0,0 +1,102 @@
+package test_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+// example from RFC 9535, section 1.5
+const storeJSON = `{"store":{
+	"book":[
+		{"category":"reference","author":"Nigel Rees","title":"Sayings of the Century","price":8.95},
+		{"category":"fiction","author":"Evelyn Waugh","title":"Sword of Honour","price":12.99},
+		{"category":"fiction","author":"Herman Melville","title":"Moby Dick","isbn":"0-553-21311-3","price":8.99},
+		{"category":"fiction","author":"J. R. R. Tolkien","title":"The Lord of the Rings","isbn":"0-395-19395-8","price":22.99}
+	],
+	"bicycle":{"color":"red","price":399}
+}}`
+
+func queryPaths(t *testing.T, root any, expr string) string {
+	t.Helper()
+	nodes, err := jsonmap.Query(root, expr)
+	assert.NoError(t, err)
+	paths := make([]string, len(nodes))
+	for i, n := range nodes {
+		paths[i] = n.Path.Normalized()
+	}
+	return strings.Join(paths, " ")
+}
+
+func TestJSONPath(t *testing.T) {
+	m := parse(t, storeJSON)
+
+	for _, test := range []struct {
+		expr, paths string
+	}{
+		{`$.store.book[*].author`, `$['store']['book'][0]['author'] $['store']['book'][1]['author'] $['store']['book'][2]['author'] $['store']['book'][3]['author']`},
+		{`$..author`, `$['store']['book'][0]['author'] $['store']['book'][1]['author'] $['store']['book'][2]['author'] $['store']['book'][3]['author']`},
+		{`$.store.*`, `$['store']['book'] $['store']['bicycle']`},
+		{`$.store..price`, `$['store']['book'][0]['price'] $['store']['book'][1]['price'] $['store']['book'][2]['price'] $['store']['book'][3]['price'] $['store']['bicycle']['price']`},
+		{`$..book[2]`, `$['store']['book'][2]`},
+		{`$..book[-1]`, `$['store']['book'][3]`},
+		{`$..book[0,1]`, `$['store']['book'][0] $['store']['book'][1]`},
+		{`$..book[:2]`, `$['store']['book'][0] $['store']['book'][1]`},
+		{`$..book[::-2]`, `$['store']['book'][3] $['store']['book'][1]`},
+		{`$..book[?@.isbn]`, `$['store']['book'][2] $['store']['book'][3]`},
+		{`$..book[?@.price<10]`, `$['store']['book'][0] $['store']['book'][2]`},
+		{`$.store.book[?@.price < 10].title`, `$['store']['book'][0]['title'] $['store']['book'][2]['title']`},
+		{`$..book[?@.category == 'fiction' && !(@.price > 20)].title`, `$['store']['book'][1]['title'] $['store']['book'][2]['title']`},
+		{`$..book[?match(@.author, 'J.*') || search(@.title, "Dick")].price`, `$['store']['book'][2]['price'] $['store']['book'][3]['price']`},
+		{`$.store[?search(@.color, $.store.bicycle.color)]`, `$['store']['bicycle']`},
+		{`$..book[?match(@.author, '(')]`, ``},
+		{`$.store[?length(@) == 4]`, `$['store']['book']`},
+		{`$.store[?count(@.*) == 2]`, `$['store']['bicycle']`},
+		{`$.store.book[?value(@..price) > 20]`, `$['store']['book'][3]`},
+		{`$[?@.bicycle.color == $.store.bicycle.color]`, `$['store']`},
+		{`$["store"]['bicycle']`, `$['store']['bicycle']`},
+		{`$.nothing`, ``},
+	} {
+		assert.Equal(t, queryPaths(t, m, test.expr), test.paths)
+	}
+}
+
+func TestJSONPathValues(t *testing.T) {
+	m := parse(t, storeJSON)
+	p := jsonmap.MustParseJSONPath(`$..book[?@.price < 10].price`)
+	nodes := p.Query(m)
+	assert.Equal(t, len(nodes), 2)
+	assert.Equal(t, nodes[0].Value, 8.95)
+
+	// mutate selected values
+	for _, n := range nodes {
+		assert.True(t, n.Path.Set(m, 10.))
+	}
+	assert.Equal(t, len(p.Query(m)), 0)
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, expr := range []string{
+		``, `store`, `$.`, `$[`, `$[01]`, `$[-0]`, `$['a`, `$[?@.a == @.*]`, `$[?1]`,
+		`$[?length(@.*) == 1]`, `$[?foo(@)]`, `$ `, `$[?(@.a]`, `$[9007199254740992]`,
+	} {
+		_, err := jsonmap.ParseJSONPath(expr)
+		assert.Error(t, err)
+	}
+
+	// deep nesting is an error, not a stack overflow
+	_, err := jsonmap.ParseJSONPath(`$[?` + strings.Repeat("(", 1000) + "@.a" + strings.Repeat(")", 1000) + `]`)
+	assert.NoError(t, err)
+	for _, expr := range []string{
+		`$[?` + strings.Repeat("(", 1000000),
+		`$[?` + strings.Repeat("!(", 1000000),
+		`$[?` + strings.Repeat("@[?", 1000000),
+		`$[?` + strings.Repeat("length(", 1000000),
+	} {
+		_, err := jsonmap.ParseJSONPath(expr)
+		assert.Error(t, err)
+		assert.True(t, strings.Contains(err.Error(), "exceeded max depth 10000"))
+	}
+}
@@ -0,0 +1,40 @@
+package test_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestMerge3(t *testing.T) {
+	base := parse(t, `{"a":1,"b":{"x":1,"y":2},"c":3,"d":4}`)
+	ours := parse(t, `{"a":1,"b":{"x":1,"y":2,"o":1},"c":30,"e":5}`)
+	theirs := parse(t, `{"b":{"x":1,"t":1,"y":2},"a":1,"c":3,"d":4,"f":6}`)
+
+	merged, conflicts := jsonmap.Merge3(base, ours, theirs)
+	assert.Equal(t, len(conflicts), 0)
+	data, err := json.Marshal(merged)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"b":{"x":1,"t":1,"y":2,"o":1},"a":1,"c":30,"e":5,"f":6}`)
+}
+
+func TestMerge3Conflicts(t *testing.T) {
+	base := parse(t, `{"a":1,"b":{"x":1},"c":3}`)
+	ours := parse(t, `{"a":2,"b":{"x":2},"c":4}`)
+	theirs := parse(t, `{"a":3,"b":{"x":3}}`)
+
+	merged, conflicts := jsonmap.Merge3(base, ours, theirs)
+	data, err := json.Marshal(merged)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"a":2,"b":{"x":2},"c":4}`)
+
+	assert.Equal(t, len(conflicts), 3)
+	assert.Equal(t, conflicts[0].Path.String(), "/a")
+	assert.Equal(t, conflicts[0].Theirs, 3.)
+	assert.Equal(t, conflicts[1].Path.String(), "/b/x")
+	assert.Equal(t, conflicts[2].Path.String(), "/c")
+	assert.True(t, conflicts[2].HasOurs)
+	assert.False(t, conflicts[2].HasTheirs)
+}
@@ -0,0 +1,115 @@
+package test_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestMarshalMsgpack(t *testing.T) {
+	m := jsonmap.New()
+	m.Set("b", 1)
+	m.Set("a", []any{true, nil, -33, "x"})
+	data, err := jsonmap.MarshalMsgpack(m)
+	assert.NoError(t, err)
+	assert.Equal(t, data, []byte{
+		0x82,
+		0xa1, 'b', 0x01,
+		0xa1, 'a', 0x94, 0xc3, 0xc0, 0xd0, 0xdf, 0xa1, 'x',
+	})
+
+	back := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalMsgpack(data, back))
+	assert.Equal(t, back.Keys(), []string{"b", "a"})
+	v, _ := back.Get("a")
+	assert.Equal(t, v, []any{true, nil, int64(-33), "x"})
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	nested := jsonmap.New()
+	nested.Set("z", "last")
+	nested.Set("y", map[string]int{"k": 1}) // converted through JSON
+	m := jsonmap.New()
+	values := []any{
+		int64(0), int64(127), int64(128), int64(-32), int64(-129), int64(70000), int64(math.MinInt64), int64(math.MaxInt64),
+		uint64(math.MaxUint64), 1.5, float32(2.5), math.Inf(-1), "", strings.Repeat("s", 40), strings.Repeat("l", 300),
+		[]byte{1, 2, 3}, jsonmap.MsgpackExt{Type: 5, Data: []byte{1, 2, 3}}, jsonmap.MsgpackExt{Type: -2, Data: []byte{9}},
+		time.Unix(1700000000, 0).UTC(), time.Unix(1700000000, 123).UTC(), time.Unix(1<<35, 1).UTC(), time.Unix(-1, 0).UTC(),
+		[]any{nested, []any{}}, make([]any, 20),
+	}
+	for i, v := range values {
+		m.Set(strings.Repeat("k", i+1), v)
+	}
+	data, err := jsonmap.MarshalMsgpack(m)
+	assert.NoError(t, err)
+
+	back := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalMsgpack(data, back))
+	assert.Equal(t, back.Len(), len(values))
+	i := 0
+	for el := back.First(); el != nil; el = el.Next() {
+		assert.Equal(t, el.Key(), strings.Repeat("k", i+1))
+		if i == len(values)-2 {
+			a := el.Value().([]any)
+			n := a[0].(*jsonmap.Map)
+			assert.Equal(t, n.Keys(), []string{"z", "y"})
+			y, _ := n.Get("y")
+			out, err := json.Marshal(y)
+			assert.NoError(t, err)
+			assert.Equal(t, string(out), `{"k":1}`)
+			assert.Equal(t, a[1], []any{})
+		} else {
+			assert.Equal(t, el.Value(), values[i])
+		}
+		i++
+	}
+}
+
+func TestUnmarshalMsgpackKeys(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalMsgpack([]byte{0x83, 0x01, 0xa1, 'a', 0xc4, 0x01, 'b', 0x02, 0xff, 0x03}, m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"1":"a","b":2,"-1":3}`)
+}
+
+func TestUnmarshalMsgpackErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{0x91, 0x01},                        // not a map
+		{0x81, 0xa1, 'a'},                   // truncated
+		{0x81, 0xa1, 'a', 0xc1},             // invalid byte
+		{0x80, 0x00},                        // trailing data
+		{0x81, 0xc0, 0x01},                  // nil key
+		{0xdf, 0xff, 0xff, 0xff, 0xff},      // huge map
+		{0x81, 0x01, 0xd5, 0xff, 0, 0},      // timestamp of 2 bytes
+		{0x81, 0x01, 0xdb, 0, 0, 0, 9, 'a'}, // truncated string
+	} {
+		err := jsonmap.UnmarshalMsgpack(data, jsonmap.New())
+		assert.Error(t, err)
+	}
+
+	err := jsonmap.UnmarshalMsgpack([]byte{0x81, 0xa1, 'a', 0xc1}, jsonmap.New())
+	var formatErr *jsonmap.FormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.Equal(t, err.Error(), "jsonmap: msgpack: invalid type byte 0xc1 at offset 3")
+
+	deep := append([]byte{0x81, 0xa1, 'a'}, bytes.Repeat([]byte{0x91}, 5000000)...)
+	err = jsonmap.UnmarshalMsgpack(deep, jsonmap.New())
+	assert.True(t, errors.As(err, &formatErr))
+	assert.True(t, strings.Contains(err.Error(), "exceeded max depth"))
+
+	_, err = jsonmap.MarshalMsgpack(func() *jsonmap.Map {
+		m := jsonmap.New()
+		m.Set("f", func() {})
+		return m
+	}())
+	assert.Error(t, err)
+}
@@ -0,0 +1,106 @@
+package test_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func snapshotJSON(t *testing.T, p *jsonmap.Parser) string {
+	t.Helper()
+	data, err := json.Marshal(p.Snapshot())
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestParser(t *testing.T) {
+	p := jsonmap.NewParser()
+	assert.Equal(t, snapshotJSON(t, p), `{}`)
+
+	steps := []struct{ chunk, snapshot string }{
+		{`{"z": 1, "a`, `{"z":1}`},
+		{`": "hel`, `{"z":1,"a":"hel"}`},
+		{`lo \u00`, `{"z":1,"a":"hello "}`},
+		{`e9\uD83D`, `{"z":1,"a":"hello é"}`},
+		{`\uDE00", "n": {"list": [1, 2`, `{"z":1,"a":"hello é😀","n":{"list":[1,2]}}`},
+		{`.5e`, `{"z":1,"a":"hello é😀","n":{"list":[1,2.5]}}`},
+		{`1, tr`, `{"z":1,"a":"hello é😀","n":{"list":[1,25,null]}}`},
+		{`ue], "m":`, `{"z":1,"a":"hello é😀","n":{"list":[1,25,true],"m":null}}`},
+		{" \"\xe6", `{"z":1,"a":"hello é😀","n":{"list":[1,25,true],"m":""}}`},
+		{"\x97\xa5\"}}\n", `{"z":1,"a":"hello é😀","n":{"list":[1,25,true],"m":"日"}}`},
+	}
+	for _, step := range steps {
+		assert.NoError(t, p.Feed([]byte(step.chunk)))
+		assert.Equal(t, snapshotJSON(t, p), step.snapshot)
+	}
+	assert.NoError(t, p.Close())
+
+	v, ok := p.Snapshot().Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, v, "hello é😀")
+}
+
+func TestParserPartial(t *testing.T) {
+	p := jsonmap.NewParser()
+	assert.NoError(t, p.Feed([]byte(`{"a": [{"b": "x`)))
+	a, ok := p.Snapshot().Get("a")
+	assert.True(t, ok)
+	b, ok := a.([]any)[0].(*jsonmap.Map).Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, b, jsonmap.Partial{Value: "x"})
+
+	// snapshot is a copy
+	p.Snapshot().Set("a", 1)
+	assert.Equal(t, snapshotJSON(t, p), `{"a":[{"b":"x"}]}`)
+
+	assert.Error(t, p.Close())
+}
+
+func TestParserByteByByte(t *testing.T) {
+	const data = `{"b":[true,false,null,{}],"a":{"y":"\"\\\/\b\f\n\r\tA","x":-1.5E+3},"c":[]}`
+	p := jsonmap.NewParser()
+	for i := 0; i < len(data); i++ {
+		assert.NoError(t, p.Feed([]byte{data[i]}))
+		_ = p.Snapshot()
+	}
+	assert.NoError(t, p.Close())
+
+	expected := jsonmap.New()
+	assert.NoError(t, json.Unmarshal([]byte(data), expected))
+	want, err := json.Marshal(expected)
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotJSON(t, p), string(want))
+}
+
+func TestParserErrors(t *testing.T) {
+	for _, chunks := range [][]string{
+		{`[1]`},
+		{`{"a"`, `1}`},
+		{`{"a":1`, `]`},
+		{`{"a":tr`, `ue,`, `}`},
+		{`{"a":nul`, `x}`},
+		{`{"a":1.`, `}`},
+		{`{"a":"\`, `x"}`},
+		{`{}`, ` {}`},
+	} {
+		p := jsonmap.NewParser()
+		var err error
+		for _, chunk := range chunks {
+			if err = p.Feed([]byte(chunk)); err != nil {
+				break
+			}
+		}
+		assert.Error(t, err)
+		assert.Equal(t, p.Close(), err)
+	}
+
+	p := jsonmap.NewParser()
+	assert.NoError(t, p.Feed([]byte(`{"a":`)))
+	err := p.Close()
+	var scanErr *jsonmap.ScanError
+	assert.True(t, errors.As(err, &scanErr))
+	assert.Equal(t, scanErr.Offset, int64(5))
+}
@@ -0,0 +1,194 @@
+package test_test
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+const infoPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CFBundleName</key>
+	<string>Demo &amp; Co</string>
+	<key>CFBundleVersion</key>
+	<integer>42</integer>
+	<key>Scale</key>
+	<real>1.5</real>
+	<key>LSRequiresIPhoneOS</key>
+	<true/>
+	<key>Released</key>
+	<date>2024-02-29T12:30:00Z</date>
+	<key>Icon</key>
+	<data>
+	AQID
+	BA==
+	</data>
+	<key>Architectures</key>
+	<array>
+		<string>arm64</string>
+		<string>x86_64</string>
+	</array>
+	<key>Empty</key>
+	<dict/>
+	<key>Ref</key>
+	<dict>
+		<key>CF$UID</key>
+		<integer>7</integer>
+	</dict>
+</dict>
+</plist>
+`
+
+func TestUnmarshalPlistXML(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalPlist([]byte(infoPlist), m))
+	assert.Equal(t, m.Keys(), []string{"CFBundleName", "CFBundleVersion", "Scale", "LSRequiresIPhoneOS", "Released", "Icon", "Architectures", "Empty", "Ref"})
+	for key, want := range map[string]any{
+		"CFBundleName":       "Demo & Co",
+		"CFBundleVersion":    int64(42),
+		"Scale":              1.5,
+		"LSRequiresIPhoneOS": true,
+		"Released":           time.Date(2024, 2, 29, 12, 30, 0, 0, time.UTC),
+		"Icon":               []byte{1, 2, 3, 4},
+		"Architectures":      []any{"arm64", "x86_64"},
+		"Ref":                jsonmap.PlistUID(7),
+	} {
+		v, _ := m.Get(key)
+		assert.Equal(t, v, want)
+	}
+
+	data, err := jsonmap.MarshalPlist(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), strings.Replace(infoPlist, "\n\tAQID\n\tBA==\n\t", "AQIDBA==", 1))
+}
+
+func TestBinaryPlist(t *testing.T) {
+	m := jsonmap.New()
+	m.Set("a", 1)
+	data, err := jsonmap.MarshalBinaryPlist(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "bplist00"+
+		"\xd1\x01\x02"+"\x51a"+"\x10\x01"+
+		"\x08\x0b\x0d"+
+		"\x00\x00\x00\x00\x00\x00\x01\x01"+
+		"\x00\x00\x00\x00\x00\x00\x00\x03"+
+		"\x00\x00\x00\x00\x00\x00\x00\x00"+
+		"\x00\x00\x00\x00\x00\x00\x00\x0f")
+
+	nested := jsonmap.New()
+	nested.Set("z", "same")
+	nested.Set("y", "same")
+	m = jsonmap.New()
+	values := []any{
+		"ascii", "ünïcødé 😀", strings.Repeat("long", 10), int64(-1), int64(255), int64(70000), int64(math.MaxInt64),
+		uint64(math.MaxUint64), 2.5, math.Inf(1), true, false,
+		time.Date(2024, 2, 29, 12, 30, 0, 123e6, time.UTC), time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC),
+		[]byte{}, []byte(strings.Repeat("x", 20)), jsonmap.PlistUID(300),
+		nested, []any{"same", int64(1), []any{}}, jsonmap.New(),
+	}
+	for i, v := range values {
+		m.Set(strings.Repeat("k", i+1), v)
+	}
+	data, err = jsonmap.MarshalBinaryPlist(m)
+	assert.NoError(t, err)
+
+	back := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalPlist(data, back))
+	assert.Equal(t, back.Keys(), m.Keys())
+	i := 0
+	for el := back.First(); el != nil; el = el.Next() {
+		if n, ok := el.Value().(*jsonmap.Map); ok {
+			assert.Equal(t, n.Keys(), values[i].(*jsonmap.Map).Keys())
+		} else {
+			assert.Equal(t, el.Value(), values[i])
+		}
+		i++
+	}
+
+	// XML and binary give the same map
+	xmlData, err := jsonmap.MarshalPlist(back)
+	assert.NoError(t, err)
+	fromXML := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalPlist(xmlData, fromXML))
+	assert.Equal(t, fromXML.Keys(), m.Keys())
+}
+
+func TestMarshalPlistTypes(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, json.Unmarshal([]byte(`{"n":1,"f":0.5,"s":"<x>"}`), m))
+	m.Set("f32", float32(0.1))
+	data, err := jsonmap.MarshalPlist(m)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(data), "\t<key>n</key>\n\t<real>1</real>\n"))
+	assert.True(t, strings.Contains(string(data), "\t<string>&lt;x&gt;</string>\n"))
+	assert.True(t, strings.Contains(string(data), "\t<real>0.1</real>\n"))
+
+	m = jsonmap.New()
+	m.Set("null", nil)
+	_, err = jsonmap.MarshalPlist(m)
+	assert.Error(t, err)
+	_, err = jsonmap.MarshalBinaryPlist(m)
+	assert.Error(t, err)
+
+	// characters not allowed in XML can't be written, binary plist keeps them
+	for _, key := range []string{"a\x00", "\xff"} {
+		m = jsonmap.New()
+		m.Set(key, "x")
+		_, err = jsonmap.MarshalPlist(m)
+		assert.Error(t, err)
+		m = jsonmap.New()
+		m.Set("s", key)
+		_, err = jsonmap.MarshalPlist(m)
+		assert.Error(t, err)
+	}
+	m = jsonmap.New()
+	m.Set("s", "\x01")
+	_, err = jsonmap.MarshalBinaryPlist(m)
+	assert.NoError(t, err)
+}
+
+func TestUnmarshalPlistErrors(t *testing.T) {
+	trailer := func(count, table byte) string {
+		return "\x00\x00\x00\x00\x00\x00\x01\x01" +
+			"\x00\x00\x00\x00\x00\x00\x00" + string(count) +
+			"\x00\x00\x00\x00\x00\x00\x00\x00" +
+			"\x00\x00\x00\x00\x00\x00\x00" + string(table)
+	}
+	for _, data := range []string{
+		"",
+		"bplist00",
+		"bplist00" + "\xa1\x00" + "\x08" + trailer(1, 10),                      // array containing itself
+		"bplist00" + "\xa1\x05" + "\x08" + trailer(1, 10),                      // invalid reference
+		"bplist00" + "\xa0" + "\x08" + trailer(1, 9),                           // root is not a dict
+		"bplist00" + "\xd1\x01\x01" + "\x10\x01" + "\x08\x0b" + trailer(2, 13), // non-string key
+		"bplist00" + "\x5f\x10\xff" + "\x08" + trailer(1, 11),                  // string overflows objects
+		`<plist><array/></plist>`,
+		`<plist><dict><string>x</string></dict></plist>`,
+		`<plist><dict><key>a</key></dict></plist>`,
+		`<plist><dict><key>a</key><integer>x</integer></dict></plist>`,
+		`<plist><dict><key>a</key><date>yesterday</date></dict></plist>`,
+		`<plist><dict><key>a</key><data>!!</data></dict></plist>`,
+		`<plist><dict><key>a</key><null/></dict></plist>`,
+		`<plist><dict/><dict/></plist>`,
+		`<plist><dict>`,
+	} {
+		err := jsonmap.UnmarshalPlist([]byte(data), jsonmap.New())
+		assert.Error(t, err)
+	}
+
+	// deep nesting is an error, not a stack overflow
+	deep := func(n int) string {
+		return "<plist><dict><key>a</key>" + strings.Repeat("<array>", n) + strings.Repeat("</array>", n) + "</dict></plist>"
+	}
+	assert.NoError(t, jsonmap.UnmarshalPlist([]byte(deep(9999)), jsonmap.New()))
+	err := jsonmap.UnmarshalPlist([]byte(deep(3000000)), jsonmap.New())
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "exceeded max depth 10000"))
+}
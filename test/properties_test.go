@@ -0,0 +1,62 @@
+package test_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestUnmarshalProperties(t *testing.T) {
+	data := `# comment
+! another comment
+z.name = Demo \
+         App
+a.list.1 : second
+a.list.0=first
+key\ with\ spaces value with spaces
+tab\tkey=\u00e9t\u00e9 \uD83D\uDE00
+empty
+path=C:\\dir\\file
+trailing=even\\
+next=line
+`
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalProperties([]byte(data), m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"z.name":"Demo App","a.list.1":"second","a.list.0":"first",`+
+		`"key with spaces":"value with spaces","tab\tkey":"été 😀","empty":"","path":"C:\\dir\\file",`+
+		`"trailing":"even\\","next":"line"}`)
+
+	m = jsonmap.New()
+	assert.NoError(t, jsonmap.KeyValueOptions{Unflatten: true}.UnmarshalProperties([]byte(data), m))
+	out, err = json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"z":{"name":"Demo App"},"a":{"list":["first","second"]},`+
+		`"key with spaces":"value with spaces","tab\tkey":"été 😀","empty":"","path":"C:\\dir\\file",`+
+		`"trailing":"even\\","next":"line"}`)
+
+	err = jsonmap.UnmarshalProperties([]byte("a=\\u12"), jsonmap.New())
+	assert.Error(t, err)
+}
+
+func TestMarshalProperties(t *testing.T) {
+	m := parse(t, `{"server":{"port":8080,"hosts":["a","b"]}," key=":"  v#!:=\\\n","é":"ü"}`)
+	data, err := jsonmap.MarshalProperties(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `server.port=8080
+server.hosts.0=a
+server.hosts.1=b
+\ key\==\  v\#\!\:\=\\\n
+é=ü
+`)
+
+	opts := jsonmap.KeyValueOptions{Unflatten: true}
+	back := jsonmap.New()
+	assert.NoError(t, opts.UnmarshalProperties(data, back))
+	out, err := json.Marshal(back)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"server":{"port":"8080","hosts":["a","b"]}," key=":"  v#!:=\\\n","é":"ü"}`)
+}
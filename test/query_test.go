@@ -0,0 +1,78 @@
+package test_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestEncodeQuery(t *testing.T) {
+	m := parse(t, `{"z":"last first","a":{"y":[1,{"k":true}],"x":null},"empty":{},"key[0]":"&="}`)
+	assert.Equal(t, jsonmap.EncodeQuery(m), "z=last+first&a[y][0]=1&a[y][1][k]=true&a[x]=&key%5B0%5D=%26%3D")
+
+	m = jsonmap.New()
+	m.Set("struct", struct{ B, A int }{1, 2}) // converted through JSON
+	assert.Equal(t, jsonmap.EncodeQuery(m), "struct[B]=1&struct[A]=2")
+
+	// empty keys are kept
+	m = parse(t, `{"":{"a":1},"b":{"":{"c":2}}}`)
+	assert.Equal(t, jsonmap.EncodeQuery(m), "[a]=1&b[][c]=2")
+}
+
+func TestParseQuery(t *testing.T) {
+	for _, tc := range []struct {
+		query, want string
+	}{
+		{"z=1&a=2&m=3", `{"z":"1","a":"2","m":"3"}`},
+		{"b[z]=1&b[a]=2&a=3&b[m]=4", `{"b":{"z":"1","a":"2","m":"4"},"a":"3"}`},
+		{"a[0]=x&a[1]=y&a[0]=z", `{"a":[["x","z"],"y"]}`},
+		{"a[]=x&a[]=y&a=z", `{"a":["x","y","z"]}`},
+		{"a=x&a=y", `{"a":["x","y"]}`},
+		{"a[][n]=1&a[][v]=x&a[][n]=2&a[][v]=y", `{"a":[{"n":"1","v":"x"},{"n":"2","v":"y"}]}`},
+		{"a[0][b][0]=x&a[0][c]=y&a[0][b][1]=z", `{"a":[{"b":["x","z"],"c":"y"}]}`},
+		{"m[x]=1&m[0]=2", `{"m":{"x":"1","0":"2"}}`},
+		{"a%5Bb%5D=1&c[d=2&e]=3&[f]=4", `{"a[b]":"1","c[d":"2","e]":"3","[f]":"4"}`},
+		{"k+1=v+w%21&flag&&x=", `{"k 1":"v w!","flag":"","x":""}`},
+		{"", `{}`},
+	} {
+		m, err := jsonmap.ParseQuery(tc.query)
+		assert.NoError(t, err)
+		out, err := json.Marshal(m)
+		assert.NoError(t, err)
+		assert.Equal(t, string(out), tc.want)
+	}
+
+	for _, query := range []string{
+		"a=1&a[b]=2",
+		"a[b]=1&a=2",
+		"a[0]=1&a[x]=2",
+		"a[1]=x",
+		"a=%zz",
+		"a[%zz]=1",
+		"=",
+		"=x",
+		"a=1&=b",
+	} {
+		_, err := jsonmap.ParseQuery(query)
+		assert.Error(t, err)
+	}
+
+	// deep keys don't overflow the stack
+	_, err := jsonmap.ParseQuery("a" + strings.Repeat("[b]", 10000) + "=1")
+	assert.NoError(t, err)
+	_, err = jsonmap.ParseQuery("a" + strings.Repeat("[b]", 1000000) + "=1")
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "more than 10000 nested parts"))
+}
+
+func TestQueryRoundTrip(t *testing.T) {
+	m := parse(t, `{"sig":"abc","b":{"z":"1","a":["x","y"],"n":{"q":"a b"}},"a":"&"}`)
+	back, err := jsonmap.ParseQuery(jsonmap.EncodeQuery(m))
+	assert.NoError(t, err)
+	want, _ := json.Marshal(m)
+	got, _ := json.Marshal(back)
+	assert.Equal(t, string(got), string(want))
+}
@@ -0,0 +1,63 @@
+package test_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestRawMap(t *testing.T) {
+	const data = `{"b": 1.50, "a": {"y": 1, "x": [1, 2]},"s":"é", "n" : null}`
+	r := jsonmap.NewRawMap()
+	assert.NoError(t, r.UnmarshalJSON([]byte(data)))
+	assert.Equal(t, r.Len(), 4)
+	assert.Equal(t, r.Keys()[1], "a")
+
+	out, err := r.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"b":1.50,"a":{"y": 1, "x": [1, 2]},"s":"é","n":null}`)
+
+	raw, ok := r.GetRaw("s")
+	assert.True(t, ok)
+	assert.Equal(t, string(raw), `"é"`)
+
+	// scalars keep raw bytes after Get
+	v, ok := r.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, v, 1.5)
+
+	// nested maps are decoded on Get, and may be modified
+	v, ok = r.Get("a")
+	assert.True(t, ok)
+	v.(*jsonmap.Map).Set("z", true)
+	r.Set("s", "new")
+	r.Delete("n")
+	r.Set("added", []any{1})
+
+	out, err = r.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"b":1.50,"a":{"y":1,"x":[1,2],"z":true},"s":"new","added":[1]}`)
+
+	m, err := r.Map()
+	assert.NoError(t, err)
+	out, err = json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"b":1.5,"a":{"y":1,"x":[1,2],"z":true},"s":"new","added":[1]}`)
+}
+
+func TestRawMapInStruct(t *testing.T) {
+	var s struct {
+		Body *jsonmap.RawMap `json:"body"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"body":{"z":1,"a":[true]}}`), &s))
+	assert.Equal(t, s.Body.Keys()[0], "z")
+	out, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), `{"body":{"z":1,"a":[true]}}`)
+
+	r := jsonmap.NewRawMap()
+	assert.Error(t, r.UnmarshalJSON([]byte(`[1]`)))
+	assert.Error(t, r.UnmarshalJSON([]byte(`{"a":1} x`)))
+}
@@ -0,0 +1,83 @@
+package test_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestRepair(t *testing.T) {
+	for _, tc := range []struct{ input, output string }{
+		// valid input is unchanged
+		{`{"b": [1, -2.5e+3, "x\n\u00e9"], "a": {}}`, `{"b": [1, -2.5e+3, "x\n\u00e9"], "a": {}}`},
+		{`{"a": 1, "b": [1, 2,],}`, `{"a": 1, "b": [1, 2]}`},
+		{`{"a": 1 "b": 2}`, `{"a": 1 ,"b": 2}`},
+		{`{'a': 'it\'s "x"'}`, `{"a": "it's \"x\""}`},
+		{`{“a”: ‘b’}`, `{"a": "b"}`},
+		{`{a: 1, b-c: x y}`, `{"a": 1, "b-c": "x" ,"y":null}`},
+		{`{"a": True, "b": None, "c": False, "d": NaN, "e": undefined}`, `{"a": true, "b": null, "c": false, "d": null, "e": null}`},
+		{`{"a": +1, "b": .5, "c": 01, "d": 1., "e": 1.2.3}`, `{"a": 1, "b": 0.5, "c": 1, "d": 1, "e": "1.2.3"}`},
+		{"{\"a\": \"line\nbreak\tand \\x\"}", `{"a": "line\nbreak\tand \\x"}`},
+		{`{"a": [1, 2}`, `{"a": [1, 2]}`},
+		{`{"a": 1]`, `{"a": 1}`},
+		{`{"a": 1}}`, `{"a": 1}`},
+		{`{"a": , "b"}`, `{"a": null, "b":null}`},
+		{`{"a" 1, "b" = 2, ,"c": :3}`, `{"a" :1, "b" : 2, "c": 3}`},
+		{"```json\n{\"a\": 1}\n```", "{\"a\": 1}\n"},
+		{"{\"a\": 1, // comment\n/* more */ \"b\": 2 # end\n}", "{\"a\": 1, \n \"b\": 2 \n}"},
+
+		// truncated
+		{`{"a": [1, {"b": "te`, `{"a": [1, {"b": "te"}]}`},
+		{`{"a": "x\`, `{"a": "x"}`},
+		{`{"a": "x\u00`, `{"a": "x"}`},
+		{`{"a": tr`, `{"a": true}`},
+		{`{"a": 1.5e`, `{"a": 1.5}`},
+		{`{"a": 1,`, `{"a": 1}`},
+		{`{"a"`, `{"a":null}`},
+		{`{"a":`, `{"a":null}`},
+		{``, `null`},
+	} {
+		out, fixes := jsonmap.Repair([]byte(tc.input))
+		assert.Equal(t, string(out), tc.output)
+		assert.True(t, json.Valid(out))
+		assert.Equal(t, len(fixes) == 0, tc.input == tc.output)
+	}
+}
+
+func TestRepairFixes(t *testing.T) {
+	_, fixes := jsonmap.Repair([]byte(`{'a': 1,}`))
+	var lines []string
+	for _, fix := range fixes {
+		lines = append(lines, fix.String())
+	}
+	assert.Equal(t, strings.Join(lines, "\n"), "offset 1: replaced single quotes\noffset 7: removed trailing comma")
+}
+
+func TestUnmarshalLenient(t *testing.T) {
+	m := jsonmap.New()
+	fixes, err := jsonmap.UnmarshalLenient([]byte(`{z: 1, y: [True, 'x',], x: {"w": nul`), m)
+	assert.NoError(t, err)
+	assert.Equal(t, len(fixes), 9)
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"z":1,"y":[true,"x"],"x":{"w":null}}`)
+
+	_, err = jsonmap.UnmarshalLenient([]byte(`[1,`), m)
+	assert.Error(t, err)
+}
+
+func TestRepairMaxDepth(t *testing.T) {
+	data := `{"a":` + strings.Repeat("[", 9999) + strings.Repeat("]", 9999) + "}"
+	out, fixes := jsonmap.Repair([]byte(data))
+	assert.Equal(t, string(out), data)
+	assert.Equal(t, len(fixes), 0)
+
+	out, _ = jsonmap.Repair([]byte(strings.Repeat("[", 3000000)))
+	assert.True(t, json.Valid(out))
+	_, err := jsonmap.UnmarshalLenient([]byte("{a:"+strings.Repeat("[", 3000000)), jsonmap.New())
+	assert.Error(t, err)
+	assert.Equal(t, err.Error(), "jsonmap: repair: offset 10002: exceeded max depth 10000")
+}
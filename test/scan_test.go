@@ -0,0 +1,126 @@
+package test_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func scanEvents(t *testing.T, data string, fn func(e *jsonmap.Event) error) string {
+	t.Helper()
+	var out []string
+	err := jsonmap.Scan([]byte(data), func(e *jsonmap.Event) error {
+		s := fmt.Sprintf("%s@%d:%s", e.Kind, e.Offset, e.Path)
+		if e.Kind == jsonmap.EventValue {
+			s += fmt.Sprintf("=%v", e.Value)
+		}
+		out = append(out, s)
+		if fn != nil {
+			return fn(e)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	return strings.Join(out, " ")
+}
+
+func TestScan(t *testing.T) {
+	const data = `{"a": [1, "x"], "b": {"c": null}, "d": true}`
+	assert.Equal(t, scanEvents(t, data, nil), ""+
+		"StartObject@0: Key@1:/a StartArray@6:/a Value@7:/a/0=1 Value@10:/a/1=x EndArray@13:/a "+
+		"Key@16:/b StartObject@21:/b Key@22:/b/c Value@27:/b/c=<nil> EndObject@31:/b "+
+		"Key@34:/d Value@39:/d=true EndObject@43:")
+
+	skipped := scanEvents(t, data, func(e *jsonmap.Event) error {
+		if e.Kind == jsonmap.EventKey && e.Key == "a" || e.Kind == jsonmap.EventStartObject && len(e.Path) > 0 {
+			return jsonmap.SkipValue
+		}
+		return nil
+	})
+	assert.Equal(t, skipped, "StartObject@0: Key@1:/a Key@16:/b StartObject@21:/b Key@34:/d Value@39:/d=true EndObject@43:")
+
+	stopped := scanEvents(t, data, func(e *jsonmap.Event) error {
+		if e.Kind == jsonmap.EventKey && e.Key == "b" {
+			return jsonmap.StopScan
+		}
+		return nil
+	})
+	assert.Equal(t, stopped, "StartObject@0: Key@1:/a StartArray@6:/a Value@7:/a/0=1 Value@10:/a/1=x EndArray@13:/a Key@16:/b")
+}
+
+func TestScanReader(t *testing.T) {
+	const data = `{"ключ":"значение ☺ 😀 \"\\\/\b\f\n\r\t","n":[-1.5e3,0,{"x":[]}]}`
+	var want []string
+	err := jsonmap.Scan([]byte(data), func(e *jsonmap.Event) error {
+		want = append(want, fmt.Sprint(e.Kind, e.Offset, e.Path, e.Key, e.Value))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	var got []string
+	err = jsonmap.ScanReader(iotest.OneByteReader(strings.NewReader(data)), func(e *jsonmap.Event) error {
+		got = append(got, fmt.Sprint(e.Kind, e.Offset, e.Path, e.Key, e.Value))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join(got, "|"), strings.Join(want, "|"))
+}
+
+func TestScanStrings(t *testing.T) {
+	// same decoding as encoding/json, including invalid UTF-8 and surrogates
+	for _, s := range []string{
+		`"plain"`, `"é☺"`, `"😀"`, `"\ud83d"`, `"\ud83dx"`, `"\ude00\ud83d"`,
+		`"\ud83dA"`, "\"\xff\xfe\"", "\"a\xe2\x82\"", `"<>&"`,
+	} {
+		var want string
+		assert.NoError(t, json.Unmarshal([]byte(s), &want))
+		var got any
+		assert.NoError(t, jsonmap.Scan([]byte(s), func(e *jsonmap.Event) error {
+			got = e.Value
+			return nil
+		}))
+		assert.Equal(t, got, want)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	for _, data := range []string{
+		``, `{`, `{"a"}`, `{"a":}`, `{"a":1,}`, `[1,]`, `[1 2]`, `01`, `1.`, `-`, `1e`, `tru`, `nul`,
+		`"abc`, `"\x"`, `"\u12"`, "\"\x01\"", `{} {}`, `{"a":[}`, `{1:2}`,
+	} {
+		err := jsonmap.Scan([]byte(data), func(e *jsonmap.Event) error { return nil })
+		assert.Error(t, err)
+	}
+
+	// skipped values check nesting
+	err := jsonmap.Scan([]byte(`{"a":{"b":[}]}`), func(e *jsonmap.Event) error { return jsonmap.SkipValue })
+	assert.Error(t, err)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, data := range []string{`[1]`, `1`, `"a"`, `{"a":1`, `{"a":[1,{"b":}]}`} {
+		m := jsonmap.New()
+		assert.Error(t, m.UnmarshalJSON([]byte(data)))
+	}
+}
+
+func TestScanMaxDepth(t *testing.T) {
+	deep := `{"a":` + strings.Repeat("[", 5000000)
+	err := jsonmap.New().UnmarshalJSON([]byte(deep))
+	var scanErr *jsonmap.ScanError
+	assert.True(t, errors.As(err, &scanErr))
+	assert.Equal(t, scanErr.Offset, int64(5+9999))
+
+	err = jsonmap.ScanReader(strings.NewReader(deep), func(e *jsonmap.Event) error { return nil })
+	assert.True(t, errors.As(err, &scanErr))
+
+	// 10000 levels are fine
+	ok := strings.Repeat("[", 9999) + strings.Repeat("]", 9999)
+	assert.NoError(t, jsonmap.New().UnmarshalJSON([]byte(`{"a":`+ok+`}`)))
+}
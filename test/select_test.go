@@ -0,0 +1,35 @@
+package test_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestUnmarshalSelect(t *testing.T) {
+	const data = `{"z":1,"meta":{"id":7,"skip":[1,2]},"items":[{"name":"a","x":1},{"x":2},{"name":"c","sub":{"k":[1]}}],"a":{"b":{}}}`
+
+	for _, test := range []struct {
+		paths []string
+		want  string
+	}{
+		{[]string{"/meta/id", "/z"}, `{"z":1,"meta":{"id":7}}`},
+		{[]string{"/items/*/name"}, `{"items":[{"name":"a"},{"name":"c"}]}`},
+		{[]string{"/items/2"}, `{"items":[{"name":"c","sub":{"k":[1]}}]}`},
+		{[]string{"/*/id", "/a/b"}, `{"meta":{"id":7},"a":{"b":{}}}`},
+		{[]string{"/missing", "/meta/skip/5"}, `{}`},
+		{[]string{""}, data},
+	} {
+		m := jsonmap.New()
+		assert.NoError(t, jsonmap.UnmarshalSelect([]byte(data), m, test.paths...))
+		out, err := json.Marshal(m)
+		assert.NoError(t, err)
+		assert.Equal(t, string(out), test.want)
+	}
+
+	assert.Error(t, jsonmap.UnmarshalSelect([]byte(`[1]`), jsonmap.New(), "/a"))
+	assert.Error(t, jsonmap.UnmarshalSelect([]byte(`{"a":1`), jsonmap.New(), "/a"))
+	assert.Error(t, jsonmap.UnmarshalSelect([]byte(`{}`), jsonmap.New(), "a"))
+}
@@ -0,0 +1,56 @@
+package test_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func streamAll(t *testing.T, input, pointer string) (items []string, rest string, err error) {
+	t.Helper()
+	s := jsonmap.NewArrayStream(strings.NewReader(input), pointer)
+	for s.Next() {
+		assert.Equal(t, s.Index(), len(items))
+		data, err := json.Marshal(s.Value())
+		assert.NoError(t, err)
+		items = append(items, string(data))
+	}
+	if s.Map() != nil {
+		data, err := json.Marshal(s.Map())
+		assert.NoError(t, err)
+		rest = string(data)
+	}
+	return items, rest, s.Err()
+}
+
+func TestArrayStream(t *testing.T) {
+	items, rest, err := streamAll(t, `{"meta":{"b":1,"a":2},"items":[{"y":1,"x":2},3,[4]],"after":true}`, "/items")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join(items, " "), `{"y":1,"x":2} 3 [4]`)
+	assert.Equal(t, rest, `{"meta":{"b":1,"a":2},"items":[],"after":true}`)
+
+	items, rest, err = streamAll(t, `{"a":[0,{"z":1,"b/c":[1,2],"y":2},5],"b":1}`, "/a/1/b~1c")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join(items, " "), `1 2`)
+	assert.Equal(t, rest, `{"a":[0,{"z":1,"b/c":[],"y":2},5],"b":1}`)
+
+	items, rest, err = streamAll(t, `[{"b":1,"a":2},{}]`, "")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join(items, " "), `{"b":1,"a":2} {}`)
+	assert.Equal(t, rest, ``)
+}
+
+func TestArrayStreamErrors(t *testing.T) {
+	_, _, err := streamAll(t, `{"a":1}`, "/items")
+	assert.True(t, errors.Is(err, jsonmap.ErrPathNotFound))
+	_, _, err = streamAll(t, `{"items":{}}`, "/items")
+	assert.True(t, errors.Is(err, jsonmap.ErrPathNotFound))
+	items, _, err := streamAll(t, `{"items":[1,2`, "/items")
+	assert.Equal(t, len(items), 2)
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
@@ -0,0 +1,248 @@
+package test_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func tomlJSON(t *testing.T, data string) string {
+	t.Helper()
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalTOML([]byte(data), m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestUnmarshalTOML(t *testing.T) {
+	assert.Equal(t, tomlJSON(t, `# config
+title = "TOML Example"
+name.last = 'Preston-Werner'
+name.first = "Tom"
+
+[database]
+enabled = true
+ports = [ 8000, 8001, 8002, ]
+data = [ ["delta", "phi"], [3.14] ]
+temp_targets = { cpu = 79.5, case = 72.0 }
+
+[servers]
+
+[servers.beta]
+ip = "10.0.0.2"
+role = "backend"
+
+[servers.alpha]
+ip = "10.0.0.1"
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]  # empty table within the array
+
+[[products]]
+name = "Nail"
+color.value = "gray"
+
+[products.details]
+size = 1
+`), `{"title":"TOML Example","name":{"last":"Preston-Werner","first":"Tom"},`+
+		`"database":{"enabled":true,"ports":[8000,8001,8002],"data":[["delta","phi"],[3.14]],"temp_targets":{"cpu":79.5,"case":72}},`+
+		`"servers":{"beta":{"ip":"10.0.0.2","role":"backend"},"alpha":{"ip":"10.0.0.1"}},`+
+		`"products":[{"name":"Hammer","sku":738594937},{},{"name":"Nail","color":{"value":"gray"},"details":{"size":1}}]}`)
+
+	assert.Equal(t, tomlJSON(t, ""), `{}`)
+	assert.Equal(t, tomlJSON(t, "[a.b.c]\n[a]\nx = 1\n[a.b.d]\n"), `{"a":{"b":{"c":{},"d":{}},"x":1}}`)
+	assert.Equal(t, tomlJSON(t, "[fruit]\napple.color = 'red'\n[fruit.apple.texture]\nsmooth = true\n"),
+		`{"fruit":{"apple":{"color":"red","texture":{"smooth":true}}}}`)
+}
+
+func TestUnmarshalTOMLValues(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalTOML([]byte(`
+int = +1_000
+hex = 0xDEAD_beef
+oct = 0o755
+bin = 0b1101
+float = -6.626e-34
+inf = -inf
+nan = nan
+str = "tab\t quote\" \u00e9 \U0001F600"
+lit = 'C:\Users\'
+multi = """
+Roses are red \
+   Violets are blue
+"quoted" """"
+multilit = '''
+first
+second'''''
+odt = 1979-05-27T07:32:00.5-07:00
+utc = 1979-05-27 07:32:00Z
+ldt = 1979-05-27T07:32:00
+ld = 1979-05-27
+lt = 07:32:00.999
+"quoted key" = 1
+'' = 2
+`), m))
+
+	get := func(key string) any {
+		v, ok := m.Get(key)
+		assert.True(t, ok)
+		return v
+	}
+	assert.Equal(t, get("int"), int64(1000))
+	assert.Equal(t, get("hex"), int64(0xDEADBEEF))
+	assert.Equal(t, get("oct"), int64(0o755))
+	assert.Equal(t, get("bin"), int64(13))
+	assert.Equal(t, get("float"), -6.626e-34)
+	assert.True(t, math.IsInf(get("inf").(float64), -1))
+	assert.True(t, math.IsNaN(get("nan").(float64)))
+	assert.Equal(t, get("str"), "tab\t quote\" é 😀")
+	assert.Equal(t, get("lit"), `C:\Users\`)
+	assert.Equal(t, get("multi"), "Roses are red Violets are blue\n\"quoted\" \"")
+	assert.Equal(t, get("multilit"), "first\nsecond''")
+	assert.True(t, get("odt").(time.Time).Equal(time.Date(1979, 5, 27, 14, 32, 0, 5e8, time.UTC)))
+	assert.Equal(t, get("utc"), time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC))
+	ldt := get("ldt").(time.Time)
+	assert.Equal(t, ldt.Format(time.DateTime), "1979-05-27 07:32:00")
+	assert.Equal(t, ldt.Location().String(), "datetime-local")
+	assert.Equal(t, get("ld").(time.Time).Location().String(), "date-local")
+	assert.Equal(t, get("lt").(time.Time).Format("15:04:05.000"), "07:32:00.999")
+	assert.Equal(t, get("quoted key"), int64(1))
+	assert.Equal(t, get(""), int64(2))
+}
+
+func TestUnmarshalTOMLErrors(t *testing.T) {
+	for _, data := range []string{
+		"a = 1\na = 2",
+		"a.b = 1\na = 2",
+		"[a]\n[a]",
+		"[a]\nb.c = 1\n[a.b]",
+		"a = {b = 1}\n[a.c]",
+		"a = {b = 1}\na.c = 2",
+		"a = [1]\n[[a]]",
+		"[[a]]\n[a]",
+		"a = {b = 1,}",
+		"a = {\nb = 1}",
+		"a = 01",
+		"a = 1__0",
+		"a = 0x",
+		"a = 1.",
+		"a = .5",
+		"a = \"\\x41\"",
+		"a = \"open",
+		"a = 'line\nbreak'",
+		"a = 1 b = 2",
+		"a",
+		"= 1",
+		"a = 1979-13-01",
+		"a = 9223372036854775808",
+		"[a",
+		"a = [1 2]",
+		"a = true # \x01",
+	} {
+		err := jsonmap.UnmarshalTOML([]byte(data), jsonmap.New())
+		assert.Error(t, err)
+	}
+
+	err := jsonmap.UnmarshalTOML([]byte("[a]\nx = 1\n\n[a]\n"), jsonmap.New())
+	var tomlErr *jsonmap.TOMLError
+	assert.True(t, errors.As(err, &tomlErr))
+	assert.Equal(t, err.Error(), "toml: line 4, column 2: table a is already defined")
+
+	// deep nesting is an error, not a stack overflow
+	assert.NoError(t, jsonmap.UnmarshalTOML([]byte("a = "+strings.Repeat("[", 10000)+strings.Repeat("]", 10000)), jsonmap.New()))
+	for _, data := range []string{
+		"a = " + strings.Repeat("[", 3000000),
+		"a = " + strings.Repeat("{b=", 3000000),
+	} {
+		err = jsonmap.UnmarshalTOML([]byte(data), jsonmap.New())
+		assert.True(t, errors.As(err, &tomlErr))
+		assert.Equal(t, tomlErr.Msg, "exceeded max depth 10000")
+	}
+}
+
+func TestMarshalTOML(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"title": "example",
+		"owner": {"name": "Tom", "tags": ["a", "b"]},
+		"port": 8080,
+		"ratio": 0.5,
+		"text": "line 1\nline \"2\"",
+		"key with space": true,
+		"servers": {
+			"alpha": {"ip": "10.0.0.1", "nested": {"deep": 1}},
+			"beta": {}
+		},
+		"products": [{"name": "Hammer"}, {"name": "Nail", "dims": {"w": 1}}]
+	}`), m))
+	data, err := jsonmap.MarshalTOML(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `title = "example"
+owner = { name = "Tom", tags = ["a", "b"] }
+port = 8080
+ratio = 0.5
+text = """
+line 1
+line "2\""""
+"key with space" = true
+
+[servers.alpha]
+ip = "10.0.0.1"
+
+[servers.alpha.nested]
+deep = 1
+
+[servers.beta]
+
+[[products]]
+name = "Hammer"
+
+[[products]]
+name = "Nail"
+
+[products.dims]
+w = 1
+`)
+
+	// decoding gives the same document, in the same order
+	back := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalTOML(data, back))
+	want, err := json.Marshal(m)
+	assert.NoError(t, err)
+	got, err := json.Marshal(back)
+	assert.NoError(t, err)
+	assert.Equal(t, string(got), string(want))
+
+	m.Set("missing", nil)
+	_, err = jsonmap.MarshalTOML(m)
+	assert.Equal(t, err.Error(), "toml: key missing: null is not supported")
+}
+
+func TestTOMLRoundTrip(t *testing.T) {
+	const data = `int = -12
+float = 1.5e-09
+inf = inf
+odt = 1979-05-27T07:32:00.5-07:00
+ldt = 1979-05-27T07:32:00
+ld = 1979-05-27
+lt = 07:32:00.999
+big = 9223372036854775807
+"a.b" = "dot"
+mixed = [1, "two", { three = 3 }, []]
+`
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalTOML([]byte(data), m))
+	out, err := jsonmap.MarshalTOML(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(out), data)
+}
@@ -0,0 +1,93 @@
+package test_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func TestWalk(t *testing.T) {
+	m := parse(t, `{"a":1,"b":{"c":null,"d":[1,null,{"e":2},null,3]},"f":"x","g":null}`)
+
+	var visited []string
+	jsonmap.Walk(m, func(path jsonmap.Path, key jsonmap.Key, value jsonmap.Value) jsonmap.Action {
+		visited = append(visited, path.String())
+		switch {
+		case value == nil:
+			return jsonmap.Delete
+		case key == "a":
+			return jsonmap.Replace(10)
+		case key == "b":
+			return jsonmap.Rename("B")
+		case key == "e":
+			return jsonmap.Skip
+		}
+		return jsonmap.Continue
+	})
+	assert.Equal(t, strings.Join(visited, " "), "/a /b /B/c /B/d /B/d/0 /B/d/1 /B/d/2 /B/d/2/e /B/d/3 /B/d/4 /f /g")
+
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"a":10,"B":{"d":[1,{"e":2},3]},"f":"x"}`)
+}
+
+func TestWalkStop(t *testing.T) {
+	m := parse(t, `{"a":[1,2,3],"b":2}`)
+	var visited []string
+	jsonmap.Walk(m, func(path jsonmap.Path, key jsonmap.Key, value jsonmap.Value) jsonmap.Action {
+		visited = append(visited, path.String())
+		if path.String() == "/a/1" {
+			return jsonmap.Stop
+		}
+		return jsonmap.Continue
+	})
+	assert.Equal(t, strings.Join(visited, " "), "/a /a/0 /a/1")
+}
+
+func TestWalkRenameOverExisting(t *testing.T) {
+	m := parse(t, `{"a":1,"b":2,"c":3}`)
+	jsonmap.Walk(m, func(path jsonmap.Path, key jsonmap.Key, value jsonmap.Value) jsonmap.Action {
+		if key == "a" {
+			return jsonmap.Rename("b")
+		}
+		return jsonmap.Continue
+	})
+	assert.Equal(t, strings.Join(m.Keys(), ","), "b,c")
+	v, _ := m.Get("b")
+	assert.Equal(t, v, 1.)
+}
+
+func TestWalkRenameKeepsPaths(t *testing.T) {
+	m := parse(t, `{"a":{"b":1}}`)
+	var paths []jsonmap.Path
+	jsonmap.Walk(m, func(path jsonmap.Path, key jsonmap.Key, value jsonmap.Value) jsonmap.Action {
+		paths = append(paths, path)
+		if key == "a" {
+			return jsonmap.Rename("x")
+		}
+		return jsonmap.Continue
+	})
+	assert.Equal(t, len(paths), 2)
+	assert.Equal(t, paths[0].String(), "/a")
+	assert.Equal(t, paths[1].String(), "/x/b")
+}
+
+func TestTransform(t *testing.T) {
+	m := parse(t, `{"a":" x ","b":{"c":" y "}}`)
+	m2 := jsonmap.Transform(m, func(path jsonmap.Path, key jsonmap.Key, value jsonmap.Value) jsonmap.Action {
+		if s, ok := value.(string); ok {
+			return jsonmap.Replace(strings.TrimSpace(s))
+		}
+		return jsonmap.Continue
+	})
+
+	data, err := json.Marshal(m2)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"a":"x","b":{"c":"y"}}`)
+	data, err = json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `{"a":" x ","b":{"c":" y "}}`)
+}
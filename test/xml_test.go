@@ -0,0 +1,172 @@
+package test_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+const soapXML = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- request -->
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns="urn:shop">
+  <soap:Body>
+    <Order id="42" status="new">
+      <Item sku="b">2</Item>
+      <Note>fragile &amp; heavy</Note>
+      <Item sku="a">1</Item>
+      <Empty/>
+    </Order>
+  </soap:Body>
+</soap:Envelope>`
+
+func fromXML(t *testing.T, c jsonmap.XMLConverter, doc string) string {
+	t.Helper()
+	m, err := c.FromXML(strings.NewReader(doc))
+	assert.NoError(t, err)
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestFromXML(t *testing.T) {
+	assert.Equal(t, fromXML(t, jsonmap.XMLConverter{}, soapXML), `{"soap:Envelope":{`+
+		`"@xmlns:soap":"http://www.w3.org/2003/05/soap-envelope","@xmlns":"urn:shop","soap:Body":{"Order":{`+
+		`"@id":"42","@status":"new",`+
+		`"Item":[{"@sku":"b","#text":"2"},{"@sku":"a","#text":"1"}],`+
+		`"Note":"fragile \u0026 heavy","Empty":""}}}}`)
+
+	assert.Equal(t, fromXML(t, jsonmap.XMLConverter{Convention: jsonmap.XMLBadgerFish}, soapXML), `{"soap:Envelope":{`+
+		`"@xmlns":{"soap":"http://www.w3.org/2003/05/soap-envelope","$":"urn:shop"},"soap:Body":{"Order":{`+
+		`"@id":"42","@status":"new",`+
+		`"Item":[{"@sku":"b","$":"2"},{"@sku":"a","$":"1"}],`+
+		`"Note":{"$":"fragile \u0026 heavy"},"Empty":{}}}}}`)
+
+	assert.Equal(t, fromXML(t, jsonmap.XMLConverter{Convention: jsonmap.XMLParker}, soapXML),
+		`{"soap:Envelope":{"soap:Body":{"Order":{"Item":["2","1"],"Note":"fragile \u0026 heavy","Empty":null}}}}`)
+
+	// FromXML is shortcut for default convention
+	m, err := jsonmap.FromXML(strings.NewReader(`<a x="1"> text <b/>more</a>`))
+	assert.NoError(t, err)
+	out, _ := json.Marshal(m)
+	assert.Equal(t, string(out), `{"a":{"@x":"1","#text":"text more","b":""}}`)
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	for _, c := range []jsonmap.XMLConverter{
+		{},
+		{Convention: jsonmap.XMLBadgerFish},
+		{Convention: jsonmap.XMLParker},
+		{Indent: "  "},
+	} {
+		m, err := c.FromXML(strings.NewReader(soapXML))
+		assert.NoError(t, err)
+		data, err := c.ToXML(m)
+		assert.NoError(t, err)
+		back, err := c.FromXML(strings.NewReader(string(data)))
+		assert.NoError(t, err)
+		assert.Equal(t, back.String(), m.String())
+	}
+
+	m, err := jsonmap.FromXML(strings.NewReader(soapXML))
+	assert.NoError(t, err)
+	data, err := jsonmap.XMLConverter{Indent: "  "}.ToXML(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns="urn:shop">
+  <soap:Body>
+    <Order id="42" status="new">
+      <Item sku="b">2</Item>
+      <Item sku="a">1</Item>
+      <Note>fragile &amp; heavy</Note>
+      <Empty></Empty>
+    </Order>
+  </soap:Body>
+</soap:Envelope>
+`)
+}
+
+func TestXMLKeepOrder(t *testing.T) {
+	const doc = `<r id="1"><a>1</a><b>2</b><a>3</a><p>Hello <i>world</i>, bye</p><q><c/><c/></q></r>`
+	c := jsonmap.XMLConverter{KeepOrder: true}
+	assert.Equal(t, fromXML(t, c, doc), `{"r":{"@id":"1","#content":[{"a":"1"},{"b":"2"},{"a":"3"},`+
+		`{"p":{"#content":["Hello ",{"i":"world"},", bye"]}},{"q":{"c":["",""]}}]}}`)
+	assert.Equal(t, fromXML(t, jsonmap.XMLConverter{Convention: jsonmap.XMLParker, KeepOrder: true}, doc),
+		`{"r":{"#content":[{"a":"1"},{"b":"2"},{"a":"3"},{"p":{"i":"world"}},{"q":{"c":[null,null]}}]}}`)
+
+	m, err := c.FromXML(strings.NewReader(doc))
+	assert.NoError(t, err)
+	data, err := c.ToXML(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), strings.Replace(doc, "<c/><c/>", "<c></c><c></c>", 1))
+
+	c.Indent = "  "
+	data, err = c.ToXML(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `<r id="1">
+  <a>1</a>
+  <b>2</b>
+  <a>3</a>
+  <p>Hello <i>world</i>, bye</p>
+  <q>
+    <c></c>
+    <c></c>
+  </q>
+</r>
+`)
+}
+
+func TestToXML(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, json.Unmarshal([]byte(`{"root":{"@n":1.5,"@q":"a\"b","b":true,"list":[1,{"#text":"x","@k":"v"},null],"s":"<&>"}}`), m))
+	data, err := jsonmap.ToXML(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `<root n="1.5" q="a&quot;b"><b>true</b><list>1</list><list k="v">x</list><list/><s>&lt;&amp;&gt;</s></root>`)
+
+	// Parker writes keys starting with @ as elements, so they are invalid
+	_, err = jsonmap.XMLConverter{Convention: jsonmap.XMLParker}.ToXML(m)
+	assert.Error(t, err)
+
+	for _, doc := range []string{
+		`{}`,
+		`{"a":1,"b":2}`,
+		`{"a":[1,2]}`,
+		`{"a":{"b":[[1]]}}`,
+		`{"a":{"1b":1}}`,
+		`{"a":{"@x":{"y":1}}}`,
+		`{"a":"\u0000"}`,
+		`{"a":{"@x":"\u001b"}}`,
+		`{"a":"\ufffe"}`,
+		`{"a":{"#content":"x"}}`,
+		`{"a":{"#content":[{"b":1,"c":2}]}}`,
+	} {
+		m := jsonmap.New()
+		assert.NoError(t, json.Unmarshal([]byte(doc), m))
+		_, err := jsonmap.ToXML(m)
+		assert.Error(t, err)
+	}
+}
+
+func TestFromXMLErrors(t *testing.T) {
+	for _, doc := range []string{
+		``,
+		`text`,
+		`<a></b>`,
+		`<a>`,
+		`<a/><b/>`,
+		`</a>`,
+		`<a/>text`,
+		`<a x="1" x="2"`,
+	} {
+		_, err := jsonmap.FromXML(strings.NewReader(doc))
+		assert.Error(t, err)
+	}
+
+	// deep nesting is an error, not a stack overflow
+	_, err := jsonmap.FromXML(strings.NewReader(strings.Repeat("<a>", 10000) + strings.Repeat("</a>", 10000)))
+	assert.NoError(t, err)
+	_, err = jsonmap.FromXML(strings.NewReader(strings.Repeat("<a>", 3000000)))
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "exceeded max depth 10000"))
+}
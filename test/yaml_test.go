@@ -0,0 +1,298 @@
+package test_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/metalim/jsonmap"
+	"github.com/zeebo/assert"
+)
+
+func yamlJSON(t *testing.T, data string) string {
+	t.Helper()
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalYAML([]byte(data), m))
+	out, err := json.Marshal(m)
+	assert.NoError(t, err)
+	return string(out)
+}
+
+func TestMarshalYAML(t *testing.T) {
+	m := jsonmap.New()
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"z": 1, "a": "plain text", "version": "1.20", "enabled": "yes", "empty": "", "nothing": null,
+		"list": [1, true, {"name": "x", "tags": []}, [2, 3]],
+		"nested": {"b": {}, "a": "key: value"},
+		"script": "echo 1\necho 2\n",
+		"quote": "it's",
+		"tab": "a\tb",
+		"float": 1.5
+	}`), m))
+	data, err := jsonmap.MarshalYAML(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `z: 1
+a: plain text
+version: '1.20'
+enabled: 'yes'
+empty: ''
+nothing: null
+list:
+  - 1
+  - true
+  - name: x
+    tags: []
+  - - 2
+    - 3
+nested:
+  b: {}
+  a: 'key: value'
+script: |
+  echo 1
+  echo 2
+quote: it's
+tab: "a\tb"
+float: 1.5
+`)
+
+	data, err = jsonmap.YAMLEmitter{Indent: 4, FlowLevel: 2}.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), `z: 1
+a: plain text
+version: '1.20'
+enabled: 'yes'
+empty: ''
+nothing: null
+list:
+    - 1
+    - true
+    - {name: x, tags: []}
+    - [2, 3]
+nested:
+    b: {}
+    a: 'key: value'
+script: |
+    echo 1
+    echo 2
+quote: it's
+tab: "a\tb"
+float: 1.5
+`)
+
+	data, err = jsonmap.MarshalYAML(jsonmap.New())
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), "{}\n")
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	strs := []string{
+		"", " lead", "trail ", "true", "False", "null", "~", "12", "-1.5e3", "0x1F", ".inf", "on", "1_000", "2024-01-31",
+		"a: b", "a #b", "#c", "- x", "[x]", "{x}", "&a", "*a", "!t", "|", ">", "'", `"`, "%", "@", "`", "<<", "...",
+		"line\n", "line", "two\nlines", "keep\n\n", " indented\nblock", "\nleading", "nbsp\u00a0", "ctrl\x01", "é😀",
+	}
+	m := jsonmap.New()
+	a := make([]any, 0, len(strs))
+	for _, s := range strs {
+		m.Set(s, s)
+		a = append(a, s)
+	}
+	m.Set("list", a)
+	m.Set("flow", map[string]any{"k": a})
+	for _, e := range []jsonmap.YAMLEmitter{{}, {Indent: 3}, {FlowLevel: 1}} {
+		data, err := e.Marshal(m)
+		assert.NoError(t, err)
+		back := jsonmap.New()
+		assert.NoError(t, jsonmap.UnmarshalYAML(data, back))
+		want, err := json.Marshal(m)
+		assert.NoError(t, err)
+		got, err := json.Marshal(back)
+		assert.NoError(t, err)
+		assert.Equal(t, string(got), string(want))
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	const manifest = `%YAML 1.2
+--- # deployment
+kind: Deployment
+apiVersion: apps/v1
+metadata:
+  name: web   # comment
+  labels: {app: web, tier: "front end"}
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        image: 'nginx:1.25'
+        ports:
+          - containerPort: 80
+            protocol: TCP
+        args: [--port, 80, --debug]
+      - name: sidecar
+        command:
+        - sh
+        - -c
+        - |
+          echo start
+          exec sleep infinity
+...
+`
+	assert.Equal(t, yamlJSON(t, manifest), `{"kind":"Deployment","apiVersion":"apps/v1",`+
+		`"metadata":{"name":"web","labels":{"app":"web","tier":"front end"}},`+
+		`"spec":{"replicas":3,"template":{"spec":{"containers":[`+
+		`{"name":"web","image":"nginx:1.25","ports":[{"containerPort":80,"protocol":"TCP"}],"args":["--port",80,"--debug"]},`+
+		`{"name":"sidecar","command":["sh","-c","echo start\nexec sleep infinity\n"]}]}}}}`)
+
+	assert.Equal(t, yamlJSON(t, ""), `{}`)
+	assert.Equal(t, yamlJSON(t, "# only comment\n"), `{}`)
+	assert.Equal(t, yamlJSON(t, "a:\nb: ~\nc: []\nd: {}\n"), `{"a":null,"b":null,"c":[],"d":{}}`)
+}
+
+func TestUnmarshalYAMLScalars(t *testing.T) {
+	assert.Equal(t, yamlJSON(t, `
+int: 12
+neg: -3
+oct: 0o17
+hex: 0xff
+float: 1.5e3
+dot: .5
+bools: [true, False, TRUE, yes, off]
+nulls: [null, ~, Null, ]
+str: !!str 12
+quoted: "12"
+version: 1.2.3
+url: http://example.com/a#b
+comment: value # not part of it
+single: 'it''s'
+double: "tab\tnew\nline\u00e9\x41\\"
+`), `{"int":12,"neg":-3,"oct":15,"hex":255,"float":1500,"dot":0.5,`+
+		`"bools":[true,false,true,"yes","off"],"nulls":[null,null,null],"str":"12","quoted":"12","version":"1.2.3",`+
+		`"url":"http://example.com/a#b","comment":"value","single":"it's","double":"tab\tnew\nlineéA\\"}`)
+
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalYAML([]byte("inf: -.inf\nnan: .NaN\n"), m))
+	v, _ := m.Get("inf")
+	assert.True(t, math.IsInf(v.(float64), -1))
+	v, _ = m.Get("nan")
+	assert.True(t, math.IsNaN(v.(float64)))
+}
+
+func TestUnmarshalYAMLMultiline(t *testing.T) {
+	assert.Equal(t, yamlJSON(t, `
+plain: first
+  second
+
+  third
+double: "first
+  second \
+  third"
+literal: |
+  a
+    b
+
+  c
+
+
+folded: >
+  a
+  b
+
+  c
+    d
+  e
+strip: |-
+  text
+
+keep: |+
+  text
+
+indicator: |2
+    four
+  two
+`), `{"plain":"first second\nthird","double":"first second third",`+
+		`"literal":"a\n  b\n\nc\n","folded":"a b\nc\n  d\ne\n","strip":"text","keep":"text\n\n","indicator":"  four\ntwo\n"}`)
+}
+
+func TestUnmarshalYAMLAnchors(t *testing.T) {
+	assert.Equal(t, yamlJSON(t, `
+base: &base
+  image: nginx
+  replicas: 1
+tags: &tags [a, b]
+web:
+  <<: *base
+  replicas: 3
+  tags: *tags
+worker:
+  name: w
+  <<: [*base, {extra: true}]
+`), `{"base":{"image":"nginx","replicas":1},"tags":["a","b"],`+
+		`"web":{"image":"nginx","replicas":3,"tags":["a","b"]},`+
+		`"worker":{"name":"w","image":"nginx","replicas":1,"extra":true}}`)
+
+	// aliases are copies
+	m := jsonmap.New()
+	assert.NoError(t, jsonmap.UnmarshalYAML([]byte("a: &x {k: 1}\nb: *x\n"), m))
+	a, _ := m.Get("a")
+	b, _ := m.Get("b")
+	a.(*jsonmap.Map).Set("k", 2)
+	v, _ := b.(*jsonmap.Map).Get("k")
+	assert.Equal(t, v, 1.0)
+}
+
+func TestUnmarshalYAMLErrors(t *testing.T) {
+	for _, data := range []string{
+		"- a\n- b\n",
+		"plain",
+		"a: 1\na: 2\n",
+		"a: b: c\n",
+		"a:\n  b: 1\n c: 2\n",
+		"a: [1, 2\n",
+		"a: \"open\n",
+		"a: *missing\n",
+		"? complex\n: key\n",
+		"a: 1\n---\nb: 2\n",
+		"a: \"\\q\"\n",
+	} {
+		m := jsonmap.New()
+		err := jsonmap.UnmarshalYAML([]byte(data), m)
+		assert.Error(t, err)
+	}
+
+	err := jsonmap.UnmarshalYAML([]byte("a: 1\nb:\n  c: 2\n  c: 3\n"), jsonmap.New())
+	var yamlErr *jsonmap.YAMLError
+	assert.True(t, errors.As(err, &yamlErr))
+	assert.Equal(t, yamlErr.Line, 4)
+	assert.Equal(t, yamlErr.Column, 3)
+	assert.Equal(t, err.Error(), `yaml: line 4, column 3: duplicate key "c"`)
+}
+
+func TestUnmarshalYAMLAliasExpansion(t *testing.T) {
+	// each level has 10 aliases of the previous one, so the last has 10^n values
+	laughs := func(n int) string {
+		var b strings.Builder
+		b.WriteString("a0: &a0 [x]\n")
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(&b, "a%d: &a%d [%s]\n", i, i, strings.TrimSuffix(strings.Repeat(fmt.Sprintf("*a%d,", i-1), 10), ","))
+		}
+		return b.String()
+	}
+	assert.NoError(t, jsonmap.UnmarshalYAML([]byte(laughs(3)), jsonmap.New()))
+	err := jsonmap.UnmarshalYAML([]byte(laughs(9)), jsonmap.New())
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "aliases expand into too many values"))
+}
+
+func TestUnmarshalYAMLMaxDepth(t *testing.T) {
+	data := "a: " + strings.Repeat("[", 10000) + strings.Repeat("]", 10000) + "\nb: {c: [1]}\n"
+	assert.NoError(t, jsonmap.UnmarshalYAML([]byte(data), jsonmap.New()))
+
+	err := jsonmap.UnmarshalYAML([]byte("a: "+strings.Repeat("[{b: ", 3000000)), jsonmap.New())
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "exceeded max depth 10000"))
+}
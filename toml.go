@@ -0,0 +1,921 @@
+package jsonmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Zones of TOML local date-times, dates and times, which have no offset.
+// MarshalTOML writes times in these zones back in their local form.
+var (
+	tomlLocalDateTime = time.FixedZone("datetime-local", 0)
+	tomlLocalDate     = time.FixedZone("date-local", 0)
+	tomlLocalTime     = time.FixedZone("time-local", 0)
+)
+
+// TOMLError is a syntax error in TOML input, or a key defined twice.
+type TOMLError struct {
+	Line   int // 1-based
+	Column int // 1-based, in bytes
+	Msg    string
+}
+
+func (e *TOMLError) Error() string {
+	return fmt.Sprintf("toml: line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// UnmarshalTOML parses TOML v1.0 document, and stores it in m, keeping order of keys.
+// Tables, inline tables and dotted keys are decoded as *Map, arrays and arrays of tables as []any.
+// Integers are decoded as int64, floats as float64, and date-times as time.Time.
+// Local date-times, dates and times are decoded in zones named "datetime-local", "date-local" and "time-local",
+// with zero offset, and local times are on January 1 of year 0.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := jsonmap.UnmarshalTOML(data, m)
+func UnmarshalTOML(data []byte, m *Map) error {
+	p := &tomlParser{data: data, line: 1, root: New(), kinds: map[*Map]tomlKind{}, arrays: map[tomlSlot]bool{}}
+	p.kinds[p.root] = tomlHeader
+	if err := p.document(); err != nil {
+		return err
+	}
+	for el := p.root.First(); el != nil; el = el.Next() {
+		m.Push(el.key, el.value)
+	}
+	return nil
+}
+
+// tomlKind is how a table was defined, which tells how it can be extended.
+type tomlKind int
+
+const (
+	tomlImplicit tomlKind = iota // by header of its sub-table, can be defined by its own header later
+	tomlHeader                   // by [header]
+	tomlDotted                   // by dotted key, can be extended by dotted keys and headers of sub-tables
+	tomlInline                   // by inline table, can't be extended
+)
+
+// tomlSlot is a key in a table, to tell arrays of tables from static arrays.
+type tomlSlot struct {
+	m   *Map
+	key Key
+}
+
+type tomlParser struct {
+	data      []byte
+	pos       int
+	line      int
+	lineStart int
+	root      *Map
+	kinds     map[*Map]tomlKind
+	arrays    map[tomlSlot]bool // arrays of tables
+	depth     int               // nesting of arrays and inline tables
+}
+
+func (p *tomlParser) errorf(format string, args ...any) error {
+	return &TOMLError{Line: p.line, Column: p.pos - p.lineStart + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *tomlParser) peek() byte {
+	return p.at(p.pos)
+}
+
+func (p *tomlParser) at(i int) byte {
+	if i >= len(p.data) {
+		return 0
+	}
+	return p.data[i]
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+// ws skips spaces and tabs.
+func (p *tomlParser) ws() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.pos++
+	}
+}
+
+// newline skips line break at current position. Reports whether there was one.
+func (p *tomlParser) newline() bool {
+	switch {
+	case p.peek() == '\n':
+		p.pos++
+	case p.peek() == '\r' && p.at(p.pos+1) == '\n':
+		p.pos += 2
+	default:
+		return false
+	}
+	p.line++
+	p.lineStart = p.pos
+	return true
+}
+
+// comment skips a comment at current position.
+func (p *tomlParser) comment() error {
+	if p.peek() != '#' {
+		return nil
+	}
+	for !p.eof() && p.peek() != '\n' {
+		if c := p.peek(); c < 0x20 && c != '\t' && !(c == '\r' && p.at(p.pos+1) == '\n') || c == 0x7F {
+			return p.errorf("control character %q in comment", c)
+		}
+		p.pos++
+	}
+	return nil
+}
+
+// lineEnd skips whitespace and a comment up to the end of the line.
+func (p *tomlParser) lineEnd() error {
+	p.ws()
+	if err := p.comment(); err != nil {
+		return err
+	}
+	if !p.newline() && !p.eof() {
+		return p.errorf("expected end of line, found %q", p.peek())
+	}
+	return nil
+}
+
+// blank skips whitespace, comments and line breaks, inside arrays.
+func (p *tomlParser) blank() error {
+	for {
+		p.ws()
+		if err := p.comment(); err != nil {
+			return err
+		}
+		if !p.newline() {
+			return nil
+		}
+	}
+}
+
+func (p *tomlParser) document() error {
+	table := p.root
+	for !p.eof() {
+		p.ws()
+		switch p.peek() {
+		case '#', '\n', '\r', 0:
+		case '[':
+			t, err := p.header()
+			if err != nil {
+				return err
+			}
+			table = t
+		default:
+			if err := p.keyValue(table); err != nil {
+				return err
+			}
+		}
+		if err := p.lineEnd(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// header parses [table] or [[array of tables]] header, and returns the table.
+func (p *tomlParser) header() (*Map, error) {
+	p.pos++
+	array := p.peek() == '['
+	if array {
+		p.pos++
+	}
+	p.ws()
+	line, col := p.line, p.pos-p.lineStart+1
+	keys, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != ']' || array && p.at(p.pos+1) != ']' {
+		return nil, p.errorf("expected ']' after table name")
+	}
+	p.pos++
+	if array {
+		p.pos++
+	}
+
+	name := tomlPath(keys)
+	fail := func(format string) error {
+		return &TOMLError{Line: line, Column: col, Msg: fmt.Sprintf(format, name)}
+	}
+	m := p.root
+	for _, k := range keys[:len(keys)-1] {
+		v, ok := m.Get(k)
+		if !ok {
+			t := New()
+			p.kinds[t] = tomlImplicit
+			m.Set(k, t)
+			m = t
+			continue
+		}
+		switch v := v.(type) {
+		case *Map:
+			if p.kinds[v] == tomlInline {
+				return nil, fail("table %s extends inline table")
+			}
+			m = v
+			continue
+		case []any:
+			if p.arrays[tomlSlot{m, k}] {
+				m = v[len(v)-1].(*Map)
+				continue
+			}
+		}
+		return nil, fail("table %s extends a value, which is not a table")
+	}
+
+	k := keys[len(keys)-1]
+	v, ok := m.Get(k)
+	t := New()
+	p.kinds[t] = tomlHeader
+	switch {
+	case !ok && array:
+		m.Set(k, []any{t})
+		p.arrays[tomlSlot{m, k}] = true
+		return t, nil
+	case !ok:
+		m.Set(k, t)
+		return t, nil
+	case array:
+		if a, isArray := v.([]any); isArray && p.arrays[tomlSlot{m, k}] {
+			m.Set(k, append(a, t))
+			return t, nil
+		}
+		return nil, fail("array of tables %s is already defined as a value")
+	}
+	if v, isMap := v.(*Map); isMap && p.kinds[v] == tomlImplicit {
+		p.kinds[v] = tomlHeader
+		return v, nil
+	}
+	return nil, fail("table %s is already defined")
+}
+
+// key parses dotted key, and whitespace after it.
+func (p *tomlParser) key() ([]Key, error) {
+	var keys []Key
+	for {
+		var k string
+		var err error
+		switch c := p.peek(); {
+		case c == '"':
+			k, err = p.basic()
+		case c == '\'':
+			k, err = p.literal()
+		default:
+			start := p.pos
+			for c := p.peek(); c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'; c = p.peek() {
+				p.pos++
+			}
+			if p.pos == start {
+				return nil, p.errorf("expected key, found %q", c)
+			}
+			k = string(p.data[start:p.pos])
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		p.ws()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+		p.ws()
+	}
+}
+
+// keyValue parses key = value pair into the table.
+func (p *tomlParser) keyValue(table *Map) error {
+	line, col := p.line, p.pos-p.lineStart+1
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.errorf("expected '=' after key")
+	}
+	p.pos++
+	p.ws()
+	value, err := p.value()
+	if err != nil {
+		return err
+	}
+
+	m := table
+	for i, k := range keys {
+		v, ok := m.Get(k)
+		if i == len(keys)-1 {
+			if ok {
+				break
+			}
+			m.Set(k, value)
+			return nil
+		}
+		if !ok {
+			t := New()
+			p.kinds[t] = tomlDotted
+			m.Set(k, t)
+			m = t
+			continue
+		}
+		if t, isMap := v.(*Map); isMap && p.kinds[t] == tomlDotted {
+			m = t
+			continue
+		}
+		keys = keys[:i+1]
+		break
+	}
+	return &TOMLError{Line: line, Column: col, Msg: fmt.Sprintf("key %s is already defined", tomlPath(keys))}
+}
+
+var (
+	tomlInt      = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	tomlHex      = regexp.MustCompile(`^0x[0-9A-Fa-f](_?[0-9A-Fa-f])*$`)
+	tomlOct      = regexp.MustCompile(`^0o[0-7](_?[0-7])*$`)
+	tomlBin      = regexp.MustCompile(`^0b[01](_?[01])*$`)
+	tomlFloat    = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+	tomlDateTime = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}[Tt ][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?([Zz]|[+-][0-9]{2}:[0-9]{2})?$`)
+	tomlDate     = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+	tomlTime     = regexp.MustCompile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?$`)
+)
+
+// value parses a value.
+func (p *tomlParser) value() (Value, error) {
+	switch p.peek() {
+	case '"':
+		return p.basic()
+	case '\'':
+		return p.literal()
+	case '[', '{':
+		if p.depth == maxDepth {
+			return nil, p.errorf("exceeded max depth %d", maxDepth)
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.peek() == '[' {
+			return p.array()
+		}
+		return p.inlineTable()
+	}
+
+	start := p.pos
+	for c := p.peek(); c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || strings.IndexByte("+-_.:", c) >= 0; c = p.peek() {
+		p.pos++
+		// date and time can be separated by space
+		if p.pos-start == 10 && p.peek() == ' ' && tomlDate.Match(p.data[start:p.pos]) && isDigit(p.at(p.pos+1)) {
+			p.pos++
+		}
+	}
+	s := string(p.data[start:p.pos])
+	switch s {
+	case "":
+		return nil, p.errorf("expected value, found %q", p.peek())
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), nil
+	}
+	fail := func() (Value, error) {
+		p.pos = start
+		return nil, p.errorf("invalid value %q", s)
+	}
+	digits := strings.ReplaceAll(s, "_", "")
+	var err error
+	var v Value
+	switch {
+	case tomlInt.MatchString(s):
+		v, err = strconv.ParseInt(digits, 10, 64)
+	case tomlHex.MatchString(s):
+		v, err = strconv.ParseInt(digits[2:], 16, 64)
+	case tomlOct.MatchString(s):
+		v, err = strconv.ParseInt(digits[2:], 8, 64)
+	case tomlBin.MatchString(s):
+		v, err = strconv.ParseInt(digits[2:], 2, 64)
+	case tomlFloat.MatchString(s):
+		v, err = strconv.ParseFloat(digits, 64)
+	case tomlDateTime.MatchString(s):
+		s = strings.ToUpper(s[:10]) + "T" + strings.ToUpper(s[11:])
+		if strings.HasSuffix(s, "Z") || strings.LastIndexAny(s, "+-") > 10 {
+			v, err = time.Parse(time.RFC3339Nano, s)
+		} else {
+			v, err = time.ParseInLocation("2006-01-02T15:04:05", s, tomlLocalDateTime)
+		}
+	case tomlDate.MatchString(s):
+		v, err = time.ParseInLocation("2006-01-02", s, tomlLocalDate)
+	case tomlTime.MatchString(s):
+		v, err = time.ParseInLocation("15:04:05", s, tomlLocalTime)
+	default:
+		return fail()
+	}
+	if err != nil {
+		return fail()
+	}
+	return v, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// basic parses basic string, or multi-line basic string in triple quotes.
+func (p *tomlParser) basic() (string, error) {
+	multi := p.at(p.pos+1) == '"' && p.at(p.pos+2) == '"'
+	if multi {
+		p.pos += 3
+		p.newline() // trimmed
+	} else {
+		p.pos++
+	}
+	var b []byte
+	for {
+		c := p.peek()
+		switch {
+		case p.eof():
+			return "", p.errorf("unterminated string")
+		case c == '"':
+			if !multi {
+				p.pos++
+				return string(b), nil
+			}
+			n := p.quotes()
+			if n >= 3 {
+				b = append(b, strings.Repeat(`"`, n-3)...)
+				return string(b), nil
+			}
+			b = append(b, strings.Repeat(`"`, n)...)
+		case c == '\\':
+			p.pos++
+			if multi && p.lineContinuation() {
+				continue
+			}
+			r, err := p.escape()
+			if err != nil {
+				return "", err
+			}
+			b = utf8.AppendRune(b, r)
+		case multi && (c == '\n' || c == '\r'):
+			if !p.newline() {
+				return "", p.errorf("control character %q in string", c)
+			}
+			b = append(b, '\n')
+		case c < 0x20 && c != '\t' || c == 0x7F:
+			return "", p.errorf("control character %q in string", c)
+		default:
+			b = append(b, c)
+			p.pos++
+		}
+	}
+}
+
+// quotes skips a run of up to 5 quotes, which can end multi-line string, and returns their number.
+func (p *tomlParser) quotes() int {
+	q := p.peek()
+	n := 0
+	for n < 5 && p.peek() == q {
+		p.pos++
+		n++
+	}
+	return n
+}
+
+// lineContinuation skips backslash at the end of line, and whitespace after it, in multi-line basic string.
+func (p *tomlParser) lineContinuation() bool {
+	i := p.pos
+	for c := p.at(i); c == ' ' || c == '\t'; c = p.at(i) {
+		i++
+	}
+	if c := p.at(i); c != '\n' && c != '\r' {
+		return false
+	}
+	p.pos = i
+	for {
+		p.ws()
+		if !p.newline() {
+			return true
+		}
+	}
+}
+
+// escape parses escape sequence after backslash.
+func (p *tomlParser) escape() (rune, error) {
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		return '\b', nil
+	case 't':
+		return '\t', nil
+	case 'n':
+		return '\n', nil
+	case 'f':
+		return '\f', nil
+	case 'r':
+		return '\r', nil
+	case '"':
+		return '"', nil
+	case '\\':
+		return '\\', nil
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n <= len(p.data) {
+			r, err := strconv.ParseUint(string(p.data[p.pos:p.pos+n]), 16, 32)
+			if err == nil && utf8.ValidRune(rune(r)) {
+				p.pos += n
+				return rune(r), nil
+			}
+		}
+	}
+	p.pos--
+	return 0, p.errorf("invalid escape in string")
+}
+
+// literal parses literal string, or multi-line literal string in triple quotes.
+func (p *tomlParser) literal() (string, error) {
+	multi := p.at(p.pos+1) == '\'' && p.at(p.pos+2) == '\''
+	if multi {
+		p.pos += 3
+		p.newline() // trimmed
+	} else {
+		p.pos++
+	}
+	var b []byte
+	for {
+		c := p.peek()
+		switch {
+		case p.eof():
+			return "", p.errorf("unterminated string")
+		case c == '\'':
+			if !multi {
+				p.pos++
+				return string(b), nil
+			}
+			n := p.quotes()
+			if n >= 3 {
+				b = append(b, strings.Repeat("'", n-3)...)
+				return string(b), nil
+			}
+			b = append(b, strings.Repeat("'", n)...)
+		case multi && (c == '\n' || c == '\r'):
+			if !p.newline() {
+				return "", p.errorf("control character %q in string", c)
+			}
+			b = append(b, '\n')
+		case c < 0x20 && c != '\t' || c == 0x7F:
+			return "", p.errorf("control character %q in string", c)
+		default:
+			b = append(b, c)
+			p.pos++
+		}
+	}
+}
+
+// array parses array [...], which can span multiple lines.
+func (p *tomlParser) array() ([]any, error) {
+	p.pos++
+	a := make([]any, 0)
+	for {
+		if err := p.blank(); err != nil {
+			return nil, err
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return a, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+		if err := p.blank(); err != nil {
+			return nil, err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+// inlineTable parses inline table {...}, which must be on a single line.
+func (p *tomlParser) inlineTable() (*Map, error) {
+	p.pos++
+	t := New()
+	p.kinds[t] = tomlDotted // while parsing, to allow dotted keys
+	p.ws()
+	if p.peek() == '}' {
+		p.pos++
+		p.kinds[t] = tomlInline
+		return t, nil
+	}
+	for {
+		if err := p.keyValue(t); err != nil {
+			return nil, err
+		}
+		p.ws()
+		switch p.peek() {
+		case ',':
+			p.pos++
+			p.ws()
+		case '}':
+			p.pos++
+			p.kinds[t] = tomlInline
+			return t, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
+
+// tomlPath returns dotted key, quoting keys when needed.
+func tomlPath(keys []Key) string {
+	var b []byte
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, '.')
+		}
+		b = appendTOMLKey(b, k)
+	}
+	return string(b)
+}
+
+func appendTOMLKey(b []byte, k Key) []byte {
+	bare := k != ""
+	for i := 0; i < len(k) && bare; i++ {
+		c := k[i]
+		bare = c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+	}
+	if bare {
+		return append(b, k...)
+	}
+	return appendTOMLString(b, k, false)
+}
+
+// appendTOMLString appends basic string, or multi-line basic string.
+func appendTOMLString(b []byte, s string, multi bool) []byte {
+	if multi {
+		b = append(b, `"""`+"\n"...)
+	} else {
+		b = append(b, '"')
+	}
+	for i, r := range s {
+		switch {
+		case r == '"' && (!multi || i+1 == len(s) || s[i+1] == '"'):
+			b = append(b, `\"`...)
+		case r == '\\':
+			b = append(b, `\\`...)
+		case r == '\n' && multi, r == '\t':
+			b = append(b, byte(r))
+		case r == '\n':
+			b = append(b, `\n`...)
+		case r == '\r':
+			b = append(b, `\r`...)
+		case r == '\b':
+			b = append(b, `\b`...)
+		case r == '\f':
+			b = append(b, `\f`...)
+		case r < 0x20 || r == 0x7F:
+			b = append(b, fmt.Sprintf(`\u%04X`, r)...)
+		default:
+			b = utf8.AppendRune(b, r)
+		}
+	}
+	if multi {
+		return append(b, `"""`...)
+	}
+	return append(b, '"')
+}
+
+// MarshalTOML returns TOML encoding of the map, keeping order of keys.
+//
+// Maps and arrays of maps at the end of a table are written as [table] and [[array of tables]] sections.
+// Maps before other keys are written as inline tables, so decoding gives keys in the same order.
+// Headers of tables with only sub-tables are omitted. Multi-line strings are written as multi-line basic strings.
+//
+// Numbers with integer values are written as integers, since JSON does not tell them from floats.
+// TOML has no null, so nil values return an error.
+//
+//	data, err := jsonmap.MarshalTOML(m)
+func MarshalTOML(m *Map) ([]byte, error) {
+	w := &tomlWriter{}
+	if err := w.table(m, nil); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+type tomlWriter struct {
+	buf []byte
+}
+
+// table writes key/value pairs of the table, followed by its sections.
+func (w *tomlWriter) table(m *Map, path []Key) error {
+	keys := make([]Key, 0, m.Len())
+	values := make([]Value, 0, m.Len())
+	for el := m.First(); el != nil; el = el.Next() {
+		v, err := tomlNormalize(el.value)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, el.key)
+		values = append(values, v)
+	}
+	split := len(values)
+	for split > 0 && tomlSection(values[split-1]) {
+		split--
+	}
+
+	for i, k := range keys[:split] {
+		w.buf = appendTOMLKey(w.buf, k)
+		w.buf = append(w.buf, " = "...)
+		if s, ok := values[i].(string); ok && strings.Contains(s, "\n") {
+			w.buf = appendTOMLString(w.buf, s, true)
+		} else if err := w.value(values[i], append(path[:len(path):len(path)], k)); err != nil {
+			return err
+		}
+		w.buf = append(w.buf, '\n')
+	}
+
+	for i, k := range keys[split:] {
+		sub := append(path[:len(path):len(path)], k)
+		switch v := values[split+i].(type) {
+		case *Map:
+			if v.Len() == 0 || !tomlOnlySections(v) {
+				w.header(sub, false)
+			}
+			if err := w.table(v, sub); err != nil {
+				return err
+			}
+		case []any:
+			for _, t := range v {
+				w.header(sub, true)
+				if err := w.table(t.(*Map), sub); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (w *tomlWriter) header(path []Key, array bool) {
+	if len(w.buf) > 0 {
+		w.buf = append(w.buf, '\n')
+	}
+	if array {
+		w.buf = append(w.buf, "[["+tomlPath(path)+"]]\n"...)
+	} else {
+		w.buf = append(w.buf, "["+tomlPath(path)+"]\n"...)
+	}
+}
+
+// tomlSection reports whether value can be written as [table] or [[array of tables]].
+func tomlSection(v Value) bool {
+	switch v := v.(type) {
+	case *Map:
+		return true
+	case []any:
+		for _, item := range v {
+			if _, ok := item.(*Map); !ok {
+				return false
+			}
+		}
+		return len(v) > 0
+	}
+	return false
+}
+
+// tomlOnlySections reports whether all values of the map are written as sections, so its header can be omitted.
+func tomlOnlySections(m *Map) bool {
+	for el := m.First(); el != nil; el = el.Next() {
+		v, err := tomlNormalize(el.value)
+		if err != nil || !tomlSection(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlNormalize converts values of types, which are not written directly, through JSON.
+func tomlNormalize(v Value) (Value, error) {
+	if _, ok := v.(time.Time); ok {
+		return v, nil
+	}
+	return normalizeValue(v)
+}
+
+// value writes the value in inline form.
+func (w *tomlWriter) value(v Value, path []Key) error {
+	v, err := tomlNormalize(v)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil:
+		return fmt.Errorf("toml: key %s: null is not supported", tomlPath(path))
+	case *Map:
+		w.buf = append(w.buf, '{')
+		for el := v.First(); el != nil; el = el.Next() {
+			if el != v.First() {
+				w.buf = append(w.buf, ',')
+			}
+			w.buf = append(w.buf, ' ')
+			w.buf = appendTOMLKey(w.buf, el.key)
+			w.buf = append(w.buf, " = "...)
+			if err := w.value(el.value, append(path[:len(path):len(path)], el.key)); err != nil {
+				return err
+			}
+		}
+		if v.Len() > 0 {
+			w.buf = append(w.buf, ' ')
+		}
+		w.buf = append(w.buf, '}')
+	case []any:
+		w.buf = append(w.buf, '[')
+		for i, item := range v {
+			if i > 0 {
+				w.buf = append(w.buf, ", "...)
+			}
+			if err := w.value(item, append(path[:len(path):len(path)], strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		w.buf = append(w.buf, ']')
+	case string:
+		w.buf = appendTOMLString(w.buf, v, false)
+	case bool:
+		w.buf = strconv.AppendBool(w.buf, v)
+	case float64:
+		w.buf = appendTOMLFloat(w.buf, v)
+	case float32:
+		w.buf = appendTOMLFloat(w.buf, float64(v))
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			w.buf = append(w.buf, v...)
+		} else if f, err := v.Float64(); err == nil {
+			w.buf = appendTOMLFloat(w.buf, f)
+		} else {
+			return fmt.Errorf("toml: key %s: invalid number %s", tomlPath(path), v)
+		}
+	case uint64:
+		if v > math.MaxInt64 {
+			return fmt.Errorf("toml: key %s: integer %d overflows int64", tomlPath(path), v)
+		}
+		w.buf = strconv.AppendUint(w.buf, v, 10)
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return fmt.Errorf("toml: key %s: integer %d overflows int64", tomlPath(path), v)
+		}
+		w.buf = strconv.AppendUint(w.buf, uint64(v), 10)
+	case time.Time:
+		switch v.Location() {
+		case tomlLocalDateTime:
+			w.buf = v.AppendFormat(w.buf, "2006-01-02T15:04:05.999999999")
+		case tomlLocalDate:
+			w.buf = v.AppendFormat(w.buf, "2006-01-02")
+		case tomlLocalTime:
+			w.buf = v.AppendFormat(w.buf, "15:04:05.999999999")
+		default:
+			w.buf = v.AppendFormat(w.buf, time.RFC3339Nano)
+		}
+	default: // other integers
+		w.buf = fmt.Appendf(w.buf, "%d", v)
+	}
+	return nil
+}
+
+func appendTOMLFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, "nan"...)
+	case math.IsInf(f, 1):
+		return append(b, "inf"...)
+	case math.IsInf(f, -1):
+		return append(b, "-inf"...)
+	case f == math.Trunc(f) && math.Abs(f) < 1<<53:
+		return strconv.AppendInt(b, int64(f), 10)
+	case math.Abs(f) >= 1e-6 && math.Abs(f) < 1e21:
+		return strconv.AppendFloat(b, f, 'f', -1, 64)
+	}
+	return strconv.AppendFloat(b, f, 'e', -1, 64)
+}
@@ -1,7 +1,6 @@
 package jsonmap
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 )
@@ -14,16 +13,85 @@ import (
 //
 //	err := m.UnmarshalJSON([]byte(`{"a":1,"b":2}`))
 func (m *Map) UnmarshalJSON(data []byte) error {
-	d := json.NewDecoder(bytes.NewReader(data))
-	tok, err := d.Token()
-	if err != nil {
-		return err
-	}
-	if tok != json.Delim('{') {
-		return errors.New("expected '{'")
+	b := builder{root: m}
+	return Scan(data, b.event)
+}
+
+// builder builds nested maps and arrays from Scan events.
+// With root set, the document must be an object, decoded into root.
+// Otherwise any value is decoded into value.
+type builder struct {
+	root  *Map
+	value Value
+	stack []builderFrame
+	key   Key
+}
+
+type builderFrame struct {
+	m        *Map
+	a        []any
+	key      Key  // key of this container in parent map
+	optional bool // dropped if empty
+}
+
+func (b *builder) event(e *Event) error {
+	switch e.Kind {
+	case EventStartObject:
+		if b.stack == nil && b.root != nil {
+			b.stack = append(b.stack, builderFrame{m: b.root})
+			return nil
+		}
+		b.stack = append(b.stack, builderFrame{m: New(), key: b.key})
+	case EventStartArray:
+		if b.stack == nil && b.root != nil {
+			return errors.New("expected '{'")
+		}
+		b.stack = append(b.stack, builderFrame{a: make([]any, 0), key: b.key})
+	case EventKey:
+		b.key = e.Key
+	case EventValue:
+		if len(b.stack) == 0 {
+			if b.root != nil {
+				return errors.New("expected '{'")
+			}
+			b.value = e.Value
+			return nil
+		}
+		b.add(e.Value)
+	case EventEndObject, EventEndArray:
+		f := b.stack[len(b.stack)-1]
+		b.stack = b.stack[:len(b.stack)-1]
+		var v Value = f.a
+		if f.m != nil {
+			v = f.m
+		}
+		if len(b.stack) == 0 {
+			b.value = v
+			return nil
+		}
+		b.key = f.key
+		if f.optional && (f.m != nil && f.m.Len() == 0 || f.m == nil && len(f.a) == 0) {
+			return nil
+		}
+		b.add(v)
 	}
+	return nil
+}
 
-	return decodeMap(d, m)
+// unmarshalValue decodes any JSON value, with objects decoded as *Map.
+func unmarshalValue(data []byte) (Value, error) {
+	var b builder
+	err := Scan(data, b.event)
+	return b.value, err
+}
+
+func (b *builder) add(v Value) {
+	f := &b.stack[len(b.stack)-1]
+	if f.m != nil {
+		f.m.Push(b.key, v)
+	} else {
+		f.a = append(f.a, v)
+	}
 }
 
 func decodeMap(d *json.Decoder, m *Map) error {
@@ -105,3 +173,15 @@ func decodeArray(d *json.Decoder) ([]any, error) {
 		}
 	}
 }
+
+// decodeValue decodes the value starting with token tok, which is already read.
+func decodeValue(d *json.Decoder, tok json.Token) (Value, error) {
+	switch tok {
+	case json.Delim('{'):
+		m := New()
+		return m, decodeMap(d, m)
+	case json.Delim('['):
+		return decodeArray(d)
+	}
+	return tok, nil
+}
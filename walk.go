@@ -0,0 +1,198 @@
+package jsonmap
+
+// Action is returned by WalkFunc, to control walking and edit the visited element.
+type Action struct {
+	kind  actionKind
+	value Value
+	key   Key
+}
+
+type actionKind int
+
+const (
+	actionContinue actionKind = iota
+	actionSkip
+	actionStop
+	actionReplace
+	actionDelete
+	actionRename
+)
+
+var (
+	// Continue walking, including the subtree of the current value.
+	Continue = Action{kind: actionContinue}
+	// Skip the subtree of the current value.
+	Skip = Action{kind: actionSkip}
+	// Stop walking.
+	Stop = Action{kind: actionStop}
+	// Delete the current element from its map or array, and skip its subtree.
+	Delete = Action{kind: actionDelete}
+)
+
+// Replace the current value. The new value is not walked.
+func Replace(value Value) Action {
+	return Action{kind: actionReplace, value: value}
+}
+
+// Rename the key of the current map element, keeping its position.
+// If the new key already exists in the map, that element is removed.
+// The subtree of the value is walked after renaming. Ignored for array elements.
+func Rename(key Key) Action {
+	return Action{kind: actionRename, key: key}
+}
+
+// WalkFunc is called by Walk for each visited value.
+// Key is the map key of the value, or "" for array elements; last step of path is the key or array index.
+type WalkFunc func(path Path, key Key, value Value) Action
+
+// Walk visits all values in nested maps and arrays of m in document order, depth-first.
+// Each value is visited before its subtree. The map itself is not visited.
+//
+// The callback can edit the map in place, by returning Replace, Delete or Rename action.
+// Elements are safe to delete or rename while walking. Arrays with deleted elements are
+// replaced in their parent with new shorter arrays.
+//
+//	jsonmap.Walk(m, func(path jsonmap.Path, key jsonmap.Key, value jsonmap.Value) jsonmap.Action {
+//	    if value == nil {
+//	        return jsonmap.Delete
+//	    }
+//	    return jsonmap.Continue
+//	})
+func Walk(m *Map, fn WalkFunc) {
+	walkMap(m, Path{}, fn)
+}
+
+// Transform is same as Walk, but returns edited deep copy of m, and leaves m untouched.
+//
+//	m2 := jsonmap.Transform(m, func(path jsonmap.Path, key jsonmap.Key, value jsonmap.Value) jsonmap.Action {
+//	    if s, ok := value.(string); ok {
+//	        return jsonmap.Replace(strings.TrimSpace(s))
+//	    }
+//	    return jsonmap.Continue
+//	})
+func Transform(m *Map, fn WalkFunc) *Map {
+	m2 := m.Clone()
+	Walk(m2, fn)
+	return m2
+}
+
+// Clone returns a deep copy of the map. Nested maps and arrays are copied, other values are shared.
+// O(n) time and space, where n is number of values in the tree.
+//
+//	m2 := m.Clone()
+func (m *Map) Clone() *Map {
+	m2 := New()
+	for el := m.first; el != nil; el = el.next {
+		m2.Set(el.key, cloneValue(el.value))
+	}
+	return m2
+}
+
+func cloneValue(v Value) Value {
+	switch v := v.(type) {
+	case *Map:
+		return v.Clone()
+	case []any:
+		a := make([]any, len(v))
+		for i, item := range v {
+			a[i] = cloneValue(item)
+		}
+		return a
+	}
+	return v
+}
+
+// walkMap returns true if walking was stopped.
+func walkMap(m *Map, path Path, fn WalkFunc) bool {
+	for el := m.first; el != nil; {
+		next := el.next // element can be deleted or renamed
+		p := path.Append(el.key)
+		action := fn(p, el.key, el.value)
+		switch action.kind {
+		case actionStop:
+			return true
+		case actionReplace:
+			el.value = action.value
+		case actionDelete:
+			m.Delete(el.key)
+		case actionRename:
+			if next != nil && next.key == action.key {
+				next = next.next // replaced by the renamed element
+			}
+			m.rename(el, action.key)
+			p = append(p[:len(p)-1:len(p)-1], action.key) // p was passed to fn, and may be kept
+			fallthrough
+		case actionContinue:
+			value, stop := walkValue(el.value, p, fn)
+			el.value = value
+			if stop {
+				return true
+			}
+		}
+		el = next
+	}
+	return false
+}
+
+// walkArray returns the array with deleted elements removed, and true if walking was stopped.
+func walkArray(a []any, path Path, fn WalkFunc) ([]any, bool) {
+	var out []any // allocated on first deletion
+	deleted := false
+	for i := 0; i < len(a); i++ {
+		p := path.Append(i)
+		action := fn(p, "", a[i])
+		stop := false
+		switch action.kind {
+		case actionStop:
+			stop = true
+		case actionReplace:
+			a[i] = action.value
+		case actionDelete:
+			if !deleted {
+				deleted = true
+				out = append(make([]any, 0, len(a)-1), a[:i]...)
+			}
+			continue
+		case actionContinue, actionRename:
+			a[i], stop = walkValue(a[i], p, fn)
+		}
+		if deleted {
+			out = append(out, a[i])
+		}
+		if stop {
+			if deleted {
+				out = append(out, a[i+1:]...)
+				return out, true
+			}
+			return a, true
+		}
+	}
+	if deleted {
+		return out, false
+	}
+	return a, false
+}
+
+func walkValue(v Value, path Path, fn WalkFunc) (Value, bool) {
+	switch v := v.(type) {
+	case *Map:
+		return v, walkMap(v, path, fn)
+	case []any:
+		return walkArray(v, path, fn)
+	}
+	return v, false
+}
+
+// rename changes key of the element, keeping its position.
+// Existing element with the new key is removed.
+func (m *Map) rename(elem *Element, key Key) {
+	if elem.key == key {
+		return
+	}
+	if other, ok := m.elements[key]; ok {
+		m.Delete(other.key)
+	}
+	delete(m.elements, elem.key)
+	elem.key = key
+	m.elements[key] = elem
+}
@@ -0,0 +1,500 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// XMLConvention selects how XML elements, attributes and text are mapped to maps.
+type XMLConvention int
+
+const (
+	// XMLAttrText maps attributes to "@name" keys and text of elements with attributes or children to "#text" key.
+	// Elements with only text become strings. This is the default.
+	XMLAttrText XMLConvention = iota
+
+	// XMLBadgerFish maps every element to a map, with attributes in "@name" keys, text in "$" key,
+	// and namespace declarations in "@xmlns" map, with "$" key for default namespace.
+	XMLBadgerFish
+
+	// XMLParker drops attributes. Elements with only text become strings, and empty elements become nil.
+	// Text of elements with children is dropped too.
+	XMLParker
+)
+
+// XMLConverter converts between XML documents and maps, keeping order of elements.
+// Zero value is usable, and uses XMLAttrText convention without indentation.
+//
+// Root element is the only key of the map. Child elements become keys in order of their first appearance,
+// and repeated elements become arrays. Without KeepOrder, order of interleaved siblings with different names,
+// like <a/><b/><a/>, and position of text between child elements are not kept.
+// Text and attribute values are read as strings, and names are kept with their namespace prefixes.
+//
+//	c := jsonmap.XMLConverter{Convention: jsonmap.XMLBadgerFish, Indent: "  "}
+//	m, err := c.FromXML(r)
+//	data, err := c.ToXML(m)
+type XMLConverter struct {
+	// Convention of mapping. Default is XMLAttrText.
+	Convention XMLConvention
+
+	// Indent is written per nesting level by ToXML, if set. Otherwise output is written in a single line.
+	// Elements with text between children are written in a single line.
+	Indent string
+
+	// KeepOrder makes FromXML keep document order of elements, whose children can't be grouped by name
+	// without losing it: with interleaved siblings, or with text between or after children.
+	// Such elements get "#content" key with array of single-key maps for child elements,
+	// and strings for text, skipping whitespace-only text. ToXML writes "#content" key in any case.
+	KeepOrder bool
+}
+
+// xmlContentKey is key of children in document order, see XMLConverter.KeepOrder.
+const xmlContentKey = "#content"
+
+// ToXML returns XML document of the map with a single root key, in XMLAttrText convention.
+// Shortcut for XMLConverter{}.ToXML(m).
+//
+//	data, err := jsonmap.ToXML(m)
+func ToXML(m *Map) ([]byte, error) {
+	return XMLConverter{}.ToXML(m)
+}
+
+// FromXML reads XML document, and returns it as a map with a single root key, in XMLAttrText convention.
+// Shortcut for XMLConverter{}.FromXML(r).
+//
+//	m, err := jsonmap.FromXML(r)
+func FromXML(r io.Reader) (*Map, error) {
+	return XMLConverter{}.FromXML(r)
+}
+
+// textKey returns key of element text in the convention.
+func (c XMLConverter) textKey() Key {
+	if c.Convention == XMLBadgerFish {
+		return "$"
+	}
+	return "#text"
+}
+
+// FromXML reads XML document, and returns it as a map with a single root key.
+// Comments, processing instructions and directives are skipped.
+//
+//	m, err := c.FromXML(r)
+func (c XMLConverter) FromXML(r io.Reader) (*Map, error) {
+	d := xml.NewDecoder(r)
+	m := New()
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if m.Len() > 0 {
+				return nil, xmlSyntaxError(d, "multiple root elements")
+			}
+			v, err := c.element(d, tok, 0)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(xmlName(tok.Name), v)
+		case xml.EndElement:
+			return nil, xmlSyntaxError(d, fmt.Sprintf("unexpected end element </%s>", xmlName(tok.Name)))
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return nil, xmlSyntaxError(d, "text outside of root element")
+			}
+		}
+	}
+	if m.Len() == 0 {
+		return nil, xmlSyntaxError(d, "no root element")
+	}
+	return m, nil
+}
+
+func xmlSyntaxError(d *xml.Decoder, msg string) error {
+	line, _ := d.InputPos()
+	return &xml.SyntaxError{Msg: msg, Line: line}
+}
+
+// xmlName returns qualified name as written, like "soap:Body".
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// element reads content of the element till its end, and returns its value.
+// Depth is the number of its ancestors, limited to maxDepth.
+func (c XMLConverter) element(d *xml.Decoder, start xml.StartElement, depth int) (Value, error) {
+	if depth == maxDepth {
+		return nil, xmlSyntaxError(d, fmt.Sprintf("exceeded max depth %d", maxDepth))
+	}
+	start = start.Copy()
+	var text strings.Builder
+	children := New()
+
+	// children and text in document order, for KeepOrder
+	var content []any
+	var chunk strings.Builder // text after the last child
+	ordered := false          // grouping children by name loses their order
+	last := ""                // name of the last child
+	addChunk := func() {
+		if c.Convention != XMLParker && strings.TrimSpace(chunk.String()) != "" {
+			content = append(content, chunk.String())
+			ordered = ordered || children.Len() > 0
+		}
+		chunk.Reset()
+	}
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return nil, xmlSyntaxError(d, "unexpected EOF")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			addChunk()
+			v, err := c.element(d, tok, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			name := xmlName(tok.Name)
+			item := New()
+			item.Set(name, v)
+			content = append(content, item)
+			if old, ok := children.Get(name); ok {
+				ordered = ordered || name != last
+				if a, ok := old.([]any); ok {
+					children.Set(name, append(a, v))
+				} else {
+					children.Set(name, []any{old, v})
+				}
+			} else {
+				children.Set(name, v)
+			}
+			last = name
+		case xml.EndElement:
+			if tok.Name != start.Name {
+				return nil, xmlSyntaxError(d, fmt.Sprintf("element <%s> closed by </%s>", xmlName(start.Name), xmlName(tok.Name)))
+			}
+			addChunk()
+			if c.KeepOrder && ordered {
+				m := New()
+				if c.Convention != XMLParker {
+					c.attrs(m, start.Attr)
+				}
+				m.Set(xmlContentKey, content)
+				return m, nil
+			}
+			return c.value(start.Attr, children, text.String()), nil
+		case xml.CharData:
+			text.Write(tok)
+			chunk.Write(tok)
+		}
+	}
+}
+
+// value builds value of the element in the convention.
+func (c XMLConverter) value(attrs []xml.Attr, children *Map, text string) Value {
+	if children.Len() > 0 {
+		text = strings.TrimSpace(text)
+	}
+	switch c.Convention {
+	case XMLParker:
+		if children.Len() > 0 {
+			return children
+		}
+		if text == "" {
+			return nil
+		}
+		return text
+	case XMLAttrText:
+		if len(attrs) == 0 && children.Len() == 0 {
+			return text
+		}
+	}
+
+	m := New()
+	c.attrs(m, attrs)
+	if text != "" {
+		m.Set(c.textKey(), text)
+	}
+	for el := children.First(); el != nil; el = el.Next() {
+		m.Set(el.key, el.value)
+	}
+	return m
+}
+
+// attrs sets attributes of the element in m, in the convention.
+func (c XMLConverter) attrs(m *Map, attrs []xml.Attr) {
+	var ns *Map
+	for _, attr := range attrs {
+		switch {
+		case c.Convention == XMLBadgerFish && attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			ns = xmlNamespaces(m, ns)
+			ns.Set("$", attr.Value)
+		case c.Convention == XMLBadgerFish && attr.Name.Space == "xmlns":
+			ns = xmlNamespaces(m, ns)
+			ns.Set(attr.Name.Local, attr.Value)
+		default:
+			m.Set("@"+xmlName(attr.Name), attr.Value)
+		}
+	}
+}
+
+// xmlNamespaces returns "@xmlns" map of BadgerFish element, adding it on first use.
+func xmlNamespaces(m, ns *Map) *Map {
+	if ns == nil {
+		ns = New()
+		m.Set("@xmlns", ns)
+	}
+	return ns
+}
+
+// ToXML returns XML document of the map with a single root key.
+// XML declaration is not written, prepend xml.Header if needed.
+//
+// Maps are written as elements with attributes, text and children, arrays as repeated elements,
+// nil as empty elements, and other values as text, with numbers and booleans in JSON format.
+//
+//	data, err := c.ToXML(m)
+func (c XMLConverter) ToXML(m *Map) ([]byte, error) {
+	if m.Len() != 1 {
+		return nil, fmt.Errorf("jsonmap: xml: map must have a single root key, got %d keys", m.Len())
+	}
+	if _, ok := m.First().Value().([]any); ok {
+		return nil, fmt.Errorf("jsonmap: xml: root %q must not be an array", m.First().Key())
+	}
+	w := &xmlWriter{conv: c}
+	if err := w.element(m.First().Key(), m.First().Value(), 0); err != nil {
+		return nil, err
+	}
+	if c.Indent != "" {
+		w.buf = append(w.buf, '\n')
+	}
+	return w.buf, nil
+}
+
+type xmlWriter struct {
+	buf  []byte
+	conv XMLConverter
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;",
+		"\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// newline starts a new indented line. Negative depth means no indentation, inside mixed content.
+func (w *xmlWriter) newline(depth int) {
+	if w.conv.Indent != "" && depth >= 0 && len(w.buf) > 0 {
+		w.buf = append(w.buf, '\n')
+		w.buf = append(w.buf, strings.Repeat(w.conv.Indent, depth)...)
+	}
+}
+
+func (w *xmlWriter) element(name string, v Value, depth int) error {
+	v, err := normalizeValue(v)
+	if err != nil {
+		return err
+	}
+	if a, ok := v.([]any); ok {
+		for _, item := range a {
+			if _, ok := item.([]any); ok {
+				return fmt.Errorf("jsonmap: xml: element %q: nested arrays are not supported", name)
+			}
+			if err := w.element(name, item, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !xmlValidName(name) {
+		return fmt.Errorf("jsonmap: xml: invalid element name %q", name)
+	}
+
+	w.newline(depth)
+	w.buf = append(w.buf, '<')
+	w.buf = append(w.buf, name...)
+	m, ok := v.(*Map)
+	if !ok {
+		if v == nil {
+			w.buf = append(w.buf, "/>"...)
+			return nil
+		}
+		text, err := xmlText(v)
+		if err != nil {
+			return err
+		}
+		w.buf = append(w.buf, '>')
+		if err := w.text(text, xmlTextEscaper); err != nil {
+			return err
+		}
+		w.buf = append(w.buf, "</"+name+">"...)
+		return nil
+	}
+
+	var text string
+	var children []*Element
+	var content []any
+	for el := m.First(); el != nil; el = el.Next() {
+		switch {
+		case el.key == xmlContentKey:
+			if content, ok = el.value.([]any); !ok {
+				return fmt.Errorf("jsonmap: xml: element %q: %s must be an array", name, xmlContentKey)
+			}
+		case w.conv.Convention == XMLBadgerFish && el.key == "@xmlns":
+			ns, ok := el.value.(*Map)
+			if !ok {
+				return fmt.Errorf("jsonmap: xml: element %q: @xmlns must be a map", name)
+			}
+			for nsEl := ns.First(); nsEl != nil; nsEl = nsEl.Next() {
+				attr := "xmlns"
+				if nsEl.key != "$" {
+					attr += ":" + nsEl.key
+				}
+				if err := w.attr(attr, nsEl.value); err != nil {
+					return err
+				}
+			}
+		case w.conv.Convention != XMLParker && strings.HasPrefix(el.key, "@"):
+			if err := w.attr(el.key[1:], el.value); err != nil {
+				return err
+			}
+		case w.conv.Convention != XMLParker && el.key == w.conv.textKey():
+			v, err := normalizeValue(el.value)
+			if err != nil {
+				return err
+			}
+			if text, err = xmlText(v); err != nil {
+				return err
+			}
+		default:
+			children = append(children, el)
+		}
+	}
+	if text == "" && len(children) == 0 && len(content) == 0 {
+		w.buf = append(w.buf, "/>"...)
+		return nil
+	}
+	w.buf = append(w.buf, '>')
+	if err := w.text(text, xmlTextEscaper); err != nil {
+		return err
+	}
+	inner := depth + 1
+	for _, item := range content {
+		if _, ok := item.(string); ok {
+			inner = -1 // indentation would change the text
+		}
+	}
+	if depth < 0 {
+		inner = -1
+	}
+	for _, el := range children {
+		if err := w.element(el.key, el.value, inner); err != nil {
+			return err
+		}
+	}
+	for _, item := range content {
+		switch item := item.(type) {
+		case string:
+			if err := w.text(item, xmlTextEscaper); err != nil {
+				return err
+			}
+		case *Map:
+			if item.Len() != 1 {
+				return fmt.Errorf("jsonmap: xml: element %q: items of %s must have a single key", name, xmlContentKey)
+			}
+			if err := w.element(item.First().Key(), item.First().Value(), inner); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("jsonmap: xml: element %q: %s can't have %T items", name, xmlContentKey, item)
+		}
+	}
+	if len(children)+len(content) > 0 {
+		w.newline(inner - 1)
+	}
+	w.buf = append(w.buf, "</"+name+">"...)
+	return nil
+}
+
+// text writes escaped text, checking that it has only characters allowed in XML 1.0.
+func (w *xmlWriter) text(s string, escaper *strings.Replacer) error {
+	if err := xmlCheckText("xml", s); err != nil {
+		return err
+	}
+	w.buf = append(w.buf, escaper.Replace(s)...)
+	return nil
+}
+
+// xmlCheckText returns error, if s has characters not allowed in XML 1.0, which can't be escaped either.
+func xmlCheckText(format, s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("jsonmap: %s: invalid UTF-8 in %q", format, s)
+	}
+	for _, r := range s {
+		if !(r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r <= 0xD7FF || r >= 0xE000 && r <= 0xFFFD || r >= 0x10000) {
+			return fmt.Errorf("jsonmap: %s: character %U is not allowed in XML", format, r)
+		}
+	}
+	return nil
+}
+
+func (w *xmlWriter) attr(name string, v Value) error {
+	if !xmlValidName(name) {
+		return fmt.Errorf("jsonmap: xml: invalid attribute name %q", name)
+	}
+	v, err := normalizeValue(v)
+	if err != nil {
+		return err
+	}
+	text, err := xmlText(v)
+	if err != nil {
+		return err
+	}
+	w.buf = append(w.buf, ' ')
+	w.buf = append(w.buf, name...)
+	w.buf = append(w.buf, `="`...)
+	if err := w.text(text, xmlAttrEscaper); err != nil {
+		return err
+	}
+	w.buf = append(w.buf, '"')
+	return nil
+}
+
+// xmlText formats scalar value as text.
+func xmlText(v Value) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case *Map, []any:
+		return "", fmt.Errorf("jsonmap: xml: %T can't be written as text", v)
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func xmlValidName(name string) bool {
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || r == ':' || i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.')) {
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,1152 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// YAMLEmitter writes maps as YAML 1.2, keeping order of keys.
+// Zero value is usable, and writes block style with 2 spaces indentation.
+//
+// Strings are written plain when possible, and quoted when they would be read as another type,
+// including YAML 1.1 booleans like "yes" and "off", or contain special characters.
+// Multi-line strings are written as literal block scalars.
+//
+//	data, err := jsonmap.YAMLEmitter{Indent: 4, FlowLevel: 2}.Marshal(m)
+type YAMLEmitter struct {
+	// Indent is number of spaces per nesting level. Default is 2.
+	Indent int
+
+	// FlowLevel makes maps and arrays nested at this level and deeper written in flow style, like {a: 1, b: [1, 2]}.
+	// Values of the top-level map are at level 1. Zero (default) writes block style at all levels.
+	FlowLevel int
+}
+
+// MarshalYAML returns YAML encoding of the map in block style.
+// Shortcut for YAMLEmitter{}.Marshal(m).
+//
+//	data, err := jsonmap.MarshalYAML(m)
+func MarshalYAML(m *Map) ([]byte, error) {
+	return YAMLEmitter{}.Marshal(m)
+}
+
+// Marshal returns YAML encoding of the map.
+//
+//	data, err := e.Marshal(m)
+func (e YAMLEmitter) Marshal(m *Map) ([]byte, error) {
+	w := &yamlWriter{indent: e.Indent, flowLevel: e.FlowLevel}
+	if w.indent <= 0 {
+		w.indent = 2
+	}
+	if m.Len() == 0 {
+		return []byte("{}\n"), nil
+	}
+	if err := w.mapping(m, 0, 0, false); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+type yamlWriter struct {
+	buf       []byte
+	indent    int
+	flowLevel int
+}
+
+func (w *yamlWriter) flow(depth int) bool {
+	return w.flowLevel > 0 && depth >= w.flowLevel
+}
+
+func (w *yamlWriter) spaces(n int) {
+	for i := 0; i < n; i++ {
+		w.buf = append(w.buf, ' ')
+	}
+}
+
+// mapping writes block mapping indented by ind spaces.
+// With inline set, the first key continues the current line, like after "- ".
+func (w *yamlWriter) mapping(m *Map, ind, depth int, inline bool) error {
+	for el := m.First(); el != nil; el = el.Next() {
+		if !inline || el != m.First() {
+			w.spaces(ind)
+		}
+		w.buf = append(w.buf, yamlString(el.key, false)...)
+		w.buf = append(w.buf, ':')
+		if err := w.value(el.value, ind, depth+1, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sequence writes block sequence indented by ind spaces.
+func (w *yamlWriter) sequence(a []any, ind, depth int, inline bool) error {
+	for i, item := range a {
+		if !inline || i > 0 {
+			w.spaces(ind)
+		}
+		w.buf = append(w.buf, '-')
+		if err := w.value(item, ind, depth+1, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value writes the value after "key:" or "-", and ends the line.
+// Collections after "-" continue the line, and after "key:" start on the next line.
+func (w *yamlWriter) value(v Value, ind, depth int, dash bool) error {
+	switch v := v.(type) {
+	case *Map:
+		if v.Len() > 0 && !w.flow(depth) {
+			if dash {
+				w.buf = append(w.buf, ' ')
+				return w.mapping(v, ind+2, depth, true)
+			}
+			w.buf = append(w.buf, '\n')
+			return w.mapping(v, ind+w.indent, depth, false)
+		}
+	case []any:
+		if len(v) > 0 && !w.flow(depth) {
+			if dash {
+				w.buf = append(w.buf, ' ')
+				return w.sequence(v, ind+2, depth, true)
+			}
+			w.buf = append(w.buf, '\n')
+			return w.sequence(v, ind+w.indent, depth, false)
+		}
+	case string:
+		if yamlLiteral(v) {
+			w.literal(v, ind+w.indent)
+			return nil
+		}
+	}
+	w.buf = append(w.buf, ' ')
+	if err := w.flowValue(v); err != nil {
+		return err
+	}
+	w.buf = append(w.buf, '\n')
+	return nil
+}
+
+// literal writes multi-line string as literal block scalar, with content indented by ind spaces.
+func (w *yamlWriter) literal(s string, ind int) {
+	body, chomp := s, "-"
+	if strings.HasSuffix(s, "\n") {
+		body, chomp = s[:len(s)-1], ""
+		if strings.HasSuffix(body, "\n") {
+			chomp = "+"
+		}
+	}
+	indicator := ""
+	if strings.TrimLeft(body, "\n")[0] == ' ' {
+		// indentation can't be detected from the first line
+		indicator = strconv.Itoa(w.indent)
+	}
+	w.buf = append(w.buf, " |"+indicator+chomp+"\n"...)
+	for _, line := range strings.Split(body, "\n") {
+		if line != "" {
+			w.spaces(ind)
+			w.buf = append(w.buf, line...)
+		}
+		w.buf = append(w.buf, '\n')
+	}
+}
+
+// flowValue writes the value in flow style.
+func (w *yamlWriter) flowValue(v Value) error {
+	switch v := v.(type) {
+	case *Map:
+		w.buf = append(w.buf, '{')
+		for el := v.First(); el != nil; el = el.Next() {
+			if el != v.First() {
+				w.buf = append(w.buf, ", "...)
+			}
+			w.buf = append(w.buf, yamlString(el.key, true)...)
+			w.buf = append(w.buf, ": "...)
+			if err := w.flowValue(el.value); err != nil {
+				return err
+			}
+		}
+		w.buf = append(w.buf, '}')
+	case []any:
+		w.buf = append(w.buf, '[')
+		for i, item := range v {
+			if i > 0 {
+				w.buf = append(w.buf, ", "...)
+			}
+			if err := w.flowValue(item); err != nil {
+				return err
+			}
+		}
+		w.buf = append(w.buf, ']')
+	case string:
+		w.buf = append(w.buf, yamlString(v, true)...)
+	case nil:
+		w.buf = append(w.buf, "null"...)
+	case float64:
+		w.buf = append(w.buf, yamlNumber(v)...)
+	case float32:
+		w.buf = append(w.buf, yamlNumber(float64(v))...)
+	default:
+		// JSON is valid flow YAML
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.buf = append(w.buf, data...)
+	}
+	return nil
+}
+
+func yamlNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return ".nan"
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	}
+	data, _ := json.Marshal(f)
+	return string(data)
+}
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOct   = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHex   = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+	// YAML 1.1 numbers with underscores, sexagesimal numbers and binary numbers, quoted for older parsers
+	yaml11Number = regexp.MustCompile(`^[-+]?([0-9][0-9_]*(:[0-5]?[0-9])*(\.[0-9_]*)?|0b[01_]+)$`)
+	yamlDate     = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt ].*)?$`)
+)
+
+// yamlResolve returns value of plain scalar, by YAML 1.2 core schema.
+func yamlResolve(s string) Value {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	switch {
+	case yamlInt.MatchString(s), yamlFloat.MatchString(s):
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case yamlOct.MatchString(s):
+		if n, err := strconv.ParseUint(s[2:], 8, 64); err == nil {
+			return float64(n)
+		}
+	case yamlHex.MatchString(s):
+		if n, err := strconv.ParseUint(s[2:], 16, 64); err == nil {
+			return float64(n)
+		}
+	}
+	return s
+}
+
+// yamlPlain reports whether string can be written as plain scalar.
+func yamlPlain(s string, flow bool) bool {
+	if s == "" || s != strings.TrimSpace(s) || strings.IndexByte("-?:,[]{}#&*!|>'\"%@`", s[0]) >= 0 {
+		return false
+	}
+	if _, ok := yamlResolve(s).(string); !ok || yaml11Number.MatchString(s) || yamlDate.MatchString(s) {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "y", "yes", "n", "no", "on", "off", "<<":
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") || strings.HasPrefix(s, "...") {
+		return false
+	}
+	if flow && strings.ContainsAny(s, ",[]{}") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) || r == utf8.RuneError {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlLiteral reports whether string should be written as literal block scalar.
+func yamlLiteral(s string) bool {
+	if !strings.Contains(s, "\n") || strings.TrimSpace(s) == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '\n' && r != '\t' && (!unicode.IsPrint(r) || r == utf8.RuneError) {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlString returns string as plain, single-quoted or double-quoted scalar.
+func yamlString(s string, flow bool) string {
+	if yamlPlain(s, flow) {
+		return s
+	}
+	printable := true
+	for _, r := range s {
+		if !unicode.IsPrint(r) || r == utf8.RuneError {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			switch {
+			case unicode.IsPrint(r) && r != utf8.RuneError || r == ' ':
+				b.WriteRune(r)
+			case r < 0x100:
+				fmt.Fprintf(&b, `\x%02X`, r)
+			case r < 0x10000:
+				fmt.Fprintf(&b, `\u%04X`, r)
+			default:
+				fmt.Fprintf(&b, `\U%08X`, r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// YAMLError is a syntax error in YAML input, or YAML feature not supported by UnmarshalYAML.
+type YAMLError struct {
+	Line   int // 1-based
+	Column int // 1-based, in bytes
+	Msg    string
+}
+
+func (e *YAMLError) Error() string {
+	return fmt.Sprintf("yaml: line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// UnmarshalYAML parses YAML document with a mapping at the top level, and stores it in m, keeping order of keys.
+// Nested mappings are decoded as *Map, sequences as []any, and numbers as float64, same as UnmarshalJSON does.
+// Scalars are resolved by YAML 1.2 core schema.
+//
+// It supports the common subset of YAML 1.2: block mappings and sequences, flow collections,
+// plain, quoted and block scalars, anchors and aliases, merge keys "<<", and comments, which are ignored.
+// Tags other than !!str are ignored. Multiple documents, explicit "?" keys and non-scalar keys are not supported.
+// Aliases are decoded as copies of anchored values. To stop exponential expansion of nested aliases,
+// they can copy at most as many values in total as there are bytes in data, plus 65536.
+//
+// Note: same as UnmarshalJSON, it does not clear the map before unmarshaling.
+//
+//	err := jsonmap.UnmarshalYAML(data, m)
+func UnmarshalYAML(data []byte, m *Map) error {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+	p := &yamlParser{data: data, line: 1, anchors: map[string]Value{}, budget: len(data) + 1<<16}
+	v, err := p.document()
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case nil: // empty document
+	case *Map:
+		for el := v.First(); el != nil; el = el.Next() {
+			m.Push(el.key, el.value)
+		}
+	default:
+		return &YAMLError{Line: 1, Column: 1, Msg: "expected mapping at the top level"}
+	}
+	return nil
+}
+
+type yamlParser struct {
+	data      []byte
+	pos       int
+	line      int
+	lineStart int
+	anchors   map[string]Value
+	budget    int  // number of values left for aliases to copy
+	depth     int  // nesting of flow collections
+	str       bool // !!str tag for the next scalar
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	return &YAMLError{Line: p.line, Column: p.pos - p.lineStart + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *yamlParser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *yamlParser) peek() byte {
+	return p.at(p.pos)
+}
+
+func (p *yamlParser) at(i int) byte {
+	if i >= len(p.data) {
+		return 0
+	}
+	return p.data[i]
+}
+
+// blank reports whether byte at i is whitespace, line end or end of input.
+func (p *yamlParser) blank(i int) bool {
+	c := p.at(i)
+	return c == 0 || c == ' ' || c == '\t' || c == '\n'
+}
+
+func (p *yamlParser) col() int {
+	return p.pos - p.lineStart
+}
+
+func (p *yamlParser) newline() {
+	p.pos++
+	p.line++
+	p.lineStart = p.pos
+}
+
+func (p *yamlParser) skipInline() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.pos++
+	}
+}
+
+// atLineEnd skips whitespace, and reports whether the rest of the line is empty or a comment.
+func (p *yamlParser) atLineEnd() bool {
+	p.skipInline()
+	c := p.peek()
+	return c == 0 || c == '\n' || c == '#'
+}
+
+// endLine skips the rest of the line, which must be empty or a comment.
+func (p *yamlParser) endLine() error {
+	if !p.atLineEnd() {
+		return p.errorf("unexpected %q", p.peek())
+	}
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+	if !p.eof() {
+		p.newline()
+	}
+	return nil
+}
+
+// skipBlank skips whitespace, comments and empty lines, up to the next content.
+func (p *yamlParser) skipBlank() {
+	for {
+		if !p.atLineEnd() || p.eof() {
+			return
+		}
+		p.endLine()
+	}
+}
+
+// marker reports whether document marker "---" or "..." is at current position.
+func (p *yamlParser) marker(m string) bool {
+	return p.col() == 0 && bytes.HasPrefix(p.data[p.pos:], []byte(m)) && p.blank(p.pos+3)
+}
+
+// seqEntry reports whether block sequence entry "- " is at current position.
+func (p *yamlParser) seqEntry() bool {
+	return p.peek() == '-' && p.blank(p.pos+1)
+}
+
+func (p *yamlParser) document() (Value, error) {
+	for {
+		p.skipBlank()
+		if p.col() != 0 || p.peek() != '%' {
+			break
+		}
+		p.pos++ // directive
+		for !p.atLineEnd() {
+			p.pos++
+		}
+	}
+	var v Value
+	var err error
+	if p.marker("---") {
+		p.pos += 3
+		v, err = p.node(-1, false, false)
+	} else {
+		v, err = p.block(-1, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.marker("...") {
+		p.pos += 3
+		if err := p.endLine(); err != nil {
+			return nil, err
+		}
+		p.skipBlank()
+	}
+	if p.marker("---") {
+		return nil, p.errorf("multiple documents are not supported")
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return v, nil
+}
+
+// node parses a node, which starts on the current line, after "key:", "-" or "---", or on the following lines.
+// Parent is indentation of the parent collection. With indentless set, sequence can be at parent's indentation.
+// With compact set, a collection can start on the current line, like "- a: 1".
+func (p *yamlParser) node(parent int, indentless, compact bool) (Value, error) {
+	anchor, err := p.properties()
+	if err != nil {
+		return nil, err
+	}
+	var v Value
+	switch {
+	case p.atLineEnd():
+		v, err = p.block(parent, indentless)
+	case compact && p.seqEntry():
+		v, err = p.sequence(p.col())
+	case compact && p.mappingKey():
+		v, err = p.mapping(p.col())
+	default:
+		v, err = p.inline(parent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = v
+	}
+	return v, nil
+}
+
+// properties reads anchor and tag of a node.
+func (p *yamlParser) properties() (anchor string, err error) {
+	p.str = false
+	for {
+		p.skipInline()
+		switch p.peek() {
+		case '&':
+			p.pos++
+			if anchor = p.name(); anchor == "" {
+				return "", p.errorf("expected anchor name")
+			}
+		case '!':
+			tag := p.name()
+			p.str = tag == "!!str" || tag == "!"
+		default:
+			return anchor, nil
+		}
+	}
+}
+
+// name reads anchor, alias or tag name.
+func (p *yamlParser) name() string {
+	start := p.pos
+	for !p.blank(p.pos) && strings.IndexByte(",[]{}", p.peek()) < 0 {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// block parses a node on the following lines, more indented than parent.
+func (p *yamlParser) block(parent int, indentless bool) (Value, error) {
+	p.skipBlank()
+	c := p.col()
+	switch {
+	case p.eof() || p.marker("---") || p.marker("..."):
+		return nil, nil
+	case c == parent && indentless && p.seqEntry():
+		return p.sequence(c)
+	case c <= parent:
+		return nil, nil
+	case p.seqEntry():
+		return p.sequence(c)
+	case p.mappingKey():
+		return p.mapping(c)
+	}
+	return p.inline(parent)
+}
+
+// inline parses a scalar, alias or flow collection, and skips the rest of the line.
+func (p *yamlParser) inline(parent int) (v Value, err error) {
+	switch c := p.peek(); c {
+	case '|', '>':
+		return p.blockScalar(parent)
+	case '*':
+		v, err = p.alias()
+	case '[', '{':
+		v, err = p.flow()
+	case '"', '\'':
+		v, err = p.quoted()
+	default:
+		if c == '-' && p.seqEntry() || c == '?' && p.blank(p.pos+1) {
+			return nil, p.errorf("unexpected %q", c)
+		}
+		v = p.scalar(p.plain(parent, false))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, p.endLine()
+}
+
+// scalar resolves plain scalar, unless it is tagged as string.
+func (p *yamlParser) scalar(s string) Value {
+	if p.str {
+		return s
+	}
+	return yamlResolve(s)
+}
+
+func (p *yamlParser) alias() (Value, error) {
+	p.pos++
+	name := p.name()
+	v, ok := p.anchors[name]
+	if !ok {
+		return nil, p.errorf("unknown alias %q", name)
+	}
+	if !p.spend(v) {
+		return nil, p.errorf("aliases expand into too many values")
+	}
+	return cloneValue(v), nil
+}
+
+// spend takes number of values in v from the alias budget, and reports whether the budget is not exceeded.
+func (p *yamlParser) spend(v Value) bool {
+	if p.budget--; p.budget < 0 {
+		return false
+	}
+	switch v := v.(type) {
+	case *Map:
+		for el := v.First(); el != nil; el = el.Next() {
+			if !p.spend(el.value) {
+				return false
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if !p.spend(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// mappingKey reports whether the current line starts with a mapping key, like "key:" or "'key':".
+func (p *yamlParser) mappingKey() bool {
+	i := p.pos
+	switch p.at(i) {
+	case '"', '\'':
+		q := p.at(i)
+		for i++; i < len(p.data) && p.data[i] != '\n'; i++ {
+			if p.data[i] == '\\' && q == '"' {
+				i++
+			} else if p.data[i] == q {
+				if q == '\'' && p.at(i+1) == '\'' {
+					i++
+					continue
+				}
+				i++
+				break
+			}
+		}
+		for p.at(i) == ' ' || p.at(i) == '\t' {
+			i++
+		}
+		return p.at(i) == ':' && p.blank(i+1)
+	case '[', '{', '#', '|', '>', '*', '&', '!', '%', '@', '`':
+		return false
+	}
+	for ; i < len(p.data) && p.data[i] != '\n'; i++ {
+		if p.data[i] == ':' && p.blank(i+1) {
+			return true
+		}
+		if p.data[i] == '#' && (p.data[i-1] == ' ' || p.data[i-1] == '\t') {
+			return false
+		}
+	}
+	return false
+}
+
+// key reads mapping key and ':' after it.
+func (p *yamlParser) key() (key string, plain bool, err error) {
+	switch p.peek() {
+	case '"', '\'':
+		key, err = p.quoted()
+		if err != nil {
+			return "", false, err
+		}
+	case '?':
+		return "", false, p.errorf("explicit keys are not supported")
+	default:
+		start := p.pos
+		for !(p.peek() == ':' && p.blank(p.pos+1)) {
+			if p.eof() || p.peek() == '\n' {
+				return "", false, p.errorf("expected ':' after mapping key")
+			}
+			p.pos++
+		}
+		key = strings.TrimRight(string(p.data[start:p.pos]), " \t")
+		plain = true
+	}
+	p.skipInline()
+	if p.peek() != ':' {
+		return "", false, p.errorf("expected ':' after mapping key")
+	}
+	p.pos++
+	return key, plain, nil
+}
+
+// mapping parses block mapping with keys at column c.
+func (p *yamlParser) mapping(c int) (*Map, error) {
+	m := New()
+	keys := map[Key]bool{} // merged keys can be overridden
+	for {
+		line, col := p.line, p.col()
+		key, plain, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		merge := plain && key == "<<"
+		if keys[key] && !merge {
+			return nil, &YAMLError{Line: line, Column: col + 1, Msg: fmt.Sprintf("duplicate key %q", key)}
+		}
+		v, err := p.node(c, true, false)
+		if err != nil {
+			return nil, err
+		}
+		if merge {
+			if err := yamlMerge(m, v); err != nil {
+				return nil, &YAMLError{Line: line, Column: col + 1, Msg: err.Error()}
+			}
+		} else {
+			keys[key] = true
+			m.Set(key, v)
+		}
+
+		p.skipBlank()
+		switch {
+		case p.eof() || p.marker("---") || p.marker("...") || p.col() < c:
+			return m, nil
+		case p.col() > c:
+			return nil, p.errorf("bad indentation of a mapping entry")
+		}
+	}
+}
+
+// yamlMerge merges map, or sequence of maps, into m, without overriding existing keys.
+func yamlMerge(m *Map, v Value) error {
+	switch v := v.(type) {
+	case *Map:
+		for el := v.First(); el != nil; el = el.Next() {
+			if _, ok := m.Get(el.key); !ok {
+				m.Set(el.key, el.value)
+			}
+		}
+		return nil
+	case []any:
+		for _, item := range v {
+			if _, ok := item.(*Map); !ok {
+				return fmt.Errorf("merge key expects mappings")
+			}
+			yamlMerge(m, item)
+		}
+		return nil
+	}
+	return fmt.Errorf("merge key expects a mapping or a sequence of mappings")
+}
+
+// sequence parses block sequence with entries at column c.
+func (p *yamlParser) sequence(c int) ([]any, error) {
+	a := make([]any, 0)
+	for {
+		p.pos++ // '-'
+		v, err := p.node(c, false, true)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+
+		p.skipBlank()
+		switch {
+		case p.eof() || p.marker("---") || p.marker("...") || p.col() < c:
+			return a, nil
+		case p.col() > c:
+			return nil, p.errorf("bad indentation of a sequence entry")
+		case !p.seqEntry():
+			return a, nil // indentless sequence ends at the next key
+		}
+	}
+}
+
+// plain reads plain scalar, which can continue on following lines, more indented than parent.
+func (p *yamlParser) plain(parent int, flow bool) string {
+	var b strings.Builder
+	for {
+		start, end := p.pos, p.pos
+		for !p.eof() {
+			c := p.peek()
+			if c == '\n' || c == ':' && (p.blank(p.pos+1) || flow && strings.IndexByte(",[]{}", p.at(p.pos+1)) >= 0) ||
+				c == '#' && p.pos > start && (p.data[p.pos-1] == ' ' || p.data[p.pos-1] == '\t') ||
+				flow && strings.IndexByte(",[]{}", c) >= 0 {
+				break
+			}
+			p.pos++
+			if c != ' ' && c != '\t' {
+				end = p.pos
+			}
+		}
+		b.Write(p.data[start:end])
+		p.pos = end
+
+		// continuation lines
+		pos, line, lineStart := p.pos, p.line, p.lineStart
+		p.skipInline()
+		breaks := 0
+		for p.peek() == '\n' {
+			p.newline()
+			p.skipInline()
+			breaks++
+		}
+		if breaks == 0 || p.eof() || p.peek() == '#' || p.marker("---") || p.marker("...") ||
+			!flow && p.col() <= parent || flow && strings.IndexByte(",[]{}:", p.peek()) >= 0 {
+			p.pos, p.line, p.lineStart = pos, line, lineStart
+			return b.String()
+		}
+		if breaks == 1 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteString(strings.Repeat("\n", breaks-1))
+		}
+	}
+}
+
+// quoted reads single-quoted or double-quoted scalar, which can span multiple lines.
+func (p *yamlParser) quoted() (string, error) {
+	q := p.peek()
+	p.pos++
+	var b []byte
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated quoted string")
+		}
+		c := p.peek()
+		switch {
+		case c == q && q == '\'' && p.at(p.pos+1) == '\'':
+			b = append(b, '\'')
+			p.pos += 2
+		case c == q:
+			p.pos++
+			return string(b), nil
+		case c == '\\' && q == '"':
+			p.pos++
+			if p.peek() == '\n' { // escaped line break
+				p.newline()
+				p.skipInline()
+				continue
+			}
+			r, err := p.escape()
+			if err != nil {
+				return "", err
+			}
+			b = utf8.AppendRune(b, r)
+		case c == ' ' || c == '\t' || c == '\n':
+			// line folding, trailing whitespace of lines is removed
+			start := p.pos
+			p.skipInline()
+			if p.peek() != '\n' {
+				b = append(b, p.data[start:p.pos]...)
+				continue
+			}
+			breaks := 0
+			for p.peek() == '\n' {
+				p.newline()
+				p.skipInline()
+				breaks++
+			}
+			if breaks == 1 {
+				b = append(b, ' ')
+			} else {
+				b = append(b, strings.Repeat("\n", breaks-1)...)
+			}
+		default:
+			b = append(b, c)
+			p.pos++
+		}
+	}
+}
+
+var yamlEscapes = map[byte]rune{
+	'0': 0, 'a': '\a', 'b': '\b', 't': '\t', '\t': '\t', 'n': '\n', 'v': '\v', 'f': '\f', 'r': '\r', 'e': 0x1B,
+	' ': ' ', '"': '"', '/': '/', '\\': '\\', 'N': 0x85, '_': 0xA0, 'L': 0x2028, 'P': 0x2029,
+}
+
+// escape reads escape sequence of double-quoted scalar after backslash.
+func (p *yamlParser) escape() (rune, error) {
+	c := p.peek()
+	if r, ok := yamlEscapes[c]; ok {
+		p.pos++
+		return r, nil
+	}
+	n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	if n == 0 || p.pos+1+n > len(p.data) {
+		return 0, p.errorf("invalid escape %q", c)
+	}
+	r, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+1+n]), 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid escape %q", p.data[p.pos-1:p.pos+1+n])
+	}
+	p.pos += 1 + n
+	return rune(r), nil
+}
+
+// blockScalar reads literal "|" or folded ">" block scalar.
+func (p *yamlParser) blockScalar(parent int) (string, error) {
+	literal := p.peek() == '|'
+	p.pos++
+	chomp, indicator := byte(0), 0
+	for i := 0; i < 2; i++ {
+		switch c := p.peek(); {
+		case c == '+' || c == '-':
+			chomp = c
+			p.pos++
+		case c >= '1' && c <= '9':
+			indicator = int(c - '0')
+			p.pos++
+		}
+	}
+	if err := p.endLine(); err != nil {
+		return "", err
+	}
+	if parent < 0 {
+		parent = 0
+	}
+
+	// content indentation, from the indicator or the first non-empty line
+	indent := parent + indicator
+	if indicator == 0 {
+		indent = 0
+		for i := p.pos; i < len(p.data); i++ {
+			if p.data[i] == '\n' {
+				indent = 0
+				continue
+			}
+			if p.data[i] != ' ' {
+				break
+			}
+			indent++
+		}
+	}
+
+	var lines []string
+	var more []bool // more-indented lines, not folded
+	for !p.eof() {
+		spaces := 0
+		for spaces < indent && p.at(p.pos+spaces) == ' ' {
+			spaces++
+		}
+		end := bytes.IndexByte(p.data[p.pos:], '\n')
+		if end < 0 {
+			end = len(p.data) - p.pos
+		}
+		text := p.data[p.pos+spaces : p.pos+end]
+		if spaces < indent || indent <= parent && indicator == 0 {
+			if len(bytes.Trim(text, " ")) > 0 {
+				break // less indented content ends the scalar
+			}
+			text = nil
+		}
+		lines = append(lines, string(text))
+		more = append(more, len(text) > 0 && (text[0] == ' ' || text[0] == '\t'))
+		p.pos += end
+		if !p.eof() {
+			p.newline()
+		}
+	}
+
+	body := len(lines)
+	for body > 0 && lines[body-1] == "" {
+		body--
+	}
+	var b strings.Builder
+	breaks := 0
+	for i, line := range lines[:body] {
+		switch {
+		case line == "":
+			breaks++
+			continue
+		case i == breaks: // leading empty lines
+			b.WriteString(strings.Repeat("\n", breaks))
+		case literal || more[i] || more[i-breaks-1]:
+			b.WriteString(strings.Repeat("\n", breaks+1))
+		case breaks > 0:
+			b.WriteString(strings.Repeat("\n", breaks))
+		default:
+			b.WriteByte(' ')
+		}
+		breaks = 0
+		b.WriteString(line)
+	}
+	switch {
+	case chomp == '-' || body == 0 && chomp != '+':
+	case chomp == '+':
+		b.WriteString(strings.Repeat("\n", len(lines)-body+1))
+		if body == 0 {
+			return strings.Repeat("\n", len(lines)), nil
+		}
+	default:
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// skipFlow skips whitespace, line breaks and comments inside flow collection.
+func (p *yamlParser) skipFlow() {
+	for {
+		p.skipInline()
+		switch p.peek() {
+		case '\n':
+			p.newline()
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// flow parses flow sequence [...] or flow mapping {...}.
+func (p *yamlParser) flow() (Value, error) {
+	if p.depth == maxDepth {
+		return nil, p.errorf("exceeded max depth %d", maxDepth)
+	}
+	p.depth++
+	open := p.peek()
+	p.pos++
+	var m *Map
+	a := make([]any, 0)
+	closer := byte(']')
+	if open == '{' {
+		m = New()
+		closer = '}'
+	}
+	for {
+		p.skipFlow()
+		if p.peek() == closer {
+			p.pos++
+			p.depth--
+			if m != nil {
+				return m, nil
+			}
+			return a, nil
+		}
+		if m == nil {
+			v, err := p.flowNode()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		} else {
+			var key string
+			var err error
+			if c := p.peek(); c == '"' || c == '\'' {
+				key, err = p.quoted()
+			} else if c == '[' || c == '{' || c == '?' {
+				err = p.errorf("only scalar keys are supported")
+			} else {
+				key = p.plain(-1, true)
+			}
+			if err != nil {
+				return nil, err
+			}
+			p.skipFlow()
+			var v Value
+			if p.peek() == ':' {
+				p.pos++
+				if v, err = p.flowNode(); err != nil {
+					return nil, err
+				}
+			}
+			if _, ok := m.Get(key); ok {
+				return nil, p.errorf("duplicate key %q", key)
+			}
+			m.Set(key, v)
+		}
+		p.skipFlow()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case closer:
+		default:
+			if p.eof() {
+				return nil, p.errorf("unterminated flow collection")
+			}
+			return nil, p.errorf("expected ',' or %q in flow collection", closer)
+		}
+	}
+}
+
+// flowNode parses a node inside flow collection.
+func (p *yamlParser) flowNode() (Value, error) {
+	p.skipFlow()
+	anchor, err := p.properties()
+	if err != nil {
+		return nil, err
+	}
+	p.skipFlow()
+	var v Value
+	switch c := p.peek(); c {
+	case '*':
+		v, err = p.alias()
+	case '[', '{':
+		v, err = p.flow()
+	case '"', '\'':
+		v, err = p.quoted()
+	case ',', ']', '}':
+		v = nil
+	default:
+		v = p.scalar(p.plain(-1, true))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = v
+	}
+	return v, nil
+}